@@ -0,0 +1,58 @@
+package revolver
+
+import "time"
+
+// adaptiveIdleCycles is the number of consecutive polls without changes after
+// which the poll interval starts backing off.
+const adaptiveIdleCycles = 3
+
+// adaptiveInterval is the poll interval of the watch loop. It is the minimum
+// interval while changes are detected, and doubles with every poll after
+// adaptiveIdleCycles polls without changes, up to the maximum interval.
+type adaptiveInterval struct {
+	min, max time.Duration
+	current  time.Duration
+	// idle is the number of consecutive polls without changes.
+	idle int
+}
+
+// newAdaptiveInterval returns the adaptive interval of the config. The unset
+// MinInterval and MaxInterval default to Interval, so a config with only an
+// Interval polls at a fixed interval.
+func newAdaptiveInterval(config Config) *adaptiveInterval {
+	min, max := config.MinInterval, config.MaxInterval
+	if min <= 0 {
+		min = config.Interval
+	}
+	if max <= 0 {
+		max = config.Interval
+	}
+	if max < min {
+		max = min
+	}
+	return &adaptiveInterval{min: min, max: max, current: min}
+}
+
+// interval returns the duration to wait before the next poll.
+func (a *adaptiveInterval) interval() time.Duration {
+	return a.current
+}
+
+// changed resets the interval to the minimum after a poll with changes.
+func (a *adaptiveInterval) changed() {
+	a.idle = 0
+	a.current = a.min
+}
+
+// unchanged backs off the interval after a poll without changes.
+func (a *adaptiveInterval) unchanged() {
+	a.idle++
+	if a.idle < adaptiveIdleCycles {
+		return
+	}
+	next := a.current * 2
+	if next <= a.current || next > a.max {
+		next = a.max
+	}
+	a.current = next
+}
@@ -0,0 +1,51 @@
+package revolver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveInterval(t *testing.T) {
+	type testCase struct {
+		config Config
+		// polls are whether the consecutive polls detected changes.
+		polls    []bool
+		expected []time.Duration
+	}
+	for name, tc := range map[string]testCase{
+		"interval only": {
+			config:   Config{Interval: time.Second},
+			polls:    []bool{false, false, false, false, true},
+			expected: []time.Duration{time.Second, time.Second, time.Second, time.Second, time.Second},
+		},
+		"back off": {
+			config:   Config{MinInterval: 100 * time.Millisecond, MaxInterval: time.Second},
+			polls:    []bool{false, false, false, false, false, false},
+			expected: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second},
+		},
+		"changes reset": {
+			config:   Config{MinInterval: 100 * time.Millisecond, MaxInterval: time.Second},
+			polls:    []bool{false, false, false, false, true, false},
+			expected: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond},
+		},
+		"max defaults to interval": {
+			config:   Config{Interval: 300 * time.Millisecond, MinInterval: 100 * time.Millisecond},
+			polls:    []bool{false, false, false, false},
+			expected: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			poll := newAdaptiveInterval(tc.config)
+			for i, changed := range tc.polls {
+				if changed {
+					poll.changed()
+				} else {
+					poll.unchanged()
+				}
+				if poll.interval() != tc.expected[i] {
+					t.Fatalf("Interval after poll %d should be %v; got: %v", i+1, tc.expected[i], poll.interval())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package revolver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// DetectArchive returns a DetectFunc that detects the changes of the entries
+// of the ZIP or TAR archive at path without extracting it. The entries are
+// compared by the SHA-256 hash of their content.
+func DetectArchive(path string) DetectFunc {
+	return changedPaths(DetectArchiveEvents(path))
+}
+
+// DetectArchiveEvents returns a DetectEventsFunc that detects the changes of
+// the entries of the archive at path like DetectArchive does. If the archive
+// can't be read, e.g. while it's being written, no changes are returned.
+func DetectArchiveEvents(path string) DetectEventsFunc {
+	registry := FileRegistry{UseHash: true}
+	prev := make(map[string]FileInfo)
+
+	return func() []ChangeEvent {
+		curr, err := snapshotArchive(path)
+		if err != nil {
+			return []ChangeEvent{}
+		}
+		changed := registry.Diff(prev, curr)
+		prev = curr
+		return changed
+	}
+}
+
+// snapshotArchive returns the state of the entries of the archive at path by
+// their name. ZIP archives are recognized by their .zip extension, gzipped TAR
+// archives by their .tar.gz or .tgz extension, everything else is read as a
+// TAR archive.
+func snapshotArchive(path string) (map[string]FileInfo, error) {
+	if strings.HasSuffix(path, ".zip") {
+		return snapshotZip(path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return snapshotTar(r)
+}
+
+func snapshotZip(path string) (map[string]FileInfo, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	files := make(map[string]FileInfo)
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		content, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		hash, err := hashReader(content)
+		content.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name] = FileInfo{
+			ModTime: entry.Modified,
+			Size:    int64(entry.UncompressedSize64),
+			Hash:    hash,
+		}
+	}
+	return files, nil
+}
+
+func snapshotTar(r io.Reader) (map[string]FileInfo, error) {
+	archive := tar.NewReader(r)
+
+	files := make(map[string]FileInfo)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		hash, err := hashReader(archive)
+		if err != nil {
+			return nil, err
+		}
+		files[header.Name] = FileInfo{
+			ModTime: header.ModTime,
+			Size:    header.Size,
+			Hash:    hash,
+		}
+	}
+}
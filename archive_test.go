@@ -0,0 +1,115 @@
+package revolver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Cannot create archive: %v", err)
+	}
+	defer file.Close()
+
+	w := zip.NewWriter(file)
+	for name, content := range files {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Cannot create archive entry: %v", err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatalf("Cannot write archive entry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Cannot close archive: %v", err)
+	}
+}
+
+func writeTar(t *testing.T, path string, files map[string]string) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Cannot create archive: %v", err)
+	}
+	defer file.Close()
+
+	w := tar.NewWriter(file)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatalf("Cannot write archive header: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("Cannot write archive entry: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Cannot close archive: %v", err)
+	}
+}
+
+func TestDetectArchive(t *testing.T) {
+	type testCase struct {
+		name  string
+		write func(t *testing.T, path string, files map[string]string)
+	}
+
+	testCases := map[string]testCase{
+		"zip": {name: "src.zip", write: writeZip},
+		"tar": {name: "src.tar", write: writeTar},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			path := filepath.Join(dir, tc.name)
+
+			tc.write(t, path, map[string]string{
+				"main.go":     "package main",
+				"lib/util.go": "package lib",
+			})
+
+			detect := DetectArchive(path)
+			if changes, expected := detect(), []string{"main.go", "lib/util.go"}; !equals(expected, changes) {
+				t.Errorf("Detect() should return: %v; got: %v", expected, changes)
+			}
+			if changes := detect(); len(changes) != 0 {
+				t.Errorf("Detect() without changes should be empty; got: %v", changes)
+			}
+
+			tc.write(t, path, map[string]string{
+				"main.go":     "package main",
+				"lib/util.go": "package util",
+			})
+			if changes, expected := detect(), []string{"lib/util.go"}; !equals(expected, changes) {
+				t.Errorf("Detect() should return: %v; got: %v", expected, changes)
+			}
+		})
+	}
+
+	t.Run("not exists", func(t *testing.T) {
+		detect := DetectArchive("testdata/not_exists.zip")
+		if changes := detect(); len(changes) != 0 {
+			t.Errorf("Detect() of a missing archive should be empty; got: %v", changes)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+		path := filepath.Join(dir, "src.zip")
+		if err := ioutil.WriteFile(path, []byte("not a zip"), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+
+		if changes := DetectArchive(path)(); len(changes) != 0 {
+			t.Errorf("Detect() of an invalid archive should be empty; got: %v", changes)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package revolver
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// The types of the audit records.
+const (
+	AuditChanges     = "changes"
+	AuditFilter      = "filter"
+	AuditBuildStart  = "build_start"
+	AuditBuildResult = "build_result"
+	AuditBuildSkip   = "build_skipped"
+	AuditRunStart    = "run_start"
+	AuditRunStop     = "run_stop"
+)
+
+// AuditRecord is a line of the audit log.
+type AuditRecord struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"ts"`
+	Type    string    `json:"type"`
+	Action  string    `json:"action,omitempty"`
+	Cycle   int       `json:"cycle,omitempty"`
+	Files   []string  `json:"files,omitempty"`
+	Outcome string    `json:"outcome,omitempty"`
+}
+
+// auditLog appends the audit records to a JSON lines file. A nil auditLog
+// discards the records.
+type auditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening audit log: %w", err)
+	}
+	return &auditLog{file: file}, nil
+}
+
+// record appends the record to the log, setting its ID and time. The record
+// is written synchronously, so it's durable when record returns.
+func (l *auditLog) record(record AuditRecord) error {
+	if l == nil {
+		return nil
+	}
+	record.ID = newUUID()
+	record.Time = time.Now()
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.file.Write(append(line, '\n'))
+	return err
+}
+
+func (l *auditLog) close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,97 @@
+package revolver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	logDir, teardownLog := createTempDir(t)
+	defer teardownLog()
+	path := filepath.Join(logDir, "audit.jsonl")
+
+	config := Config{
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+		AuditLog: path,
+		Actions: []Action{
+			{Name: "server", Patterns: []string{"*.go"}, BuildCommands: []string{"true"}, RunCommand: "sleep 30"},
+			{Name: "docs", Patterns: []string{"*.md"}, BuildCommands: []string{"true"}},
+		},
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0600); err != nil {
+			t.Errorf("Cannot write file: %v", err)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := WatchUntil(ctx, config, func(result CycleResult) bool {
+		return result.Success() && len(result.Actions) > 0
+	})
+	if err != nil {
+		t.Fatalf("WatchUntil() err should be nil; got: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Cannot open audit log: %v", err)
+	}
+	defer file.Close()
+
+	records := []AuditRecord{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Audit log line should be valid JSON: %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+
+	expected := []AuditRecord{
+		{Type: AuditChanges, Cycle: 1, Files: []string{"main.go"}},
+		{Type: AuditFilter, Action: "server", Cycle: 1, Files: []string{"main.go"}, Outcome: "triggered"},
+		{Type: AuditFilter, Action: "docs", Cycle: 1, Outcome: "skipped"},
+		{Type: AuditBuildStart, Action: "server", Cycle: 1, Files: []string{"main.go"}},
+		{Type: AuditBuildResult, Action: "server", Cycle: 1, Outcome: "success"},
+		{Type: AuditRunStart, Action: "server", Cycle: 1},
+		{Type: AuditRunStop, Action: "server"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Audit log should have %d records; got: %+v", len(expected), records)
+	}
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ids := make(map[string]struct{})
+	for i, record := range records {
+		want := expected[i]
+		if record.Type != want.Type || record.Action != want.Action || record.Cycle != want.Cycle ||
+			record.Outcome != want.Outcome || !equals(record.Files, want.Files) {
+			t.Errorf("Record %d should be %+v; got: %+v", i, want, record)
+		}
+		if !uuid.MatchString(record.ID) {
+			t.Errorf("Record %d should have a UUID; got: %q", i, record.ID)
+		}
+		if _, ok := ids[record.ID]; ok {
+			t.Errorf("Record %d should have a unique ID; got: %q", i, record.ID)
+		}
+		ids[record.ID] = struct{}{}
+		if record.Time.IsZero() {
+			t.Errorf("Record %d should have a timestamp", i)
+		}
+	}
+}
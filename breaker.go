@@ -0,0 +1,127 @@
+package revolver
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreaker pauses all builds after Threshold consecutive failures. After
+// ResetAfter a single trial build is allowed, which closes the circuit again if
+// it succeeds. A zero Threshold disables the circuit breaker.
+type CircuitBreaker struct {
+	Threshold  int           `yaml:"threshold,omitempty"`
+	ResetAfter time.Duration `yaml:"resetAfter,omitempty"`
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// breaker implements the state machine of a CircuitBreaker.
+type breaker struct {
+	config CircuitBreaker
+	logger Logger
+	now    func() time.Time
+	// manual keeps the circuit open until reset, ignoring ResetAfter.
+	manual bool
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newBreaker(config CircuitBreaker, logger Logger) *breaker {
+	return &breaker{config: config, logger: logger, now: time.Now}
+}
+
+// allow reports whether a build can be started.
+func (b *breaker) allow() bool {
+	if b.config.Threshold <= 0 {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.manual || b.now().Sub(b.openedAt) < b.config.ResetAfter {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.trial = false
+		b.logger.Info("Circuit breaker half-open, allowing a trial build.")
+		fallthrough
+	case circuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// record records the result of a build that was allowed by allow.
+func (b *breaker) record(err error) {
+	if b.config.Threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if b.state != circuitClosed {
+			b.logger.Success("Circuit breaker closed.")
+		}
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.config.Threshold {
+		if b.state == circuitHalfOpen {
+			b.logger.Info("Circuit breaker opened again, the trial build failed.")
+		} else {
+			b.logger.Info("Circuit breaker opened after %d consecutive failures.", b.failures)
+		}
+		b.state = circuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// release gives back the trial build allowed by allow if the build didn't
+// start after all, e.g. because another breaker refused it.
+func (b *breaker) release() {
+	if b.config.Threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitHalfOpen {
+		b.trial = false
+	}
+}
+
+// reset closes the circuit, e.g. after the user fixed the failing build.
+func (b *breaker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != circuitClosed {
+		b.logger.Info("Circuit breaker reset.")
+	}
+	b.state = circuitClosed
+	b.failures = 0
+	b.trial = false
+}
@@ -0,0 +1,127 @@
+package revolver
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	errBuild := errors.New("build failed")
+
+	type step struct {
+		reset   bool
+		elapsed time.Duration
+		allow   bool
+		err     error
+		state   circuitState
+	}
+	type testCase struct {
+		config CircuitBreaker
+		manual bool
+		steps  []step
+	}
+	for name, tc := range map[string]testCase{
+		"disabled": {
+			config: CircuitBreaker{},
+			steps: []step{
+				{allow: true, err: errBuild, state: circuitClosed},
+				{allow: true, err: errBuild, state: circuitClosed},
+				{allow: true, err: errBuild, state: circuitClosed},
+			},
+		},
+		"success resets failures": {
+			config: CircuitBreaker{Threshold: 2, ResetAfter: time.Minute},
+			steps: []step{
+				{allow: true, err: errBuild, state: circuitClosed},
+				{allow: true, err: nil, state: circuitClosed},
+				{allow: true, err: errBuild, state: circuitClosed},
+			},
+		},
+		"open": {
+			config: CircuitBreaker{Threshold: 2, ResetAfter: time.Minute},
+			steps: []step{
+				{allow: true, err: errBuild, state: circuitClosed},
+				{allow: true, err: errBuild, state: circuitOpen},
+				{elapsed: time.Second, allow: false, state: circuitOpen},
+			},
+		},
+		"trial and close": {
+			config: CircuitBreaker{Threshold: 1, ResetAfter: time.Minute},
+			steps: []step{
+				{allow: true, err: errBuild, state: circuitOpen},
+				{elapsed: time.Minute, allow: true, err: nil, state: circuitClosed},
+				{allow: true, err: nil, state: circuitClosed},
+			},
+		},
+		"trial and reopen": {
+			config: CircuitBreaker{Threshold: 1, ResetAfter: time.Minute},
+			steps: []step{
+				{allow: true, err: errBuild, state: circuitOpen},
+				{elapsed: time.Minute, allow: true, err: errBuild, state: circuitOpen},
+				{elapsed: time.Second, allow: false, state: circuitOpen},
+				{elapsed: time.Minute, allow: true, err: nil, state: circuitClosed},
+			},
+		},
+		"manual": {
+			config: CircuitBreaker{Threshold: 1},
+			manual: true,
+			steps: []step{
+				{allow: true, err: errBuild, state: circuitOpen},
+				{elapsed: time.Hour, allow: false, state: circuitOpen},
+				{reset: true, allow: true, err: nil, state: circuitClosed},
+			},
+		},
+		"reset": {
+			config: CircuitBreaker{Threshold: 2, ResetAfter: time.Minute},
+			steps: []step{
+				{allow: true, err: errBuild, state: circuitClosed},
+				{allow: true, err: errBuild, state: circuitOpen},
+				{reset: true, allow: true, err: errBuild, state: circuitClosed},
+				{allow: true, err: errBuild, state: circuitOpen},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			b := newBreaker(tc.config, NewLogger("text", ioutil.Discard))
+			b.now = func() time.Time { return now }
+			b.manual = tc.manual
+
+			for i, step := range tc.steps {
+				if step.reset {
+					b.reset()
+				}
+				now = now.Add(step.elapsed)
+				allow := b.allow()
+				if allow != step.allow {
+					t.Fatalf("Step %d: allow() should return %v; got: %v", i, step.allow, allow)
+				}
+				if allow {
+					b.record(step.err)
+				}
+				if b.state != step.state {
+					t.Fatalf("Step %d: state should be %v; got: %v", i, step.state, b.state)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerSingleTrial(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(CircuitBreaker{Threshold: 1, ResetAfter: time.Minute}, NewLogger("text", ioutil.Discard))
+	b.now = func() time.Time { return now }
+
+	b.allow()
+	b.record(errors.New("build failed"))
+	now = now.Add(time.Minute)
+
+	if !b.allow() {
+		t.Fatalf("First build should be allowed as a trial")
+	}
+	if b.allow() {
+		t.Errorf("Only one trial build should be allowed")
+	}
+}
@@ -1,15 +1,133 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
 
 	"github.com/kszab0/revolver"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validate(os.Args[2:]))
+		case "init":
+			os.Exit(initConfig(os.Args[2:]))
+		case "ctl":
+			os.Exit(ctl(os.Args[2:]))
+		}
+	}
+
 	config, err := revolver.ParseFlags(os.Args)
 	if err != nil {
 		panic(err)
 	}
-	revolver.Watch(*config)
+	if err := revolver.Watch(*config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		// Exit with the exit code of the failed build command if there is
+		// one, e.g. when runOnce is set.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+			os.Exit(exitErr.ExitCode())
+		}
+		os.Exit(1)
+	}
+}
+
+// validate checks the config file in args, or revolver.yml by default, and
+// returns the exit status.
+func validate(args []string) int {
+	path := "revolver.yml"
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	config, err := revolver.ParseConfigFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	errs := revolver.ValidateConfig(*config)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Printf("%s is valid\n", path)
+	return 0
+}
+
+// initConfig prints a starter config for the project in the current directory,
+// or writes it to revolver.yml with the -write flag, and returns the exit
+// status.
+func initConfig(args []string) int {
+	var write bool
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	flags.BoolVar(&write, "write", false, "Write the config to revolver.yml instead of stdout")
+	flags.Parse(args)
+
+	projectType, err := revolver.DetectProjectType(".")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	config := revolver.StarterConfig(projectType)
+	if !write {
+		fmt.Print(config)
+		return 0
+	}
+
+	const path = "revolver.yml"
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "%s already exists\n", path)
+		return 1
+	}
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%s written (project type: %s)\n", path, projectType)
+	return 0
+}
+
+// ctl sends the command in args (pause, resume, trigger <action> or status) to
+// the control socket of a running revolver, prints the status for the status
+// command, and returns the exit status.
+func ctl(args []string) int {
+	var socket string
+	flags := flag.NewFlagSet("ctl", flag.ExitOnError)
+	flags.StringVar(&socket, "socket", "", "Path to the control socket")
+	flags.Parse(args)
+
+	if socket == "" || flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: revolver ctl --socket <path> pause|resume|trigger <action>|status")
+		return 2
+	}
+	command := revolver.ControlCommand{Cmd: flags.Arg(0), Action: flags.Arg(1)}
+
+	response, err := revolver.SendControl(socket, command)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if !response.OK {
+		fmt.Fprintln(os.Stderr, response.Error)
+		return 1
+	}
+	if response.Status != nil {
+		status, err := json.MarshalIndent(response.Status, "", "  ")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(string(status))
+	}
+	return 0
 }
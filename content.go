@@ -0,0 +1,251 @@
+package revolver
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// ContentFilter decides whether the change of a file's content from prev to
+// curr is significant.
+type ContentFilter func(prev, curr []byte) bool
+
+// ExtensionContentFilters are the built-in ContentFilters by file extension.
+// They are heuristics that skip changes that can't affect a build.
+var ExtensionContentFilters = map[string]ContentFilter{
+	// .go files are skipped if only whitespace changed.
+	".go": func(prev, curr []byte) bool {
+		prevTokens, prevOK := goTokens(prev)
+		currTokens, currOK := goTokens(curr)
+		if !prevOK || !currOK {
+			return !bytes.Equal(prev, curr)
+		}
+		return !bytes.Equal(prevTokens, currTokens)
+	},
+	// .ts files are skipped if only comments (or whitespace) changed.
+	".ts": func(prev, curr []byte) bool {
+		return !bytes.Equal(tsTokens(prev), tsTokens(curr))
+	},
+}
+
+// goTokens returns the tokens of the Go source, comments included, separated
+// by zero bytes. The automatically inserted semicolons equal the explicit
+// ones. It returns false if the source can't be scanned.
+func goTokens(src []byte) ([]byte, bool) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	ok := true
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) { ok = false }, scanner.ScanComments)
+
+	var tokens []byte
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.SEMICOLON || lit == "" {
+			lit = tok.String()
+		}
+		tokens = append(tokens, lit...)
+		tokens = append(tokens, 0)
+	}
+	return tokens, ok
+}
+
+// tsTokens returns the TypeScript source without its comments and the
+// whitespace that doesn't separate tokens. String, template and regular
+// expression literals are kept as they are. Line breaks are kept, because
+// they can end statements.
+func tsTokens(src []byte) []byte {
+	var out []byte
+	// sep is the pending separator of the next token: 0, ' ' or '\n'.
+	var sep byte
+	// regexpAllowed reports whether a slash starts a regular expression
+	// literal instead of being a division.
+	regexpAllowed := true
+	emit := func(token []byte) {
+		if sep != 0 && len(out) > 0 {
+			// Spaces only matter between two words, e.g. "return x", or
+			// two operators, e.g. "+ +x".
+			if sep == '\n' || isTSWord(out[len(out)-1]) == isTSWord(token[0]) {
+				out = append(out, sep)
+			}
+		}
+		sep = 0
+		out = append(out, token...)
+	}
+	space := func(newline bool) {
+		if newline {
+			sep = '\n'
+		} else if sep == 0 {
+			sep = ' '
+		}
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			space(true)
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			space(false)
+			i++
+		case bytes.HasPrefix(src[i:], []byte("//")):
+			end := bytes.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			space(false)
+			i += end
+		case bytes.HasPrefix(src[i:], []byte("/*")):
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(src) - i - 2
+			}
+			space(bytes.IndexByte(src[i+2:i+2+end], '\n') >= 0)
+			i += end + 4
+		case c == '"' || c == '\'' || c == '`':
+			end := skipTSString(src, i)
+			emit(src[i:end])
+			regexpAllowed = false
+			i = end
+		case c == '/' && regexpAllowed:
+			end := skipTSRegexp(src, i)
+			emit(src[i:end])
+			regexpAllowed = false
+			i = end
+		case isTSWord(c):
+			end := i
+			for end < len(src) && isTSWord(src[end]) {
+				end++
+			}
+			emit(src[i:end])
+			regexpAllowed = tsKeywordsBeforeExpr[string(src[i:end])]
+			i = end
+		default:
+			emit(src[i : i+1])
+			regexpAllowed = c != ')' && c != ']' && c != '}'
+			i++
+		}
+	}
+	return out
+}
+
+// tsKeywordsBeforeExpr are the keywords after which a slash starts a regular
+// expression literal.
+var tsKeywordsBeforeExpr = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "in": true, "of": true,
+	"new": true, "delete": true, "void": true, "throw": true, "case": true,
+	"do": true, "else": true, "yield": true, "await": true,
+}
+
+// isTSWord reports whether c is a byte of an identifier, keyword or number.
+func isTSWord(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// skipTSString returns the end of the string or template literal starting at
+// i. Single and double quoted strings end at the end of the line at last.
+func skipTSString(src []byte, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			if quote != '`' {
+				return j
+			}
+		}
+	}
+	return len(src)
+}
+
+// skipTSRegexp returns the end of the regular expression literal, flags
+// included, starting at i.
+func skipTSRegexp(src []byte, i int) int {
+	class := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			class = true
+		case ']':
+			class = false
+		case '\n':
+			return j
+		case '/':
+			if !class {
+				j++
+				for j < len(src) && isTSWord(src[j]) {
+					j++
+				}
+				return j
+			}
+		}
+	}
+	return len(src)
+}
+
+// DetectWithContentFilters returns a DetectFunc that wraps detect and drops
+// the changed files whose content change is not significant according to the
+// ContentFilter registered for their extension. The changed paths are relative
+// to dir. Files without a ContentFilter are never dropped.
+func DetectWithContentFilters(dir string, detect DetectFunc, filters map[string]ContentFilter) DetectFunc {
+	return detectWithContentFilters(dir, FileRegistry{}, detect, filters)
+}
+
+// detectWithContentFilters returns a DetectFunc like DetectWithContentFilters
+// does. The first detection caches the contents of the files of dir walked by
+// registry, so the first change of a file is filtered too. The changes it
+// returns are not filtered.
+func detectWithContentFilters(dir string, registry FileRegistry, detect DetectFunc, filters map[string]ContentFilter) DetectFunc {
+	var contents map[string][]byte
+
+	return func() []string {
+		if contents == nil {
+			contents = make(map[string][]byte)
+			files, _ := registry.Snapshot(dir)
+			for name, file := range files {
+				if _, ok := filters[filepath.Ext(name)]; !ok || file.Oversized {
+					continue
+				}
+				if content, err := ioutil.ReadFile(filepath.Join(dir, name)); err == nil {
+					contents[name] = content
+				}
+			}
+			return detect()
+		}
+
+		changed := []string{}
+		for _, name := range detect() {
+			filter, ok := filters[filepath.Ext(name)]
+			if !ok {
+				changed = append(changed, name)
+				continue
+			}
+
+			curr, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				delete(contents, name)
+				changed = append(changed, name)
+				continue
+			}
+
+			prev, ok := contents[name]
+			contents[name] = curr
+			if !ok || filter(prev, curr) {
+				changed = append(changed, name)
+			}
+		}
+		return changed
+	}
+}
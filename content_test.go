@@ -0,0 +1,207 @@
+package revolver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtensionContentFilters(t *testing.T) {
+	type testCase struct {
+		ext         string
+		prev, curr  string
+		significant bool
+	}
+	for name, tc := range map[string]testCase{
+		"go: code change": {
+			ext:         ".go",
+			prev:        "package main\n\nfunc main() {}\n",
+			curr:        "package main\n\nfunc main() { println() }\n",
+			significant: true,
+		},
+		"go: whitespace change": {
+			ext:         ".go",
+			prev:        "package main\n\nfunc main() {}\n",
+			curr:        "package main\n\n\nfunc  main()  {}\n\n",
+			significant: false,
+		},
+		"go: comment change": {
+			ext:         ".go",
+			prev:        "package main\n",
+			curr:        "// Package main\npackage main\n",
+			significant: true,
+		},
+		"go: tokens merged": {
+			ext:         ".go",
+			prev:        "package main\n\nfunc f() int { return x }\n",
+			curr:        "package main\n\nfunc f() int { returnx }\n",
+			significant: true,
+		},
+		"go: whitespace change in string": {
+			ext:         ".go",
+			prev:        "package main\n\nconst s = \"a b\"\n",
+			curr:        "package main\n\nconst s = \"ab\"\n",
+			significant: true,
+		},
+		"go: line break ending statement": {
+			ext:         ".go",
+			prev:        "package main\n\nfunc f() { return; x(); }\n",
+			curr:        "package main\n\nfunc f() {\n\treturn\n\tx()\n}\n",
+			significant: false,
+		},
+		"ts: code change": {
+			ext:         ".ts",
+			prev:        "const a = 1;\n",
+			curr:        "const a = 2;\n",
+			significant: true,
+		},
+		"ts: line comment change": {
+			ext:         ".ts",
+			prev:        "const a = 1; // one\n",
+			curr:        "const a = 1; // the number one\n",
+			significant: false,
+		},
+		"ts: block comment added": {
+			ext:         ".ts",
+			prev:        "const a = 1;\n",
+			curr:        "/**\n * The number one.\n */\nconst a = 1;\n",
+			significant: false,
+		},
+		"ts: comment marker in string": {
+			ext:         ".ts",
+			prev:        "const url = \"http://a\";\n",
+			curr:        "const url = \"http://b\";\n",
+			significant: true,
+		},
+		"ts: comment marker in template": {
+			ext:         ".ts",
+			prev:        "const s = `/* ${a} */`;\n",
+			curr:        "const s = `/* ${b} */`;\n",
+			significant: true,
+		},
+		"ts: comment marker in regexp": {
+			ext:         ".ts",
+			prev:        "const r = /a\\/\\/b/; // one\n",
+			curr:        "const r = /a\\/\\/b/; // two\n",
+			significant: false,
+		},
+		"ts: regexp change": {
+			ext:         ".ts",
+			prev:        "const r = /[/]a/;\n",
+			curr:        "const r = /[/]b/;\n",
+			significant: true,
+		},
+		"ts: whitespace change in string": {
+			ext:         ".ts",
+			prev:        "const s = 'a b';\n",
+			curr:        "const s = 'ab';\n",
+			significant: true,
+		},
+		"ts: tokens merged": {
+			ext:         ".ts",
+			prev:        "function f() { return x; }\n",
+			curr:        "function f() { returnx; }\n",
+			significant: true,
+		},
+		"ts: operator spacing": {
+			ext:         ".ts",
+			prev:        "const a = b + 1;\n",
+			curr:        "const a=b+1;\n",
+			significant: false,
+		},
+		"ts: line break ending statement": {
+			ext:         ".ts",
+			prev:        "function f() { return x; }\n",
+			curr:        "function f() { return\nx; }\n",
+			significant: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filter := ExtensionContentFilters[tc.ext]
+			if significant := filter([]byte(tc.prev), []byte(tc.curr)); significant != tc.significant {
+				t.Errorf("ContentFilter should return %v; got: %v", tc.significant, significant)
+			}
+		})
+	}
+}
+
+func TestDetectWithContentFilters(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+
+	changes := [][]string{
+		{"main.go", "app.ts", "README.md"},
+		{"main.go", "app.ts", "README.md"},
+		{"main.go"},
+	}
+	detect := DetectWithContentFilters(dir, func() []string {
+		c := changes[0]
+		changes = changes[1:]
+		return c
+	}, ExtensionContentFilters)
+
+	write("main.go", "package main\n")
+	write("app.ts", "const a = 1;\n")
+	write("README.md", "# readme\n")
+	if changed := detect(); !equals(changed, []string{"main.go", "app.ts", "README.md"}) {
+		t.Errorf("New files should be changed; got: %v", changed)
+	}
+
+	write("main.go", "package  main\n\n")
+	write("app.ts", "// comment\nconst a = 1;\n")
+	write("README.md", "#  readme\n")
+	if changed := detect(); !equals(changed, []string{"README.md"}) {
+		t.Errorf("Changed files should be: %v; got: %v", []string{"README.md"}, changed)
+	}
+
+	write("main.go", "package app\n")
+	if changed := detect(); !equals(changed, []string{"main.go"}) {
+		t.Errorf("Changed files should be: %v; got: %v", []string{"main.go"}, changed)
+	}
+}
+
+func TestDetectWithContentFiltersFirstChange(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+
+	// The wrapped detect doesn't report the existing files.
+	changes := [][]string{
+		{},
+		{"main.go", "app.ts"},
+		{"main.go"},
+	}
+	detect := DetectWithContentFilters(dir, func() []string {
+		c := changes[0]
+		changes = changes[1:]
+		return c
+	}, ExtensionContentFilters)
+
+	write("main.go", "package main\n")
+	write("app.ts", "const a = 1;\n")
+	if changed := detect(); len(changed) != 0 {
+		t.Errorf("No files should be changed; got: %v", changed)
+	}
+
+	write("main.go", "package  main\n\n")
+	write("app.ts", "// comment\nconst a = 1;\n")
+	if changed := detect(); len(changed) != 0 {
+		t.Errorf("The first insignificant changes should be dropped; got: %v", changed)
+	}
+
+	write("main.go", "package app\n")
+	if changed := detect(); !equals(changed, []string{"main.go"}) {
+		t.Errorf("Changed files should be: %v; got: %v", []string{"main.go"}, changed)
+	}
+}
@@ -0,0 +1,63 @@
+package revolver
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultErrorPattern matches the "file:line:" prefix of the errors of
+// compilers like the Go compiler, e.g. "./main.go:45:2: undefined: x".
+const DefaultErrorPattern = `(?m)^\s*([^\s:]+):(\d+)(?::\d+)?: `
+
+// errorPattern compiles the error pattern of the config, which should capture
+// the file in its first and the line in its second group.
+func (config Config) errorPattern() (*regexp.Regexp, error) {
+	pattern := config.ErrorPattern
+	if pattern == "" {
+		pattern = DefaultErrorPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("Error compiling errorPattern: %w", err)
+	}
+	if re.NumSubexp() < 2 {
+		return nil, fmt.Errorf("errorPattern should capture the file and the line: %s", pattern)
+	}
+	return re, nil
+}
+
+// correlateErrors returns a callout line for every error in the build output
+// located in one of the changed files.
+func correlateErrors(pattern *regexp.Regexp, output []byte, changes []string) []string {
+	callouts := []string{}
+	seen := make(map[string]struct{})
+	for _, match := range pattern.FindAllSubmatch(output, -1) {
+		file, line := string(match[1]), string(match[2])
+		if !matchChangedFile(file, changes) {
+			continue
+		}
+		location := file + ":" + line
+		if _, ok := seen[location]; ok {
+			continue
+		}
+		seen[location] = struct{}{}
+		callouts = append(callouts, "┌── Error in "+location)
+	}
+	return callouts
+}
+
+// matchChangedFile reports whether the file of an error is one of the changed
+// files. The paths may be relative to different dirs, so a path matches if it
+// ends with the other one.
+func matchChangedFile(file string, changes []string) bool {
+	file = filepath.ToSlash(filepath.Clean(file))
+	for _, change := range changes {
+		change = filepath.ToSlash(filepath.Clean(change))
+		if file == change || strings.HasSuffix(file, "/"+change) || strings.HasSuffix(change, "/"+file) {
+			return true
+		}
+	}
+	return false
+}
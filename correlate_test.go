@@ -0,0 +1,94 @@
+package revolver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const goCompilerOutput = `# example.com/app
+./main.go:45:2: undefined: server
+./main.go:45:9: undefined: port
+internal/db/db.go:12:5: imported and not used: "fmt"
+`
+
+func TestCorrelateErrors(t *testing.T) {
+	type testCase struct {
+		changes  []string
+		expected []string
+	}
+	for name, tc := range map[string]testCase{
+		"changed file": {
+			changes:  []string{"main.go"},
+			expected: []string{"┌── Error in ./main.go:45"},
+		},
+		"nested changed file": {
+			changes:  []string{"app/internal/db/db.go", "README.md"},
+			expected: []string{"┌── Error in internal/db/db.go:12"},
+		},
+		"every changed file": {
+			changes:  []string{"main.go", "internal/db/db.go"},
+			expected: []string{"┌── Error in ./main.go:45", "┌── Error in internal/db/db.go:12"},
+		},
+		"other file": {
+			changes:  []string{"handler.go"},
+			expected: []string{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pattern, err := Config{}.errorPattern()
+			if err != nil {
+				t.Fatalf("errorPattern() err should be nil; got: %v", err)
+			}
+			callouts := correlateErrors(pattern, []byte(goCompilerOutput), tc.changes)
+			if !reflect.DeepEqual(tc.expected, callouts) {
+				t.Errorf("correlateErrors() should return: %v; got: %v", tc.expected, callouts)
+			}
+		})
+	}
+}
+
+func TestErrorPattern(t *testing.T) {
+	for pattern, valid := range map[string]bool{
+		"":               true,
+		`(\S+)\((\d+)\)`: true,
+		`(\S+):\d+`:      false,
+		`([a-`:           false,
+	} {
+		config := Config{ErrorCorrelation: true, ErrorPattern: pattern, Actions: []Action{{BuildCommands: []string{"true"}}}}
+		if err := config.validate(); (err == nil) != valid {
+			t.Errorf("validate() of errorPattern %q should be valid: %v; got: %v", pattern, valid, err)
+		}
+	}
+}
+
+func TestRunActionErrorCorrelation(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	output := filepath.Join(dir, "output")
+	if err := ioutil.WriteFile(output, []byte(goCompilerOutput), 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+
+	config := Config{
+		ErrorCorrelation: true,
+		Shell:            "sh -c",
+		Actions: []Action{
+			{Name: "build", BuildCommands: []string{"cat " + output + " >&2; exit 2"}},
+		},
+	}
+	actions := mustParseActions(t, config)
+	actions[0].TriggeredBy = []string{"main.go"}
+	r := newRunner(config)
+	r.logger = &recordLogger{}
+
+	err := r.runAction(actions[0], 1)
+	if err == nil {
+		t.Fatalf("runAction() err should not be nil")
+	}
+	if !strings.HasPrefix(err.Error(), "┌── Error in ./main.go:45\n") {
+		t.Errorf("Error should start with the callout; got: %q", err.Error())
+	}
+}
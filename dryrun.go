@@ -0,0 +1,50 @@
+package revolver
+
+import (
+	"fmt"
+	"os"
+)
+
+// dryRun logs the commands the actions would execute without executing them.
+// It checks that the watched paths and the working directories of the actions
+// exist.
+func dryRun(config Config, logger Logger) error {
+	paths := config.Dirs
+	if len(paths) == 0 {
+		paths = []string{config.Dir}
+	}
+	if config.Archive {
+		paths = []string{config.ArchivePath}
+	}
+	for _, path := range paths {
+		if path == "" {
+			path = "."
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("Error checking watched path: %w", err)
+		}
+	}
+
+	actions, err := parseActions(config)
+	if err != nil {
+		return err
+	}
+	for _, a := range actions {
+		logger := loggerFor(logger, a.ID)
+		source := a.Source.Action
+		if dir := source.workingDir(config); dir != "" {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return fmt.Errorf("Error checking working directory: %s should be a directory", dir)
+			}
+			logger.Info("Would execute in: %s", dir)
+		}
+		for _, command := range source.BuildCommands {
+			logger.Info("Would build: %s", command)
+		}
+		if source.RunCommand != "" {
+			logger.Info("Would run: %s", source.RunCommand)
+		}
+	}
+	logger.Success("Dry run finished, nothing was executed.")
+	return nil
+}
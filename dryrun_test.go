@@ -0,0 +1,76 @@
+package revolver
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	t.Run("commands", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+		if err := os.Mkdir(filepath.Join(dir, "server"), 0700); err != nil {
+			t.Fatalf("Cannot create dir: %v", err)
+		}
+
+		config := Config{
+			Dir: dir,
+			Actions: []Action{
+				{
+					Name:          "server",
+					WorkingDir:    "server",
+					BuildCommands: []string{"touch built"},
+					RunCommand:    "touch running",
+				},
+				{BuildCommands: []string{"touch " + filepath.Join(dir, "built")}},
+			},
+		}
+		logger := &recordLogger{}
+		if err := dryRun(config, logger); err != nil {
+			t.Fatalf("dryRun() err should be nil; got: %v", err)
+		}
+
+		expected := []string{
+			"info: [server] Would execute in: " + filepath.Join(dir, "server"),
+			"info: [server] Would build: touch built",
+			"info: [server] Would run: touch running",
+			"info: [2] Would build: touch " + filepath.Join(dir, "built"),
+			"success: Dry run finished, nothing was executed.",
+		}
+		if !reflect.DeepEqual(expected, logger.lines) {
+			t.Errorf("dryRun() should log: %v; got: %v", expected, logger.lines)
+		}
+
+		for _, name := range []string{"server/built", "server/running", "built"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				t.Errorf("%s should not be created", name)
+			}
+		}
+	})
+
+	t.Run("missing paths", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		for name, config := range map[string]Config{
+			"dir": {
+				Dir:     filepath.Join(dir, "not_exists"),
+				Actions: []Action{{BuildCommands: []string{"true"}}},
+			},
+			"dirs": {
+				Dirs:    []string{dir, filepath.Join(dir, "not_exists")},
+				Actions: []Action{{BuildCommands: []string{"true"}}},
+			},
+			"working dir": {
+				Dir:     dir,
+				Actions: []Action{{WorkingDir: "not_exists", BuildCommands: []string{"true"}}},
+			},
+		} {
+			if err := dryRun(config, &recordLogger{}); err == nil {
+				t.Errorf("dryRun() err should not be nil for a missing %s", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package revolver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LoadEnvFile loads the env variables of a dotenv file: KEY=VALUE lines, where
+// the value can be quoted with single or double quotes. Empty lines and lines
+// starting with # are skipped, and an export prefix is allowed.
+func LoadEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading env file: %w", err)
+	}
+	defer file.Close()
+
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		i := strings.Index(text, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("Error loading env file: %s:%d: expected KEY=VALUE", path, line)
+		}
+		key := strings.TrimSpace(text[:i])
+		value := strings.TrimSpace(text[i+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error loading env file: %w", err)
+	}
+	return env, nil
+}
+
+// envFileList loads the env file as env variables in "key=value" form. It's
+// empty if path is empty.
+func envFileList(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	env, err := LoadEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return envList(env), nil
+}
+
+// env returns the top level env variables of the config in "key=value" form:
+// the variables of EnvFile followed by Env, which override them.
+func (config Config) env() ([]string, error) {
+	env, err := envFileList(config.EnvFile)
+	if err != nil {
+		return nil, err
+	}
+	return append(env, envList(config.Env)...), nil
+}
@@ -0,0 +1,132 @@
+package revolver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadEnvFile(t *testing.T) {
+	type testCase struct {
+		content  string
+		expected map[string]string
+		err      bool
+	}
+	for name, tc := range map[string]testCase{
+		"empty": {
+			content:  "",
+			expected: map[string]string{},
+		},
+		"variables": {
+			content:  "A=1\nB = 2\nC=\n",
+			expected: map[string]string{"A": "1", "B": "2", "C": ""},
+		},
+		"comments and empty lines": {
+			content:  "# comment\n\nA=1\n  # indented comment\n",
+			expected: map[string]string{"A": "1"},
+		},
+		"quotes": {
+			content:  "A=\"a # b\"\nB='b'\nC=\"c\nD=x=y",
+			expected: map[string]string{"A": "a # b", "B": "b", "C": "\"c", "D": "x=y"},
+		},
+		"export": {
+			content:  "export A=1",
+			expected: map[string]string{"A": "1"},
+		},
+		"missing value": {
+			content: "A=1\nB",
+			err:     true,
+		},
+		"missing key": {
+			content: "=1",
+			err:     true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			path := filepath.Join(dir, ".env")
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatalf("Cannot write file: %v", err)
+			}
+
+			env, err := LoadEnvFile(path)
+			if err != nil {
+				if !tc.err {
+					t.Errorf("LoadEnvFile() err should be nil; got: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				t.Fatalf("LoadEnvFile() err should not be nil")
+			}
+			if !reflect.DeepEqual(env, tc.expected) {
+				t.Errorf("LoadEnvFile() should be %v; got: %v", tc.expected, env)
+			}
+		})
+	}
+}
+
+func TestParseActionsEnvFile(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	configFile := filepath.Join(dir, "config.env")
+	actionFile := filepath.Join(dir, "action.env")
+	if err := ioutil.WriteFile(configFile, []byte("A=file\nB=file\nC=file"), 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+	if err := ioutil.WriteFile(actionFile, []byte("C=action file\nD=action file"), 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+
+	type testCase struct {
+		config   Config
+		expected []string
+		err      bool
+	}
+	for name, tc := range map[string]testCase{
+		"config env file": {
+			config: Config{
+				EnvFile: configFile,
+				Env:     map[string]string{"B": "env"},
+				Actions: []Action{{BuildCommands: []string{"true"}}},
+			},
+			expected: []string{"A=file", "B=file", "C=file", "B=env"},
+		},
+		"action env file": {
+			config: Config{
+				EnvFile: configFile,
+				Actions: []Action{{
+					EnvFile:       actionFile,
+					Env:           map[string]string{"D": "env"},
+					BuildCommands: []string{"true"},
+				}},
+			},
+			expected: []string{"A=file", "B=file", "C=file", "C=action file", "D=action file", "D=env"},
+		},
+		"missing env file": {
+			config: Config{
+				EnvFile: filepath.Join(dir, "missing.env"),
+				Actions: []Action{{BuildCommands: []string{"true"}}},
+			},
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actions, err := parseActions(tc.config)
+			if err != nil {
+				if !tc.err {
+					t.Errorf("parseActions() err should be nil; got: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				t.Fatalf("parseActions() err should not be nil")
+			}
+			if env := actions[0].Source.Env; !reflect.DeepEqual(env, tc.expected) {
+				t.Errorf("Env should be %v; got: %v", tc.expected, env)
+			}
+		})
+	}
+}
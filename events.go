@@ -0,0 +1,101 @@
+package revolver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of a watch event.
+type EventKind int
+
+// The kinds of watch events.
+const (
+	BuildStarted EventKind = iota
+	BuildSucceeded
+	BuildFailed
+	RunStarted
+	RunStopped
+	// WatchStopped is the last event, its Err is the error that stopped
+	// watching.
+	WatchStopped
+	// RunRestarted is sent when a run command that exited by itself is
+	// restarted because of its maxRestarts.
+	RunRestarted
+)
+
+func (kind EventKind) String() string {
+	switch kind {
+	case BuildStarted:
+		return "build_started"
+	case BuildSucceeded:
+		return "build_succeeded"
+	case BuildFailed:
+		return "build_failed"
+	case RunStarted:
+		return "run_started"
+	case RunStopped:
+		return "run_stopped"
+	case WatchStopped:
+		return "watch_stopped"
+	case RunRestarted:
+		return "run_restarted"
+	}
+	return fmt.Sprintf("EventKind(%d)", int(kind))
+}
+
+// Event is an event of the lifecycle of the actions.
+type Event struct {
+	// ActionID is the ID of the action. It is empty for WatchStopped.
+	ActionID string
+	Kind     EventKind
+	// Err is the error of a failed build or the error that stopped watching.
+	Err error
+	// Duration is the duration of the build for BuildSucceeded and
+	// BuildFailed.
+	Duration time.Duration
+	// Output is the combined standard output and standard error of the build
+	// for BuildSucceeded and BuildFailed, if Config.CaptureOutput is set.
+	Output []byte
+}
+
+// WatchEvents watches like Watch does in the background and sends the events
+// of the actions to the returned channel. Calling the returned cancel func
+// stops watching. The last event is WatchStopped, after which the channel is
+// closed. The channel must be received from until it's closed, as watching
+// waits for every event to be received.
+func WatchEvents(config Config) (<-chan Event, context.CancelFunc, error) {
+	_, events, cancel, err := watchEvents(config)
+	return events, cancel, err
+}
+
+// watchEvents is WatchEvents returning the started watcher as well.
+func watchEvents(config Config) (*Watcher, <-chan Event, context.CancelFunc, error) {
+	events := make(chan Event, 16)
+
+	watcher, err := NewWatcher(config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	watcher.onEvent = func(event Event) {
+		events <- event
+	}
+	if err := watcher.Start(context.Background()); err != nil {
+		return nil, nil, nil, err
+	}
+
+	go func() {
+		err := watcher.Wait()
+		events <- Event{Kind: WatchStopped, Err: err}
+		close(events)
+	}()
+
+	var once sync.Once
+	cancel := func() {
+		// Stopping waits for the events of the stopped commands to be
+		// received, so it can't block the receiver.
+		once.Do(func() { go watcher.Stop() })
+	}
+	return watcher, events, cancel, nil
+}
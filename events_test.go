@@ -0,0 +1,127 @@
+package revolver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWatchEvents(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	events, cancel, err := WatchEvents(Config{
+		Dir:        dir,
+		Interval:   10 * time.Millisecond,
+		RunOnStart: true,
+		Actions: []Action{
+			{Name: "server", BuildCommands: []string{"true"}, RunCommand: "sleep 5"},
+			{Name: "lint", BuildCommands: []string{"false"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("WatchEvents() err should be nil; got: %v", err)
+	}
+
+	type expectedEvent struct {
+		id   string
+		kind EventKind
+	}
+	receive := func(expected []expectedEvent) {
+		for _, want := range expected {
+			select {
+			case event := <-events:
+				if event.ActionID != want.id || event.Kind != want.kind {
+					t.Fatalf("Event should be %s %v; got: %+v", want.id, want.kind, event)
+				}
+				if (event.Err != nil) != (event.Kind == BuildFailed) {
+					t.Errorf("Only failed builds should have an error; got: %+v", event)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Event %s %v should be received", want.id, want.kind)
+			}
+		}
+	}
+
+	receive([]expectedEvent{
+		{"server", BuildStarted},
+		{"server", BuildSucceeded},
+		{"server", RunStarted},
+		{"lint", BuildStarted},
+		{"lint", BuildFailed},
+	})
+
+	cancel()
+	cancel()
+	receive([]expectedEvent{
+		{"server", RunStopped},
+		{"", WatchStopped},
+	})
+
+	select {
+	case event, ok := <-events:
+		if ok {
+			t.Errorf("Channel should be closed after WatchStopped; got: %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Channel should be closed after WatchStopped")
+	}
+}
+
+func TestWatchEventsCaptureOutput(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	events, cancel, err := WatchEvents(Config{
+		Dir:           dir,
+		Interval:      10 * time.Millisecond,
+		RunOnStart:    true,
+		Shell:         "sh -c",
+		CaptureOutput: true,
+		Actions: []Action{
+			{Name: "build", BuildCommands: []string{"echo built", "echo warning >&2"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("WatchEvents() err should be nil; got: %v", err)
+	}
+	defer func() {
+		cancel()
+		for range events {
+		}
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			if event.Kind == BuildStarted {
+				continue
+			}
+			if event.Kind != BuildSucceeded {
+				t.Fatalf("Build should succeed; got: %+v", event)
+			}
+			if expected := "built\nwarning\n"; string(event.Output) != expected {
+				t.Errorf("Output should be %q; got: %q", expected, event.Output)
+			}
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Build should be finished")
+		}
+	}
+}
+
+func TestEventKindString(t *testing.T) {
+	for kind, expected := range map[EventKind]string{
+		BuildStarted:   "build_started",
+		BuildSucceeded: "build_succeeded",
+		BuildFailed:    "build_failed",
+		RunStarted:     "run_started",
+		RunStopped:     "run_stopped",
+		WatchStopped:   "watch_stopped",
+		RunRestarted:   "run_restarted",
+		EventKind(42):  "EventKind(42)",
+	} {
+		if got := kind.String(); got != expected {
+			t.Errorf("String() should return %q; got: %q", expected, got)
+		}
+	}
+}
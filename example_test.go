@@ -0,0 +1,77 @@
+package revolver_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/kszab0/revolver"
+)
+
+func ExampleWatcher() {
+	watcher, err := revolver.NewWatcher(revolver.WatchConfig{
+		Dir:      ".",
+		Interval: time.Second,
+		Actions: []revolver.Action{
+			{
+				Name:          "server",
+				Patterns:      []string{"**/*.go"},
+				BuildCommands: []string{"go build -o server ./cmd/server"},
+				RunCommand:    "./server",
+			},
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	events := watcher.Subscribe()
+	if err := watcher.Start(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	defer watcher.Stop()
+
+	for event := range events {
+		if event.Event != nil {
+			log.Printf("%s: %s", event.Event.ActionID, event.Event.Kind)
+			continue
+		}
+		for id, err := range event.Cycle.Errors {
+			log.Printf("%s failed: %v", id, err)
+		}
+	}
+}
+
+func ExampleWatchUntil() {
+	config := revolver.Config{
+		Dir:        ".",
+		Interval:   time.Second,
+		RunOnStart: true,
+		Actions: []revolver.Action{
+			{BuildCommands: []string{"go test ./..."}},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := revolver.WatchUntil(ctx, config, func(result revolver.CycleResult) bool {
+		return result.Success()
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleWatchFunc() {
+	err := revolver.WatchFunc(".", []string{".git"}, time.Second, func(events []revolver.FileEvent) error {
+		for _, event := range events {
+			fmt.Printf("%s %s\n", event.Path, event.Kind)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
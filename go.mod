@@ -3,7 +3,10 @@ module github.com/kszab0/revolver
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/bmatcuk/doublestar v1.3.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/logrusorgru/aurora v0.0.0-20200102142835-e9ef32dff381
+	github.com/prometheus/client_golang v1.7.1
 	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,119 @@
+package revolver
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// BuildRecord is the record of a build of an action.
+type BuildRecord struct {
+	// Action is the ID of the action.
+	Action string
+	// Cycle is the number of the cycle the build ran in.
+	Cycle int
+	// Timestamp is the start of the build.
+	Timestamp time.Time
+	// Duration is the duration of the build.
+	Duration time.Duration
+	// TriggeredBy are the changed files matching the patterns of the action
+	// that triggered the build. It is empty for builds run on start.
+	TriggeredBy []string
+	// Stdout and Stderr are the standard output and error of the build
+	// commands, at most the last historyOutputSize bytes of each.
+	Stdout []byte
+	Stderr []byte
+	// Err is the error of the build, or nil if it succeeded.
+	Err error
+}
+
+// buildRecordJSON is the JSON form of a BuildRecord.
+type buildRecordJSON struct {
+	Action      string    `json:"action"`
+	Cycle       int       `json:"cycle"`
+	Timestamp   time.Time `json:"timestamp"`
+	DurationMs  int64     `json:"durationMs"`
+	TriggeredBy []string  `json:"triggeredBy,omitempty"`
+	Stdout      string    `json:"stdout"`
+	Stderr      string    `json:"stderr"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// MarshalJSON encodes the record with its duration in milliseconds, its
+// outputs as strings and its error as its message.
+func (record BuildRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(buildRecordJSON{
+		Action:      record.Action,
+		Cycle:       record.Cycle,
+		Timestamp:   record.Timestamp,
+		DurationMs:  record.Duration.Milliseconds(),
+		TriggeredBy: record.TriggeredBy,
+		Stdout:      string(record.Stdout),
+		Stderr:      string(record.Stderr),
+		Error:       errorMessage(record.Err),
+	})
+}
+
+// buildHistorySize is the number of builds per action kept by the build
+// history.
+const buildHistorySize = 50
+
+// historyOutputSize is the maximum number of bytes of each output stream of a
+// build kept by the build history.
+const historyOutputSize = 64 << 10
+
+// buildHistory is a ring buffer of the last buildHistorySize builds of an
+// action.
+type buildHistory struct {
+	records []BuildRecord
+	// next is the index of the record to overwrite once the buffer is full.
+	next int
+}
+
+// add adds the record, overwriting the oldest one if the buffer is full.
+func (h *buildHistory) add(record BuildRecord) {
+	if len(h.records) < buildHistorySize {
+		h.records = append(h.records, record)
+		return
+	}
+	h.records[h.next] = record
+	h.next = (h.next + 1) % buildHistorySize
+}
+
+// last returns the last n records, oldest first. n <= 0 means every record.
+func (h *buildHistory) last(n int) []BuildRecord {
+	ordered := append(append([]BuildRecord{}, h.records[h.next:]...), h.records[:h.next]...)
+	if n > 0 && n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// tailBuffer is an io.Writer keeping the last size bytes written to it. It is
+// safe for concurrent use.
+type tailBuffer struct {
+	mu   sync.Mutex
+	size int
+	buf  []byte
+}
+
+func newTailBuffer(size int) *tailBuffer {
+	return &tailBuffer{size: size}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.size {
+		b.buf = append([]byte(nil), b.buf[len(b.buf)-b.size:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns a copy of the bytes kept.
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf...)
+}
@@ -0,0 +1,152 @@
+package revolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildHistory(t *testing.T) {
+	type testCase struct {
+		added    int
+		n        int
+		expected []int
+	}
+	for name, tc := range map[string]testCase{
+		"empty": {
+			added:    0,
+			n:        0,
+			expected: []int{},
+		},
+		"not full": {
+			added:    3,
+			n:        0,
+			expected: []int{1, 2, 3},
+		},
+		"last n": {
+			added:    3,
+			n:        2,
+			expected: []int{2, 3},
+		},
+		"n over size": {
+			added:    3,
+			n:        10,
+			expected: []int{1, 2, 3},
+		},
+		"wrapped": {
+			added:    buildHistorySize + 2,
+			n:        3,
+			expected: []int{buildHistorySize, buildHistorySize + 1, buildHistorySize + 2},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			history := &buildHistory{}
+			for i := 1; i <= tc.added; i++ {
+				history.add(BuildRecord{Cycle: i})
+			}
+
+			cycles := []int{}
+			for _, record := range history.last(tc.n) {
+				cycles = append(cycles, record.Cycle)
+			}
+			if len(cycles) != len(tc.expected) {
+				t.Fatalf("Cycles should be %v; got: %v", tc.expected, cycles)
+			}
+			for i := range cycles {
+				if cycles[i] != tc.expected[i] {
+					t.Fatalf("Cycles should be %v; got: %v", tc.expected, cycles)
+				}
+			}
+		})
+	}
+
+	history := &buildHistory{}
+	for i := 1; i <= buildHistorySize*2; i++ {
+		history.add(BuildRecord{Cycle: i})
+	}
+	if records := history.last(0); len(records) != buildHistorySize || records[0].Cycle != buildHistorySize+1 {
+		t.Errorf("History should keep the last %d records", buildHistorySize)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	buf := newTailBuffer(8)
+	for _, chunk := range []string{"abc", "defgh", "ijk"} {
+		if n, err := buf.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("Write() should write %d bytes; got: %d, %v", len(chunk), n, err)
+		}
+	}
+	if expected := "defghijk"; string(buf.Bytes()) != expected {
+		t.Errorf("Bytes() should be %q; got: %q", expected, buf.Bytes())
+	}
+}
+
+func TestBuildRecordJSON(t *testing.T) {
+	record := BuildRecord{
+		Action:    "build",
+		Cycle:     2,
+		Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Duration:  1500 * time.Millisecond,
+		Stdout:    []byte("compiling\n"),
+		Stderr:    []byte("main.go:1: error\n"),
+		Err:       errors.New("exit status 1"),
+	}
+	b, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("Marshal() err should be nil; got: %v", err)
+	}
+	expected := `{"action":"build","cycle":2,"timestamp":"2020-01-02T03:04:05Z","durationMs":1500,"stdout":"compiling\n","stderr":"main.go:1: error\n","error":"exit status 1"}`
+	if string(b) != expected {
+		t.Errorf("JSON should be %s; got: %s", expected, b)
+	}
+}
+
+func TestWatcherBuildHistory(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:        dir,
+		Interval:   10 * time.Millisecond,
+		RunOnStart: true,
+		Shell:      "sh -c",
+		Logger:     NewLogger("text", ioutil.Discard),
+		Actions: []Action{
+			{Name: "build", BuildCommands: []string{"echo out; echo err >&2"}, LogPrefix: NoLogPrefix},
+		},
+	})
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	select {
+	case <-results:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Build should be finished")
+	}
+
+	history := watcher.BuildHistory("build", 1)
+	if len(history) != 1 {
+		t.Fatalf("History should have 1 build; got: %v", history)
+	}
+	if string(history[0].Stdout) != "out\n" || string(history[0].Stderr) != "err\n" {
+		t.Errorf("Outputs should be %q and %q; got: %q and %q", "out\n", "err\n", history[0].Stdout, history[0].Stderr)
+	}
+
+	b, err := watcher.BuildHistoryJSON("build")
+	if err != nil {
+		t.Fatalf("BuildHistoryJSON() err should be nil; got: %v", err)
+	}
+	if !strings.Contains(string(b), `"stdout":"out\n"`) {
+		t.Errorf("JSON should contain the output; got: %s", b)
+	}
+	if b, _ := watcher.BuildHistoryJSON("unknown"); string(b) != "[]" {
+		t.Errorf("JSON of an unknown action should be []; got: %s", b)
+	}
+}
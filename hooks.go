@@ -0,0 +1,101 @@
+package revolver
+
+import (
+	"context"
+	"fmt"
+)
+
+// PostBuildFunc is a function that is executed after the BuildFuncs, whether
+// they succeeded or not.
+type PostBuildFunc func(success bool) error
+
+// BuildWithHooks returns a BuildFunc that executes the pre builds, the builds
+// and the post builds in order. A failing pre build aborts the execution,
+// while the post builds run even if a build failed, like deferred calls do,
+// and receive whether the builds succeeded. It returns the error of the
+// builds, or else the error of the first failing post build.
+func BuildWithHooks(pre, builds []BuildFunc, post []PostBuildFunc) BuildFunc {
+	return func() error {
+		for _, build := range pre {
+			if err := build(); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		for _, build := range builds {
+			if err = build(); err != nil {
+				break
+			}
+		}
+
+		success := err == nil
+		for _, build := range post {
+			if postErr := build(success); postErr != nil {
+				if err == nil {
+					err = postErr
+				}
+				break
+			}
+		}
+		return err
+	}
+}
+
+// postBuildCommand returns a PostBuildFunc that executes a command with
+// arguments, configured by opts. The command receives whether the builds
+// succeeded in REVOLVER_BUILD_SUCCESS.
+func postBuildCommand(opts commandOptions, command string, args ...string) PostBuildFunc {
+	return func(success bool) error {
+		opts := opts
+		opts.Env = append(opts.Env[:len(opts.Env):len(opts.Env)], fmt.Sprintf("REVOLVER_BUILD_SUCCESS=%t", success))
+		return buildCommand(opts, command, args...)()
+	}
+}
+
+// withStopHooks returns a RunFunc like run, whose stop function runs the
+// preStop hook before stopping and the postStop hook after stopping, with
+// opts. The errors of the hooks are logged, they don't prevent the stop.
+func withStopHooks(run RunFunc, opts commandOptions, preStop, postStop *hookCommand, logger Logger) RunFunc {
+	return func() (func(), error) {
+		stop, err := run()
+		if err != nil || stop == nil {
+			return stop, err
+		}
+		return func() {
+			if err := preStop.run(opts); err != nil {
+				logger.Error(err)
+			}
+			stop()
+			if err := postStop.run(opts); err != nil {
+				logger.Error(err)
+			}
+		}, nil
+	}
+}
+
+// run executes the hook with opts and waits for it to exit, but at most for
+// opts.Timeout, or defaultStopTimeout if it's zero, after which the hook is
+// killed. A nil hook does nothing.
+func (h *hookCommand) run(opts commandOptions) error {
+	if h == nil {
+		return nil
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	opts, flush := opts.withPrefix()
+	defer flush()
+	cmd := opts.command(context.Background(), h.Command, h.Args...)
+	if err := runProcessGroup(ctx, cmd); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %v", timeout)
+		}
+		return fmt.Errorf("Error executing hook: \"%s\": %w", h.Command, err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package revolver
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildWithHooks(t *testing.T) {
+	type testCase struct {
+		preErr   error
+		buildErr error
+		postErr  error
+		calls    []string
+		err      error
+	}
+	preErr, buildErr, postErr := errors.New("pre"), errors.New("build"), errors.New("post")
+	for name, tc := range map[string]testCase{
+		"success": {
+			calls: []string{"pre", "build", "post true", "post true"},
+		},
+		"pre build fails": {
+			preErr: preErr,
+			calls:  []string{"pre"},
+			err:    preErr,
+		},
+		"build fails": {
+			buildErr: buildErr,
+			calls:    []string{"pre", "build", "post false", "post false"},
+			err:      buildErr,
+		},
+		"post build fails": {
+			postErr: postErr,
+			calls:   []string{"pre", "build", "post true"},
+			err:     postErr,
+		},
+		"build and post build fail": {
+			buildErr: buildErr,
+			postErr:  postErr,
+			calls:    []string{"pre", "build", "post false"},
+			err:      buildErr,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			calls := []string{}
+			build := BuildWithHooks(
+				[]BuildFunc{func() error {
+					calls = append(calls, "pre")
+					return tc.preErr
+				}},
+				[]BuildFunc{func() error {
+					calls = append(calls, "build")
+					return tc.buildErr
+				}},
+				[]PostBuildFunc{
+					func(success bool) error {
+						if success {
+							calls = append(calls, "post true")
+						} else {
+							calls = append(calls, "post false")
+						}
+						return tc.postErr
+					},
+					func(success bool) error {
+						if success {
+							calls = append(calls, "post true")
+						} else {
+							calls = append(calls, "post false")
+						}
+						return nil
+					},
+				},
+			)
+
+			if err := build(); err != tc.err {
+				t.Errorf("Build err should be %v; got: %v", tc.err, err)
+			}
+			if !reflect.DeepEqual(calls, tc.calls) {
+				t.Errorf("Calls should be %v; got: %v", tc.calls, calls)
+			}
+		})
+	}
+}
+
+func TestActionPrePostBuild(t *testing.T) {
+	type testCase struct {
+		build    string
+		expected string
+		err      bool
+	}
+	for name, tc := range map[string]testCase{
+		"success": {
+			build:    "echo build >> log",
+			expected: "pre\nbuild\npost true\n",
+		},
+		"failure": {
+			build:    "exit 1",
+			expected: "pre\npost false\n",
+			err:      true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+
+			actions, err := parseActions(Config{
+				Dir:   dir,
+				Shell: "sh -c",
+				Actions: []Action{{
+					WorkingDir:    dir,
+					PreBuild:      []string{"echo pre >> log"},
+					BuildCommands: []string{tc.build},
+					PostBuild:     []string{"echo post $REVOLVER_BUILD_SUCCESS >> log"},
+				}},
+			})
+			if err != nil {
+				t.Fatalf("parseActions() err should be nil; got: %v", err)
+			}
+			if _, err := Run(actions[0].BuildFuncs, nil); (err != nil) != tc.err {
+				t.Errorf("Run() err should be %v; got: %v", tc.err, err)
+			}
+
+			log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+			if err != nil {
+				t.Fatalf("Cannot read log: %v", err)
+			}
+			if string(log) != tc.expected {
+				t.Errorf("Log should be %q; got: %q", tc.expected, log)
+			}
+		})
+	}
+}
+
+func TestActionStopHooks(t *testing.T) {
+	type testCase struct {
+		preStop  string
+		expected string
+		err      string
+	}
+	for name, tc := range map[string]testCase{
+		"success": {
+			preStop:  "echo pre >> log",
+			expected: "run\npre\npost\n",
+		},
+		"pre stop fails": {
+			preStop:  "echo pre >> log; exit 1",
+			expected: "run\npre\npost\n",
+			err:      "exit status 1",
+		},
+		"pre stop times out": {
+			preStop:  "echo pre >> log; sleep 10",
+			expected: "run\npre\npost\n",
+			err:      "timed out after 200ms",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+
+			logger := &recordLogger{}
+			actions, err := parseActions(Config{
+				Dir:    dir,
+				Shell:  "sh -c",
+				Logger: logger,
+				Actions: []Action{{
+					WorkingDir:  dir,
+					RunCommand:  "echo run >> log; exec sleep 5",
+					PreStop:     tc.preStop,
+					PostStop:    "echo post >> log",
+					StopTimeout: 200 * time.Millisecond,
+				}},
+			})
+			if err != nil {
+				t.Fatalf("parseActions() err should be nil; got: %v", err)
+			}
+			stop, err := Run(nil, actions[0].RunFunc)
+			if err != nil {
+				t.Fatalf("Run() err should be nil; got: %v", err)
+			}
+			time.Sleep(100 * time.Millisecond)
+
+			start := time.Now()
+			stop()
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Run command should be stopped; took: %v", elapsed)
+			}
+
+			log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+			if err != nil {
+				t.Fatalf("Cannot read log: %v", err)
+			}
+			if string(log) != tc.expected {
+				t.Errorf("Log should be %q; got: %q", tc.expected, log)
+			}
+			logged := strings.Join(logger.lines, "\n")
+			if tc.err != "" && !strings.Contains(logged, tc.err) {
+				t.Errorf("Hook error %q should be logged; got: %q", tc.err, logged)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package revolver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// IgnoreFile is the name of the file in the root of a watched dir that lists
+// the patterns of the files and directories to ignore, like .gitignore does.
+const IgnoreFile = ".revolverignore"
+
+// ParseIgnoreFile reads the glob patterns from the ignore file at path, one
+// pattern per line. Empty lines and lines beginning with # are skipped.
+func ParseIgnoreFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening ignore file: %w", err)
+	}
+	defer file.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading ignore file: %w", err)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,62 @@
+package revolver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIgnoreFile(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	path := filepath.Join(dir, IgnoreFile)
+	content := "# generated files\n*.gen.go\n\n  node_modules  \n# dist/**\ntmp/**\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Cannot write ignore file: %v", err)
+	}
+
+	patterns, err := ParseIgnoreFile(path)
+	if err != nil {
+		t.Fatalf("ParseIgnoreFile() err should be nil; got: %v", err)
+	}
+	expected := []string{"*.gen.go", "node_modules", "tmp/**"}
+	if len(patterns) != len(expected) {
+		t.Fatalf("ParseIgnoreFile() should return: %v; got: %v", expected, patterns)
+	}
+	for i := range patterns {
+		if patterns[i] != expected[i] {
+			t.Errorf("ParseIgnoreFile() should return: %v; got: %v", expected, patterns)
+		}
+	}
+
+	if _, err := ParseIgnoreFile(filepath.Join(dir, "not_exists")); err == nil {
+		t.Errorf("ParseIgnoreFile() err should not be nil for a missing file")
+	}
+}
+
+func TestDetectIgnoreFile(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	if err := os.MkdirAll(filepath.Join(dir, "node_modules"), 0700); err != nil {
+		t.Fatalf("Cannot create dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, IgnoreFile), []byte("node_modules\n*.gen.go\n"), 0600); err != nil {
+		t.Fatalf("Cannot write ignore file: %v", err)
+	}
+
+	detect := Detect(dir, nil)
+	detect()
+
+	for _, name := range []string{"main.go", "api.gen.go", "node_modules/lib.js"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+
+	if changes, expected := detect(), []string{"main.go"}; !equals(expected, changes) {
+		t.Errorf("Detect() should return: %v; got: %v", expected, changes)
+	}
+}
@@ -0,0 +1,75 @@
+package revolver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+)
+
+// DefaultFileTypeIndicators are the indicators of the changed files by their
+// extension.
+var DefaultFileTypeIndicators = map[string]string{
+	".go":   "Go",
+	".ts":   "TS",
+	".tsx":  "TS",
+	".js":   "JS",
+	".jsx":  "JS",
+	".yaml": "YAML",
+	".yml":  "YAML",
+	".json": "JSON",
+	".css":  "CSS",
+	".html": "HTML",
+	".md":   "MD",
+}
+
+// indicatorColors are the colors of the known indicators.
+var indicatorColors = map[string]func(arg interface{}) aurora.Value{
+	"Go":   aurora.Blue,
+	"TS":   aurora.Cyan,
+	"JS":   aurora.Yellow,
+	"YAML": aurora.Yellow,
+	"JSON": aurora.Green,
+	"CSS":  aurora.Magenta,
+	"HTML": aurora.Red,
+}
+
+// fileTypeIndicator returns the indicator of the file by its extension. The
+// custom indicators override the DefaultFileTypeIndicators. It returns an
+// empty string for unknown extensions.
+func fileTypeIndicator(custom map[string]string, file string) string {
+	ext := strings.ToLower(filepath.Ext(file))
+	if indicator, ok := custom[ext]; ok {
+		return indicator
+	}
+	return DefaultFileTypeIndicators[ext]
+}
+
+// indicateFileTypes returns the files prefixed with their indicators, like
+// "[Go] main.go". If color is set, the known indicators are colorized.
+func indicateFileTypes(custom map[string]string, files []string, color bool) string {
+	indicated := make([]string, len(files))
+	for i, file := range files {
+		indicator := fileTypeIndicator(custom, file)
+		if indicator == "" {
+			indicated[i] = file
+			continue
+		}
+		badge := "[" + indicator + "]"
+		if colorize, ok := indicatorColors[indicator]; ok && color {
+			badge = colorize(badge).String()
+		}
+		indicated[i] = badge + " " + file
+	}
+	return strings.Join(indicated, ", ")
+}
+
+// isTerminal reports whether the file is a terminal.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,50 @@
+package revolver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFileTypeIndicator(t *testing.T) {
+	type testCase struct {
+		custom   map[string]string
+		file     string
+		expected string
+	}
+
+	testCases := map[string]testCase{
+		"go":              {file: "cmd/main.go", expected: "Go"},
+		"typescript":      {file: "src/app.ts", expected: "TS"},
+		"tsx":             {file: "src/App.tsx", expected: "TS"},
+		"yaml":            {file: "config.yaml", expected: "YAML"},
+		"yml":             {file: "revolver.yml", expected: "YAML"},
+		"upper case":      {file: "README.MD", expected: "MD"},
+		"unknown":         {file: "Makefile", expected: ""},
+		"custom":          {custom: map[string]string{".proto": "PB"}, file: "api.proto", expected: "PB"},
+		"custom override": {custom: map[string]string{".go": "🐹"}, file: "main.go", expected: "🐹"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := fileTypeIndicator(tc.custom, tc.file); got != tc.expected {
+				t.Errorf("fileTypeIndicator(%q) should be %q; got: %q", tc.file, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestIndicateFileTypes(t *testing.T) {
+	files := []string{"main.go", "Makefile", "app.ts"}
+
+	if got, expected := indicateFileTypes(nil, files, false), "[Go] main.go, Makefile, [TS] app.ts"; got != expected {
+		t.Errorf("indicateFileTypes() should be %q; got: %q", expected, got)
+	}
+
+	colored := indicateFileTypes(nil, files, true)
+	if !strings.Contains(colored, "\x1b[") {
+		t.Errorf("indicateFileTypes() with color should contain ANSI codes; got: %q", colored)
+	}
+	if !strings.Contains(colored, "main.go") || !strings.Contains(colored, "Makefile") {
+		t.Errorf("indicateFileTypes() with color should contain the files; got: %q", colored)
+	}
+}
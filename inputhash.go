@@ -0,0 +1,77 @@
+package revolver
+
+import (
+	"crypto/sha256"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// inputHash holds the hash of the inputs of the last successful build of an
+// action.
+type inputHash struct {
+	mu    sync.Mutex
+	hash  [sha256.Size]byte
+	valid bool
+}
+
+// matches reports whether hash is the hash of the last successful build.
+func (h *inputHash) matches(hash [sha256.Size]byte) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.valid && h.hash == hash
+}
+
+// Set records hash as the hash of the last successful build.
+func (h *inputHash) Set(hash [sha256.Size]byte) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hash = hash
+	h.valid = true
+}
+
+// Reset invalidates the hash of the last successful build, so the next build
+// isn't skipped.
+func (h *inputHash) Reset() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.valid = false
+}
+
+// hashInputs returns a hash of the paths and the content of the files. The
+// paths are relative to root unless they're absolute. The hash doesn't depend
+// on the order of the files. A file that can't be read, e.g. a deleted file,
+// is hashed by its path only.
+func hashInputs(root string, files []string) [sha256.Size]byte {
+	sorted := append([]string{}, files...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for _, file := range sorted {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		h.Write([]byte(file))
+		h.Write([]byte{0})
+		if content, err := hashFile(path); err == nil {
+			h.Write([]byte{1})
+			h.Write(content[:])
+		} else {
+			h.Write([]byte{0})
+		}
+	}
+
+	var hash [sha256.Size]byte
+	copy(hash[:], h.Sum(nil))
+	return hash
+}
@@ -0,0 +1,132 @@
+package revolver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashInputs(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+	write("a.go", "a")
+	write("b.go", "b")
+	hash := hashInputs(dir, []string{"a.go", "b.go"})
+
+	if hashInputs(dir, []string{"b.go", "a.go"}) != hash {
+		t.Errorf("hashInputs() should not depend on the order of the files")
+	}
+	write("a.go", "a")
+	if hashInputs(dir, []string{"a.go", "b.go"}) != hash {
+		t.Errorf("hashInputs() should not change if the content is the same")
+	}
+	write("a.go", "changed")
+	if hashInputs(dir, []string{"a.go", "b.go"}) == hash {
+		t.Errorf("hashInputs() should change if the content changes")
+	}
+	if hashInputs(dir, []string{"a.go"}) == hash {
+		t.Errorf("hashInputs() should change if the files change")
+	}
+}
+
+func TestRunnerInputHash(t *testing.T) {
+	type testCase struct {
+		build  string
+		run    string
+		change string
+		builds int
+	}
+	for name, tc := range map[string]testCase{
+		"unchanged inputs": {
+			build:  "true",
+			builds: 1,
+		},
+		"failed build": {
+			build:  "false",
+			builds: 2,
+		},
+		"run exited": {
+			build:  "true",
+			run:    "true",
+			builds: 2,
+		},
+		"changed inputs": {
+			build:  "true",
+			change: "package lib",
+			builds: 2,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			file := filepath.Join(dir, "main.go")
+			if err := ioutil.WriteFile(file, []byte("package main"), 0600); err != nil {
+				t.Fatalf("Cannot write file: %v", err)
+			}
+
+			config := Config{
+				Dir:       dir,
+				InputHash: true,
+				Actions: []Action{
+					{Name: "build", BuildCommands: []string{tc.build}, RunCommand: tc.run},
+				},
+			}
+			r := newRunner(config)
+			r.logger = NewLogger("text", ioutil.Discard)
+			builds := 0
+			r.onBuild = func(BuildRecord) { builds++ }
+			defer r.stopAll()
+
+			a := mustParseActions(t, config)[0]
+			a.TriggeredBy = []string{"main.go"}
+			r.runAction(a, 1)
+			if tc.run != "" {
+				time.Sleep(100 * time.Millisecond)
+			}
+			if tc.change != "" {
+				if err := ioutil.WriteFile(file, []byte(tc.change), 0600); err != nil {
+					t.Fatalf("Cannot write file: %v", err)
+				}
+			}
+			r.runAction(a, 2)
+
+			if builds != tc.builds {
+				t.Errorf("Action should be built %d times; got: %d", tc.builds, builds)
+			}
+		})
+	}
+}
+
+func TestRunCycleInputsUnchanged(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+
+	config := Config{
+		Dir:       dir,
+		InputHash: true,
+		Actions: []Action{
+			{Name: "build", BuildCommands: []string{"true"}},
+		},
+	}
+	r := newRunner(config)
+	r.logger = NewLogger("text", ioutil.Discard)
+
+	a := mustParseActions(t, config)[0]
+	a.TriggeredBy = []string{"main.go"}
+	if result := r.runCycle([]action{a}, 1); len(result.Actions) != 1 {
+		t.Fatalf("First build should be in the result; got: %+v", result)
+	}
+	if result := r.runCycle([]action{a}, 2); len(result.Actions) != 0 {
+		t.Errorf("Skipped build should not be in the result; got: %+v", result)
+	}
+}
@@ -0,0 +1,27 @@
+package revolver
+
+import (
+	"math/rand"
+	"os"
+	"time"
+)
+
+// newJitterRand returns the source of the poll interval jitter. It is seeded
+// with the process ID, so the instances sharing a filesystem poll at
+// different times.
+func newJitterRand() *rand.Rand {
+	return rand.New(rand.NewSource(int64(os.Getpid())))
+}
+
+// jitterInterval returns interval varied randomly by at most jitter in
+// either direction. The result is never negative.
+func jitterInterval(rnd *rand.Rand, interval, jitter time.Duration) time.Duration {
+	if jitter <= 0 {
+		return interval
+	}
+	d := interval - jitter + time.Duration(rnd.Int63n(int64(2*jitter)+1))
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,85 @@
+package revolver
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestJitterInterval(t *testing.T) {
+	type testCase struct {
+		interval, jitter time.Duration
+		min, max         time.Duration
+	}
+	for name, tc := range map[string]testCase{
+		"no jitter": {
+			interval: time.Second,
+			min:      time.Second,
+			max:      time.Second,
+		},
+		"jitter": {
+			interval: time.Second,
+			jitter:   100 * time.Millisecond,
+			min:      900 * time.Millisecond,
+			max:      1100 * time.Millisecond,
+		},
+		"jitter larger than interval": {
+			interval: 100 * time.Millisecond,
+			jitter:   time.Second,
+			min:      0,
+			max:      1100 * time.Millisecond,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(1))
+			for i := 0; i < 100; i++ {
+				if d := jitterInterval(rnd, tc.interval, tc.jitter); d < tc.min || d > tc.max {
+					t.Fatalf("jitterInterval() should be between %v and %v; got: %v", tc.min, tc.max, d)
+				}
+			}
+		})
+	}
+}
+
+func TestWatcherIntervalJitter(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:            dir,
+		Interval:       time.Second,
+		IntervalJitter: 200 * time.Millisecond,
+		Actions: []Action{
+			{BuildCommands: []string{"true"}},
+		},
+	})
+	sleeps := make(chan time.Duration, 10)
+	watcher.after = func(d time.Duration) <-chan time.Time {
+		select {
+		case sleeps <- d:
+		default:
+		}
+		return time.After(time.Millisecond)
+	}
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	distinct := make(map[time.Duration]bool)
+	for i := 0; i < cap(sleeps); i++ {
+		select {
+		case d := <-sleeps:
+			if d < 800*time.Millisecond || d > 1200*time.Millisecond {
+				t.Errorf("Poll interval should be within 1s±200ms; got: %v", d)
+			}
+			distinct[d] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Watcher should poll")
+		}
+	}
+	if len(distinct) < 2 {
+		t.Errorf("Poll intervals should vary; got: %v", distinct)
+	}
+}
@@ -0,0 +1,41 @@
+package revolver
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// lockPollInterval is the interval of the attempts to lock a mutex file held
+// by another process.
+const lockPollInterval = 50 * time.Millisecond
+
+// lockMutexFile locks the file at path, creating it if it doesn't exist,
+// waiting at most timeout for other processes to unlock it. Zero timeout
+// means waiting forever. It returns the function unlocking the file.
+func lockMutexFile(path string, timeout time.Duration) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening mutex file: %s: %w", path, err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := lockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("Error locking mutex file: %s: %w", path, err)
+		}
+		if locked {
+			return func() {
+				unlockFile(f)
+				f.Close()
+			}, nil
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("Error locking mutex file: %s: timed out after %v", path, timeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
@@ -0,0 +1,97 @@
+package revolver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockMutexFile(t *testing.T) {
+	type testCase struct {
+		timeout time.Duration
+		err     bool
+	}
+	for name, tc := range map[string]testCase{
+		"waits for unlock": {
+			timeout: 5 * time.Second,
+		},
+		"no timeout": {
+			timeout: 0,
+		},
+		"timeout": {
+			timeout: 50 * time.Millisecond,
+			err:     true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			path := filepath.Join(dir, "revolver.lock")
+
+			unlock, err := lockMutexFile(path, 0)
+			if err != nil {
+				t.Fatalf("lockMutexFile() err should be nil; got: %v", err)
+			}
+			locked := make(chan time.Time, 1)
+			go func() {
+				time.Sleep(200 * time.Millisecond)
+				locked <- time.Now()
+				unlock()
+			}()
+
+			unlockNext, err := lockMutexFile(path, tc.timeout)
+			if tc.err {
+				if err == nil {
+					t.Errorf("lockMutexFile() err should not be nil")
+					unlockNext()
+				}
+				<-locked
+				return
+			}
+			if err != nil {
+				t.Fatalf("lockMutexFile() err should be nil; got: %v", err)
+			}
+			defer unlockNext()
+			if released := <-locked; time.Now().Before(released) {
+				t.Errorf("lockMutexFile() should wait for the lock to be released")
+			}
+		})
+	}
+}
+
+func TestRunnerMutexFile(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	log := filepath.Join(dir, "log")
+
+	// Two runners, like two revolver instances, build at the same time.
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		config := Config{
+			Shell:     "sh -c",
+			MutexFile: filepath.Join(dir, "revolver.lock"),
+			Actions: []Action{
+				{Name: name, BuildCommands: []string{"echo start >> " + log + "; sleep 0.1; echo end >> " + log}},
+			},
+		}
+		r := newRunner(config)
+		r.logger = NewLogger("text", ioutil.Discard)
+		actions := mustParseActions(t, config)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result := r.runCycle(actions, 1); !result.Success() {
+				t.Errorf("Cycle should succeed; got: %v", result.Errors)
+			}
+		}()
+	}
+	wg.Wait()
+
+	b, _ := ioutil.ReadFile(log)
+	if expected := "start\nend\nstart\nend\n"; string(b) != expected {
+		t.Errorf("Builds should not overlap: %q; got: %q", expected, b)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package revolver
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile tries to lock the file exclusively without blocking. It reports
+// whether the file is locked.
+func lockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// unlockFile unlocks the file locked by lockFile.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,147 @@
+package revolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+)
+
+// Logger logs the messages of revolver.
+type Logger interface {
+	Info(format string, args ...interface{})
+	Success(format string, args ...interface{})
+	Error(err error)
+}
+
+// actionLogger is implemented by Loggers that can record the action a message
+// belongs to.
+type actionLogger interface {
+	WithAction(id string) Logger
+}
+
+// loggerFor returns a Logger for the messages of the action with the given
+// id. If logger can't record the action, the messages are prefixed with the
+// id instead.
+func loggerFor(logger Logger, id string) Logger {
+	if l, ok := logger.(actionLogger); ok {
+		return l.WithAction(id)
+	}
+	return prefixLogger{Logger: logger, prefix: fmt.Sprintf("[%s] ", id)}
+}
+
+type prefixLogger struct {
+	Logger
+	prefix string
+}
+
+func (l prefixLogger) Info(format string, args ...interface{}) {
+	l.Logger.Info(l.prefix+format, args...)
+}
+
+func (l prefixLogger) Success(format string, args ...interface{}) {
+	l.Logger.Success(l.prefix+format, args...)
+}
+
+func (l prefixLogger) Error(err error) {
+	l.Logger.Error(fmt.Errorf("%s%w", l.prefix, err))
+}
+
+// NewLogger returns a Logger writing to w in the given format: "text" (the
+// default) writes colorized lines, "json" writes a JSON object per line.
+func NewLogger(format string, w io.Writer) Logger {
+	if format == "json" {
+		return &jsonLogger{w: w, mu: &sync.Mutex{}}
+	}
+	return &textLogger{w: w, mu: &sync.Mutex{}}
+}
+
+// DefaultLogger returns the Logger revolver uses by default, writing colored
+// text messages to w.
+func DefaultLogger(w io.Writer) Logger {
+	return NewLogger("text", w)
+}
+
+func validLogFormat(format string) bool {
+	return format == "" || format == "text" || format == "json"
+}
+
+type textLogger struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	action string
+}
+
+func (l *textLogger) WithAction(id string) Logger {
+	return &textLogger{w: l.w, mu: l.mu, action: id}
+}
+
+func (l *textLogger) println(msg interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, msg)
+}
+
+func (l *textLogger) prefix(msg string) string {
+	if l.action == "" {
+		return msg
+	}
+	return fmt.Sprintf("[%s] %s", l.action, msg)
+}
+
+func (l *textLogger) Info(format string, args ...interface{}) {
+	l.println(aurora.Yellow(l.prefix(fmt.Sprintf(format, args...))))
+}
+
+func (l *textLogger) Success(format string, args ...interface{}) {
+	l.println(aurora.Green(l.prefix(fmt.Sprintf(format, args...))))
+}
+
+func (l *textLogger) Error(err error) {
+	l.println(aurora.Red(l.prefix(err.Error())))
+}
+
+type jsonLogger struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	action string
+}
+
+type jsonLine struct {
+	Level  string `json:"level"`
+	Action string `json:"action,omitempty"`
+	Msg    string `json:"msg"`
+	TS     string `json:"ts"`
+}
+
+func (l *jsonLogger) WithAction(id string) Logger {
+	return &jsonLogger{w: l.w, mu: l.mu, action: id}
+}
+
+func (l *jsonLogger) log(level, msg string) {
+	line, _ := json.Marshal(jsonLine{
+		Level:  level,
+		Action: l.action,
+		Msg:    msg,
+		TS:     time.Now().Format(time.RFC3339Nano),
+	})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.w, string(line))
+}
+
+func (l *jsonLogger) Info(format string, args ...interface{}) {
+	l.log("info", fmt.Sprintf(format, args...))
+}
+
+func (l *jsonLogger) Success(format string, args ...interface{}) {
+	l.log("success", fmt.Sprintf(format, args...))
+}
+
+func (l *jsonLogger) Error(err error) {
+	l.log("error", err.Error())
+}
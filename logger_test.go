@@ -0,0 +1,160 @@
+package revolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewLogger(t *testing.T) {
+	type testCase struct {
+		format string
+		log    func(l Logger)
+		want   string
+	}
+
+	testCases := map[string]testCase{
+		"text info": {
+			format: "text",
+			log:    func(l Logger) { l.Info("Watching %d dirs", 2) },
+			want:   "Watching 2 dirs",
+		},
+		"text action": {
+			format: "",
+			log:    func(l Logger) { loggerFor(l, "build").Success("Built successfully.") },
+			want:   "[build] Built successfully.",
+		},
+		"text error": {
+			format: "text",
+			log:    func(l Logger) { loggerFor(l, "build").Error(errors.New("exit status 1")) },
+			want:   "[build] exit status 1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.log(NewLogger(tc.format, &buf))
+			if got := buf.String(); !strings.Contains(got, tc.want) {
+				t.Errorf("want output containing %q; got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	type testCase struct {
+		log        func(l Logger)
+		wantLevel  string
+		wantAction string
+		wantMsg    string
+	}
+
+	testCases := map[string]testCase{
+		"info": {
+			log:       func(l Logger) { l.Info("Watching %d dirs", 2) },
+			wantLevel: "info",
+			wantMsg:   "Watching 2 dirs",
+		},
+		"success": {
+			log:        func(l Logger) { loggerFor(l, "build").Success("Built successfully.") },
+			wantLevel:  "success",
+			wantAction: "build",
+			wantMsg:    "Built successfully.",
+		},
+		"error": {
+			log:        func(l Logger) { loggerFor(l, "build").Error(errors.New("exit status 1")) },
+			wantLevel:  "error",
+			wantAction: "build",
+			wantMsg:    "exit status 1",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.log(NewLogger("json", &buf))
+
+			var line map[string]string
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("want valid JSON line; got %q: %v", buf.String(), err)
+			}
+			if line["level"] != tc.wantLevel {
+				t.Errorf("want level %q; got %q", tc.wantLevel, line["level"])
+			}
+			if line["action"] != tc.wantAction {
+				t.Errorf("want action %q; got %q", tc.wantAction, line["action"])
+			}
+			if line["msg"] != tc.wantMsg {
+				t.Errorf("want msg %q; got %q", tc.wantMsg, line["msg"])
+			}
+			if line["ts"] == "" {
+				t.Error("want ts to be set")
+			}
+		})
+	}
+}
+
+type recordLogger struct {
+	lines []string
+}
+
+func (l *recordLogger) Info(format string, args ...interface{}) {
+	l.lines = append(l.lines, "info: "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Success(format string, args ...interface{}) {
+	l.lines = append(l.lines, "success: "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Error(err error) {
+	l.lines = append(l.lines, "error: "+err.Error())
+}
+
+func TestLoggerForPrefix(t *testing.T) {
+	l := &recordLogger{}
+	logger := loggerFor(l, "build")
+	logger.Info("Stopping...")
+	logger.Error(errors.New("exit status 1"))
+
+	want := []string{"info: [build] Stopping...", "error: [build] exit status 1"}
+	if !reflect.DeepEqual(l.lines, want) {
+		t.Errorf("want %v; got %v", want, l.lines)
+	}
+}
+
+func TestConfigLogger(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	var buf bytes.Buffer
+	watcher := newTestWatcher(t, Config{
+		Dir:        dir,
+		Interval:   10 * time.Millisecond,
+		RunOnStart: true,
+		Logger:     DefaultLogger(&buf),
+		Actions: []Action{
+			{Name: "build", BuildCommands: []string{"true"}},
+		},
+	})
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	select {
+	case <-results:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watcher should run a cycle")
+	}
+	watcher.Stop()
+
+	if !strings.Contains(buf.String(), "[build] Built in") {
+		t.Errorf("Config.Logger should receive the messages; got: %q", buf.String())
+	}
+}
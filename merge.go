@@ -0,0 +1,105 @@
+package revolver
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+)
+
+// MergeConfigs returns the base config extended by the override, e.g. a shared
+// team config by the config of a project. Scalar fields take the value of the
+// override if it's not zero, slices are appended and maps are merged with the
+// values of the override winning. Actions with the same name are merged with
+// the same rules, the other actions of the override are appended.
+func MergeConfigs(base, override Config) Config {
+	merged := base
+	mergeFields(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(override), "Actions")
+
+	merged.Actions = append([]Action(nil), base.Actions...)
+	for _, action := range override.Actions {
+		i := -1
+		if action.Name != "" {
+			for j, a := range merged.Actions {
+				if a.Name == action.Name {
+					i = j
+					break
+				}
+			}
+		}
+		if i < 0 {
+			merged.Actions = append(merged.Actions, action)
+			continue
+		}
+		mergeFields(reflect.ValueOf(&merged.Actions[i]).Elem(), reflect.ValueOf(action))
+	}
+	return merged
+}
+
+// mergeFields merges the fields of the override struct into dst, skipping
+// the fields with the given names.
+func mergeFields(dst, override reflect.Value, skip ...string) {
+	for i := 0; i < dst.NumField(); i++ {
+		if stringArr(skip).has(dst.Type().Field(i).Name) {
+			continue
+		}
+		field, value := dst.Field(i), override.Field(i)
+		if value.IsZero() {
+			continue
+		}
+		switch field.Kind() {
+		case reflect.Slice:
+			merged := reflect.MakeSlice(field.Type(), 0, field.Len()+value.Len())
+			merged = reflect.AppendSlice(merged, field)
+			field.Set(reflect.AppendSlice(merged, value))
+		case reflect.Map:
+			merged := reflect.MakeMap(field.Type())
+			for _, m := range []reflect.Value{field, value} {
+				iter := m.MapRange()
+				for iter.Next() {
+					merged.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			field.Set(merged)
+		default:
+			field.Set(value)
+		}
+	}
+}
+
+// parseConfigFile parses the config file like ParseConfigFile does, following
+// its extends. The visiting files are used to detect circular extends.
+func parseConfigFile(path string, visiting map[string]bool) (*Config, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if visiting[abs] {
+		return nil, fmt.Errorf("Error parsing config: circular extends: %s", path)
+	}
+	visiting[abs] = true
+	defer delete(visiting, abs)
+
+	config, err := parseConfigFileFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Extends) == 0 {
+		return config, nil
+	}
+
+	var merged Config
+	for _, base := range config.Extends {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(path), base)
+		}
+		baseConfig, err := parseConfigFile(base, visiting)
+		if err != nil {
+			return nil, err
+		}
+		merged = MergeConfigs(merged, *baseConfig)
+	}
+	merged = MergeConfigs(merged, *config)
+	merged.Extends = config.Extends
+	merged.File = path
+	return &merged, nil
+}
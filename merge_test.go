@@ -0,0 +1,221 @@
+package revolver
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMergeConfigs(t *testing.T) {
+	type testCase struct {
+		base     Config
+		override Config
+		expected Config
+	}
+	for name, tc := range map[string]testCase{
+		"empty": {
+			base:     Config{},
+			override: Config{},
+			expected: Config{},
+		},
+		"empty base": {
+			base:     Config{},
+			override: Config{Dir: "src", Interval: time.Second, ExcludeDirs: []string{".git"}},
+			expected: Config{Dir: "src", Interval: time.Second, ExcludeDirs: []string{".git"}},
+		},
+		"empty override": {
+			base:     Config{Dir: "src", Interval: time.Second, ExcludeDirs: []string{".git"}},
+			override: Config{},
+			expected: Config{Dir: "src", Interval: time.Second, ExcludeDirs: []string{".git"}},
+		},
+		"conflicting scalars": {
+			base:     Config{Dir: "src", Interval: time.Second, Shell: "sh -c", RunOnStart: true},
+			override: Config{Dir: "app", Shell: "bash -c"},
+			expected: Config{Dir: "app", Interval: time.Second, Shell: "bash -c", RunOnStart: true},
+		},
+		"zero override keeps base": {
+			base:     Config{Interval: time.Second, CircuitBreaker: CircuitBreaker{Threshold: 3}},
+			override: Config{Interval: 0, CircuitBreaker: CircuitBreaker{}},
+			expected: Config{Interval: time.Second, CircuitBreaker: CircuitBreaker{Threshold: 3}},
+		},
+		"slices appended": {
+			base:     Config{ExcludeDirs: []string{".git"}, Dirs: []string{"a"}},
+			override: Config{ExcludeDirs: []string{"node_modules", ".git"}},
+			expected: Config{ExcludeDirs: []string{".git", "node_modules", ".git"}, Dirs: []string{"a"}},
+		},
+		"maps merged": {
+			base:     Config{Env: map[string]string{"A": "base", "B": "base"}},
+			override: Config{Env: map[string]string{"B": "override", "C": "override"}},
+			expected: Config{Env: map[string]string{"A": "base", "B": "override", "C": "override"}},
+		},
+		"actions appended": {
+			base: Config{Actions: []Action{
+				{Name: "build", BuildCommands: []string{"go build"}},
+			}},
+			override: Config{Actions: []Action{
+				{Name: "lint", BuildCommands: []string{"go vet"}},
+			}},
+			expected: Config{Actions: []Action{
+				{Name: "build", BuildCommands: []string{"go build"}},
+				{Name: "lint", BuildCommands: []string{"go vet"}},
+			}},
+		},
+		"actions merged by name": {
+			base: Config{Actions: []Action{
+				{Name: "build", Patterns: []string{"**/*.go"}, BuildCommands: []string{"go build"}, Timeout: time.Minute},
+				{Name: "lint", BuildCommands: []string{"go vet"}},
+			}},
+			override: Config{Actions: []Action{
+				{Name: "build", BuildCommands: []string{"go test"}, RunCommand: "./app", Env: map[string]string{"A": "1"}},
+			}},
+			expected: Config{Actions: []Action{
+				{
+					Name:          "build",
+					Patterns:      []string{"**/*.go"},
+					BuildCommands: []string{"go build", "go test"},
+					RunCommand:    "./app",
+					Timeout:       time.Minute,
+					Env:           map[string]string{"A": "1"},
+				},
+				{Name: "lint", BuildCommands: []string{"go vet"}},
+			}},
+		},
+		"unnamed actions appended": {
+			base: Config{Actions: []Action{
+				{BuildCommands: []string{"go build"}},
+			}},
+			override: Config{Actions: []Action{
+				{BuildCommands: []string{"go test"}},
+			}},
+			expected: Config{Actions: []Action{
+				{BuildCommands: []string{"go build"}},
+				{BuildCommands: []string{"go test"}},
+			}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			merged := MergeConfigs(tc.base, tc.override)
+			if !reflect.DeepEqual(merged, tc.expected) {
+				t.Errorf("MergeConfigs() should be %+v; got: %+v", tc.expected, merged)
+			}
+		})
+	}
+}
+
+func TestMergeConfigsDoesNotModifyBase(t *testing.T) {
+	base := Config{
+		ExcludeDirs: make([]string, 1, 10),
+		Env:         map[string]string{"A": "base"},
+		Actions:     []Action{{Name: "build", BuildCommands: []string{"go build"}}},
+	}
+	override := Config{
+		ExcludeDirs: []string{"override"},
+		Env:         map[string]string{"A": "override"},
+		Actions:     []Action{{Name: "build", BuildCommands: []string{"go test"}}},
+	}
+	MergeConfigs(base, override)
+
+	if base.ExcludeDirs[:2][1] != "" {
+		t.Errorf("Base slices should not be modified")
+	}
+	if base.Env["A"] != "base" {
+		t.Errorf("Base maps should not be modified")
+	}
+	if !equals(base.Actions[0].BuildCommands, []string{"go build"}) {
+		t.Errorf("Base actions should not be modified; got: %v", base.Actions[0].BuildCommands)
+	}
+}
+
+func TestParseConfigFileExtends(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	files := map[string]string{
+		"team.yml": `excludeDir: .git
+interval: 1s
+action:
+  - name: build
+    pattern: "**/*.go"
+    build: go build`,
+		"lint.json": `{"action": [{"name": "lint", "build": "go vet"}]}`,
+		"project.yml": `extends: [team.yml, lint.json]
+dir: app
+action:
+  - name: build
+    build: go test`,
+		"simple.yml": `extends: team.yml
+build: go install`,
+		"a.yml": `extends: b.yml
+build: echo a`,
+		"b.yml": `extends: a.yml
+build: echo b`,
+		"missing.yml": `extends: not-exists.yml
+build: echo`,
+	}
+	for file, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+
+	type testCase struct {
+		file     string
+		expected Config
+		err      bool
+	}
+	for name, tc := range map[string]testCase{
+		"multiple bases": {
+			file: "project.yml",
+			expected: Config{
+				Dir:         "app",
+				ExcludeDirs: []string{".git"},
+				Interval:    time.Second,
+				Actions: []Action{
+					{Name: "build", Patterns: []string{"**/*.go"}, BuildCommands: []string{"go build", "go test"}},
+					{Name: "lint", BuildCommands: []string{"go vet"}},
+				},
+			},
+		},
+		"simple config": {
+			file: "simple.yml",
+			expected: Config{
+				ExcludeDirs: []string{".git"},
+				Interval:    time.Second,
+				Actions: []Action{
+					{Name: "build", Patterns: []string{"**/*.go"}, BuildCommands: []string{"go build"}},
+					{BuildCommands: []string{"go install"}},
+				},
+			},
+		},
+		"circular": {
+			file: "a.yml",
+			err:  true,
+		},
+		"missing base": {
+			file: "missing.yml",
+			err:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.file)
+			config, err := ParseConfigFile(path)
+			if err != nil {
+				if !tc.err {
+					t.Errorf("ParseConfigFile() err should be nil; got: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				t.Fatalf("ParseConfigFile() err should not be nil")
+			}
+			if !configEquals(*config, tc.expected) {
+				t.Errorf("ParseConfigFile() should be %v; got: %v", tc.expected, config)
+			}
+			if config.File != path {
+				t.Errorf("File should be %s; got: %s", path, config.File)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package revolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsServer serves the Prometheus metrics of the builds over HTTP. A nil
+// metricsServer records nothing.
+type metricsServer struct {
+	server *http.Server
+
+	builds    *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+	restarts  *prometheus.GaugeVec
+	velocity  prometheus.GaugeFunc
+}
+
+// startMetricsServer listens on addr and serves the metrics at /metrics in the
+// background. The change velocity gauge reports the value of velocity when the
+// metrics are scraped.
+func startMetricsServer(addr string, velocity func() float64, logger Logger) (*metricsServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Error starting metrics server: %w", err)
+	}
+
+	m := &metricsServer{
+		builds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "revolver_build_total",
+			Help: "Number of builds by action and status.",
+		}, []string{"action", "status"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "revolver_build_duration_seconds",
+			Help: "Duration of the builds by action.",
+		}, []string{"action"}),
+		restarts: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "revolver_run_restarts_total",
+			Help: "Number of restarts of the run commands by action.",
+		}, []string{"action"}),
+		velocity: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "revolver_change_velocity",
+			Help: "Number of changed files per second over the change velocity window.",
+		}, velocity),
+	}
+	// Every server has its own registry, so watchers running side by side
+	// don't share their metrics.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(m.builds, m.durations, m.restarts, m.velocity)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	m.server = &http.Server{Handler: mux}
+	go func() {
+		if err := m.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error(fmt.Errorf("Error serving metrics: %w", err))
+		}
+	}()
+	logger.Info("Serving metrics on %s", listener.Addr())
+	return m, nil
+}
+
+// close shuts down the server, waiting for the running requests.
+func (m *metricsServer) close() {
+	if m == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), statusShutdownTimeout)
+	defer cancel()
+	if err := m.server.Shutdown(ctx); err != nil {
+		m.server.Close()
+	}
+}
+
+// update records the event in the metrics.
+func (m *metricsServer) update(event Event) {
+	if m == nil {
+		return
+	}
+	switch event.Kind {
+	case BuildSucceeded:
+		m.builds.WithLabelValues(event.ActionID, "success").Inc()
+		m.durations.WithLabelValues(event.ActionID).Observe(event.Duration.Seconds())
+	case BuildFailed:
+		m.builds.WithLabelValues(event.ActionID, "failure").Inc()
+		m.durations.WithLabelValues(event.ActionID).Observe(event.Duration.Seconds())
+	case RunRestarted:
+		m.restarts.WithLabelValues(event.ActionID).Inc()
+	}
+}
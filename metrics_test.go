@@ -0,0 +1,103 @@
+package revolver
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsServerUpdate(t *testing.T) {
+	m, err := startMetricsServer("127.0.0.1:0", func() float64 { return 1.5 }, NewLogger("text", ioutil.Discard))
+	if err != nil {
+		t.Fatalf("startMetricsServer() err should be nil; got: %v", err)
+	}
+	defer m.close()
+
+	m.update(Event{ActionID: "build", Kind: BuildStarted})
+	m.update(Event{ActionID: "build", Kind: BuildSucceeded, Duration: time.Second})
+	m.update(Event{ActionID: "build", Kind: BuildFailed, Duration: time.Second})
+	m.update(Event{ActionID: "build", Kind: BuildFailed, Duration: time.Second})
+	m.update(Event{ActionID: "server", Kind: RunRestarted})
+
+	for name, tc := range map[string]struct {
+		value    float64
+		expected float64
+	}{
+		"success":  {testutil.ToFloat64(m.builds.WithLabelValues("build", "success")), 1},
+		"failure":  {testutil.ToFloat64(m.builds.WithLabelValues("build", "failure")), 2},
+		"restarts": {testutil.ToFloat64(m.restarts.WithLabelValues("server")), 1},
+		"velocity": {testutil.ToFloat64(m.velocity), 1.5},
+	} {
+		if tc.value != tc.expected {
+			t.Errorf("%s should be %v; got: %v", name, tc.expected, tc.value)
+		}
+	}
+	if count := testutil.CollectAndCount(m.durations); count != 1 {
+		t.Errorf("Durations should be observed for 1 action; got: %d", count)
+	}
+}
+
+func TestWatcherMetricsAddr(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	config := Config{
+		Dir:         dir,
+		Interval:    10 * time.Millisecond,
+		MetricsAddr: addr,
+		Logger:      NewLogger("text", ioutil.Discard),
+		Actions: []Action{
+			{Name: "build", Patterns: []string{"*.go"}, BuildCommands: []string{"true"}},
+		},
+	}
+	watcher := newTestWatcher(t, config)
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+	select {
+	case <-results:
+	case <-time.After(time.Second):
+		t.Fatalf("Change should trigger a build")
+	}
+
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("Cannot get metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Cannot read metrics: %v", err)
+	}
+
+	for _, expected := range []string{
+		`revolver_build_total{action="build",status="success"} 1`,
+		`revolver_build_duration_seconds_count{action="build"} 1`,
+		`revolver_change_velocity `,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Metrics should contain %q; got:\n%s", expected, body)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+// +build !windows
+
+package revolver
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByCurrentUser reports whether the file is owned by the user running
+// revolver.
+func ownedByCurrentUser(file os.FileInfo) bool {
+	stat, ok := file.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+	return int(stat.Uid) == os.Getuid()
+}
@@ -0,0 +1,58 @@
+//go:build !windows
+// +build !windows
+
+package revolver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotOwnedByCurrentUser(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("Changing the owner of a file needs root")
+	}
+
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	for _, name := range []string{"mine.go", "other.go"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+	if err := os.Chown(filepath.Join(dir, "other.go"), 65534, 65534); err != nil {
+		t.Fatalf("Cannot change owner: %v", err)
+	}
+
+	type testCase struct {
+		registry FileRegistry
+		expected []string
+	}
+	for name, tc := range map[string]testCase{
+		"every owner": {
+			registry: FileRegistry{},
+			expected: []string{"mine.go", "other.go"},
+		},
+		"owned by current user": {
+			registry: FileRegistry{OwnedByCurrentUser: true},
+			expected: []string{"mine.go"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			files, err := tc.registry.Snapshot(dir)
+			if err != nil {
+				t.Fatalf("Snapshot() err should be nil; got: %v", err)
+			}
+			names := []string{}
+			for name := range files {
+				names = append(names, name)
+			}
+			if !equals(tc.expected, names) {
+				t.Errorf("Snapshot() files should be: %v; got: %v", tc.expected, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package revolver
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WatchControl controls the watch loop of WatchPausable.
+type WatchControl int
+
+const (
+	// Pause pauses the watch loop: no changes are detected and no actions
+	// are executed until Resume is received.
+	Pause WatchControl = iota
+	// Resume resumes the paused watch loop. The changes made while paused
+	// are detected by the next cycle.
+	Resume
+	// ResetBreakers closes the circuit breaker and the breakers of the
+	// actions opened by BreakAfter, like SIGHUP does.
+	ResetBreakers
+)
+
+// WatchPausable watches like Watch does, and can be paused and resumed with
+// the control channel, e.g. while a git pull touches many files. Closing the
+// control channel resumes the watch loop if it's paused.
+func WatchPausable(config Config, control <-chan WatchControl) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	watcher, err := NewWatcher(config)
+	if err != nil {
+		return err
+	}
+	watcher.control = control
+	if err := watcher.Start(context.Background()); err != nil {
+		return err
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- watcher.Wait()
+	}()
+
+	for {
+		select {
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				config.logger().Info("Received %v, resetting the circuit breakers...", sig)
+				watcher.ResetBreakers()
+				continue
+			}
+			config.logger().Info("Received %v, stopping...", sig)
+			watcher.Stop()
+			return <-stopped
+		case err := <-stopped:
+			return err
+		}
+	}
+}
+
+// paused blocks until Resume is received or the control channel is closed,
+// and reports whether the watcher should continue.
+func paused(stop <-chan struct{}, control <-chan WatchControl, r *runner) bool {
+	r.logger.Info("Paused.")
+	for {
+		select {
+		case <-stop:
+			return false
+		case c, ok := <-control:
+			if !ok || c == Resume {
+				r.logger.Info("Resumed.")
+				return true
+			}
+			if c == ResetBreakers {
+				r.resetBreakers()
+			}
+		}
+	}
+}
+
+// mergeControl returns a channel receiving the commands of both control
+// channels until stop is closed. Closing a is forwarded as Resume, like the
+// watch loop resumes when its control channel is closed.
+func mergeControl(stop <-chan struct{}, a, b <-chan WatchControl) <-chan WatchControl {
+	merged := make(chan WatchControl)
+	go func() {
+		for {
+			var c WatchControl
+			select {
+			case <-stop:
+				return
+			case next, ok := <-a:
+				if !ok {
+					a = nil
+					next = Resume
+				}
+				c = next
+			case c = <-b:
+			}
+
+			select {
+			case merged <- c:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return merged
+}
@@ -0,0 +1,55 @@
+package revolver
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherPause(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	control := make(chan WatchControl, 2)
+	control <- Pause
+
+	watcher := newTestWatcher(t, Config{
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+		Actions: []Action{
+			{Name: "build", BuildCommands: []string{"true"}},
+		},
+	})
+	watcher.control = control
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	for _, name := range []string{"a.go", "b.go"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	select {
+	case result := <-results:
+		t.Fatalf("Watcher should not run a cycle while paused; got: %v", result)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	control <- Resume
+	select {
+	case result := <-results:
+		if !equals([]string{"a.go", "b.go"}, result.Changes) {
+			t.Errorf("Changes should be accumulated while paused: %v; got: %v", []string{"a.go", "b.go"}, result.Changes)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watcher should run a cycle after resumed")
+	}
+}
@@ -0,0 +1,79 @@
+package revolver
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// BuildCommandIO returns a BuildFunc like BuildCommand does, reading the
+// standard input of the command from stdin and writing its standard output to
+// stdout. Nil stdin means no input, nil stdout means os.Stdout.
+func BuildCommandIO(command string, args []string, stdin io.Reader, stdout io.Writer) BuildFunc {
+	return buildCommand(commandOptions{Stdin: stdin, Stdout: stdout}, command, args...)
+}
+
+// PipeStage creates the BuildFunc of a stage of a BuildPipeline, reading from
+// stdin and writing to stdout. It is called on every execution of the
+// pipeline with new streams.
+type PipeStage func(stdin io.Reader, stdout io.Writer) BuildFunc
+
+// CommandStage returns a PipeStage executing a command with arguments using
+// BuildCommandIO.
+func CommandStage(command string, args ...string) PipeStage {
+	return func(stdin io.Reader, stdout io.Writer) BuildFunc {
+		return BuildCommandIO(command, args, stdin, stdout)
+	}
+}
+
+// BuildPipeline returns a BuildFunc that executes the stages at the same time,
+// piping the standard output of every stage to the standard input of the next
+// one, like a shell pipe does. The first stage has no input and the output of
+// the last stage goes to os.Stdout. It returns an error if any of the stages
+// fails.
+func BuildPipeline(stages ...PipeStage) BuildFunc {
+	return func() error {
+		n := len(stages)
+		readers := make([]*io.PipeReader, n)
+		writers := make([]*io.PipeWriter, n)
+		builds := make([]BuildFunc, n)
+		for i, stage := range stages {
+			var stdin io.Reader
+			if readers[i] != nil {
+				stdin = readers[i]
+			}
+			var stdout io.Writer
+			if i < n-1 {
+				readers[i+1], writers[i] = io.Pipe()
+				stdout = writers[i]
+			}
+			builds[i] = stage(stdin, stdout)
+		}
+
+		errs := make([]error, n)
+		var wg sync.WaitGroup
+		for i, build := range builds {
+			wg.Add(1)
+			go func(i int, build BuildFunc) {
+				defer wg.Done()
+				errs[i] = build()
+				// The next stage reads EOF, the previous one can't write
+				// more than this stage read.
+				if writers[i] != nil {
+					writers[i].CloseWithError(errs[i])
+				}
+				if readers[i] != nil {
+					readers[i].Close()
+				}
+			}(i, build)
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err != nil {
+				return fmt.Errorf("Error executing build pipeline stage %d: %w", i+1, err)
+			}
+		}
+		return nil
+	}
+}
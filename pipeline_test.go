@@ -0,0 +1,88 @@
+package revolver
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuildCommandIO(t *testing.T) {
+	var stdout bytes.Buffer
+	build := BuildCommandIO("tr", []string{"a-z", "A-Z"}, strings.NewReader("revolver"), &stdout)
+	if err := build(); err != nil {
+		t.Fatalf("BuildCommandIO() err should be nil; got: %v", err)
+	}
+	if got := stdout.String(); got != "REVOLVER" {
+		t.Errorf("BuildCommandIO() output should be %q; got: %q", "REVOLVER", got)
+	}
+}
+
+func TestBuildPipeline(t *testing.T) {
+	type testCase struct {
+		stages   []PipeStage
+		expected string
+		err      bool
+	}
+	for name, tc := range map[string]testCase{
+		"single stage": {
+			stages:   []PipeStage{CommandStage("echo", "a")},
+			expected: "a\n",
+		},
+		"multiple stages": {
+			stages: []PipeStage{
+				CommandStage("printf", `b\na\nb\n`),
+				CommandStage("sort"),
+				CommandStage("uniq"),
+			},
+			expected: "a\nb\n",
+		},
+		"failed stage": {
+			stages: []PipeStage{
+				CommandStage("printf", `a\n`),
+				CommandStage("false"),
+			},
+			err: true,
+		},
+		"failed first stage": {
+			stages: []PipeStage{
+				CommandStage("false"),
+				CommandStage("cat"),
+			},
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			file := filepath.Join(dir, "out")
+
+			// The output of the last stage goes to os.Stdout, so it's
+			// written to a file by an additional stage.
+			stages := append(tc.stages, func(stdin io.Reader, _ io.Writer) BuildFunc {
+				return BuildCommandIO("sh", []string{"-c", "cat > " + file}, stdin, nil)
+			})
+			build := BuildPipeline(stages...)
+
+			// The pipeline can be executed again, like every BuildFunc.
+			for i := 0; i < 2; i++ {
+				err := build()
+				if tc.err {
+					if err == nil {
+						t.Errorf("BuildPipeline() err should not be nil")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("BuildPipeline() err should be nil; got: %v", err)
+				}
+				b, _ := ioutil.ReadFile(file)
+				if string(b) != tc.expected {
+					t.Errorf("BuildPipeline() output should be %q; got: %q", tc.expected, b)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package revolver
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// DefaultLogPrefix is the prefix of the output lines of an action's commands
+// if its LogPrefix is empty.
+const DefaultLogPrefix = "[{{.ID}}]"
+
+// NoLogPrefix as the LogPrefix of an action disables the prefix.
+const NoLogPrefix = "none"
+
+// prefixWriter is an io.Writer that writes every line written to it to w
+// prefixed by prefix. Partial lines are buffered until their newline.
+type prefixWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, prefix: []byte(prefix + " ")}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			return len(b), nil
+		}
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+}
+
+// Flush writes the last line if it isn't terminated by a newline, adding the
+// newline so the next line starts with its own prefix.
+func (p *prefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) == 0 {
+		return nil
+	}
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	_, err := p.w.Write(append(append([]byte{}, p.prefix...), line...))
+	return err
+}
+
+// withPrefix returns the options with the standard output and error of the
+// commands prefixed by opts.Prefix, and the function flushing their last
+// partial lines after the command exited.
+func (opts commandOptions) withPrefix() (commandOptions, func()) {
+	if opts.Prefix == "" {
+		return opts, func() {}
+	}
+	stdout, stderr := opts.stdout(), opts.stderr()
+	prefixedStdout := newPrefixWriter(stdout, opts.Prefix)
+	prefixedStderr := newPrefixWriter(stderr, opts.Prefix)
+	opts.Stdout, opts.Stderr = prefixedStdout, prefixedStderr
+	return opts, func() {
+		prefixedStdout.Flush()
+		prefixedStderr.Flush()
+	}
+}
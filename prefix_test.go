@@ -0,0 +1,111 @@
+package revolver
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	type testCase struct {
+		chunks   []string
+		expected string
+	}
+	for name, tc := range map[string]testCase{
+		"single line": {
+			chunks:   []string{"compiling\n"},
+			expected: "[build] compiling\n",
+		},
+		"multiple lines": {
+			chunks:   []string{"compiling\nlinking\ndone\n"},
+			expected: "[build] compiling\n[build] linking\n[build] done\n",
+		},
+		"partial writes": {
+			chunks:   []string{"comp", "iling\nlin", "king\n"},
+			expected: "[build] compiling\n[build] linking\n",
+		},
+		"no newline": {
+			chunks:   []string{"compiling\ndo", "ne"},
+			expected: "[build] compiling\n[build] done\n",
+		},
+		"empty lines": {
+			chunks:   []string{"\n\n"},
+			expected: "[build] \n[build] \n",
+		},
+		"nothing": {
+			expected: "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := newPrefixWriter(&out, "[build]")
+			for _, chunk := range tc.chunks {
+				if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+					t.Fatalf("Write() should write %d bytes; got: %d, %v", len(chunk), n, err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Flush() err should be nil; got: %v", err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("Output should be %q; got: %q", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestBuildCommandPrefix(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	build := buildCommand(commandOptions{Stdout: &stdout, Stderr: &stderr, Prefix: "[build]"},
+		"sh", "-c", "echo compiling; printf done; echo warning >&2")
+	if err := build(); err != nil {
+		t.Fatalf("Build err should be nil; got: %v", err)
+	}
+
+	if expected := "[build] compiling\n[build] done\n"; stdout.String() != expected {
+		t.Errorf("Stdout should be %q; got: %q", expected, stdout.String())
+	}
+	if expected := "[build] warning\n"; stderr.String() != expected {
+		t.Errorf("Stderr should be %q; got: %q", expected, stderr.String())
+	}
+}
+
+func TestActionLogPrefix(t *testing.T) {
+	type testCase struct {
+		prefix   string
+		expected string
+	}
+	for name, tc := range map[string]testCase{
+		"default": {
+			prefix:   "",
+			expected: "[server]",
+		},
+		"id": {
+			prefix:   "{{.ID}}:",
+			expected: "server:",
+		},
+		"custom": {
+			prefix:   "{{.ActionName}} |",
+			expected: "api |",
+		},
+		"vars": {
+			prefix:   "{{.Vars.env}}>",
+			expected: "dev>",
+		},
+		"none": {
+			prefix:   NoLogPrefix,
+			expected: "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			a := Action{Name: "api", LogPrefix: tc.prefix}
+			data := templateData{ActionName: "api", ActionID: "server", Vars: map[string]string{"env": "dev"}}
+			interpolated, err := interpolate(a, data)
+			if err != nil {
+				t.Fatalf("interpolate() err should be nil; got: %v", err)
+			}
+			if interpolated.LogPrefix != tc.expected {
+				t.Errorf("LogPrefix should be %q; got: %q", tc.expected, interpolated.LogPrefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package revolver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// FileEvent is a change event of a file.
+type FileEvent = ChangeEvent
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (kind ChangeKind) MarshalText() ([]byte, error) {
+	return []byte(kind.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (kind *ChangeKind) UnmarshalText(text []byte) error {
+	for _, k := range []ChangeKind{Added, Modified, Deleted} {
+		if k.String() == string(text) {
+			*kind = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown change kind: %s", text)
+}
+
+// PrintFormatter formats the change events printed in print only mode.
+type PrintFormatter interface {
+	Format(events []FileEvent) string
+}
+
+// NewPrintFormatter returns the PrintFormatter for the format: "text" (the
+// default), "json", "nul" or "csv".
+func NewPrintFormatter(format string) (PrintFormatter, error) {
+	switch format {
+	case "", "text":
+		return TextFormatter{}, nil
+	case "json":
+		return JSONFormatter{}, nil
+	case "nul":
+		return NulFormatter{}, nil
+	case "csv":
+		return CSVFormatter{}, nil
+	}
+	return nil, fmt.Errorf("unknown print format: %s", format)
+}
+
+// TextFormatter formats the events as one path per line.
+type TextFormatter struct{}
+
+// Format implements the PrintFormatter interface.
+func (TextFormatter) Format(events []FileEvent) string {
+	var b strings.Builder
+	for _, event := range events {
+		b.WriteString(event.Path)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// JSONFormatter formats the events as a JSON array in a single line.
+type JSONFormatter struct{}
+
+// Format implements the PrintFormatter interface.
+func (JSONFormatter) Format(events []FileEvent) string {
+	if events == nil {
+		events = []FileEvent{}
+	}
+	b, _ := json.Marshal(events)
+	return string(b) + "\n"
+}
+
+// NulFormatter formats the events as null terminated paths, like find -print0.
+type NulFormatter struct{}
+
+// Format implements the PrintFormatter interface.
+func (NulFormatter) Format(events []FileEvent) string {
+	var b strings.Builder
+	for _, event := range events {
+		b.WriteString(event.Path)
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// CSVFormatter formats the events as CSV records of path and kind.
+type CSVFormatter struct{}
+
+// Format implements the PrintFormatter interface.
+func (CSVFormatter) Format(events []FileEvent) string {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	for _, event := range events {
+		w.Write([]string{event.Path, event.Kind.String()})
+	}
+	w.Flush()
+	return b.String()
+}
+
+// printChanges prints the changes to w instead of executing actions, until an
+// error happens or stop is closed.
+func printChanges(config Config, w io.Writer, stop <-chan struct{}) error {
+	formatter, err := NewPrintFormatter(config.PrintFormat)
+	if err != nil {
+		return err
+	}
+
+	detect := newDetectEvents(config)
+	detect()
+
+	rnd := newJitterRand()
+	for {
+		if events := detect(); len(events) > 0 {
+			if _, err := io.WriteString(w, formatter.Format(events)); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(jitterInterval(rnd, config.Interval, config.IntervalJitter)):
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package revolver
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrintFormatter(t *testing.T) {
+	events := []FileEvent{
+		{Path: "main.go", Kind: Added},
+		{Path: "dir/with space.go", Kind: Modified},
+		{Path: "dir/with,comma.go", Kind: Deleted},
+	}
+
+	type testCase struct {
+		parse func(t *testing.T, output string) []FileEvent
+	}
+	for format, tc := range map[string]testCase{
+		"text": {
+			parse: func(t *testing.T, output string) []FileEvent {
+				parsed := []FileEvent{}
+				for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+					parsed = append(parsed, FileEvent{Path: line})
+				}
+				return parsed
+			},
+		},
+		"json": {
+			parse: func(t *testing.T, output string) []FileEvent {
+				parsed := []FileEvent{}
+				if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+					t.Fatalf("Cannot parse json: %v", err)
+				}
+				return parsed
+			},
+		},
+		"csv": {
+			parse: func(t *testing.T, output string) []FileEvent {
+				records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+				if err != nil {
+					t.Fatalf("Cannot parse csv: %v", err)
+				}
+				parsed := []FileEvent{}
+				for _, record := range records {
+					var kind ChangeKind
+					if err := kind.UnmarshalText([]byte(record[1])); err != nil {
+						t.Fatalf("Cannot parse kind: %v", err)
+					}
+					parsed = append(parsed, FileEvent{Path: record[0], Kind: kind})
+				}
+				return parsed
+			},
+		},
+	} {
+		t.Run(format, func(t *testing.T) {
+			formatter, err := NewPrintFormatter(format)
+			if err != nil {
+				t.Fatalf("NewPrintFormatter() err should be nil; got: %v", err)
+			}
+
+			parsed := tc.parse(t, formatter.Format(events))
+
+			if len(parsed) != len(events) {
+				t.Fatalf("Parsed events should be: %v; got: %v", events, parsed)
+			}
+			for i := range events {
+				if parsed[i].Path != events[i].Path {
+					t.Errorf("Parsed path should be: %v; got: %v", events[i].Path, parsed[i].Path)
+				}
+				if format != "text" && parsed[i].Kind != events[i].Kind {
+					t.Errorf("Parsed kind should be: %v; got: %v", events[i].Kind, parsed[i].Kind)
+				}
+			}
+		})
+	}
+}
+
+func TestNulFormatter(t *testing.T) {
+	output := NulFormatter{}.Format([]FileEvent{{Path: "a.go"}, {Path: "b.go"}})
+	if output != "a.go\x00b.go\x00" {
+		t.Errorf("Output should be %q; got: %q", "a.go\x00b.go\x00", output)
+	}
+}
+
+func TestNewPrintFormatterUnknown(t *testing.T) {
+	if _, err := NewPrintFormatter("xml"); err == nil {
+		t.Errorf("NewPrintFormatter() err should not be nil")
+	}
+}
+
+func TestNewDetectEvents(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	for _, name := range []string{"a/main.go", "a/main_test.go", "b/lib.go", "c/other.go"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatalf("Cannot create dir: %v", err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+
+	type testCase struct {
+		config   Config
+		expected []FileEvent
+	}
+	for name, tc := range map[string]testCase{
+		"dirs": {
+			config: Config{
+				Dirs:            []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")},
+				ExcludePatterns: []string{"**/*_test.go"},
+			},
+			expected: []FileEvent{
+				{Path: filepath.Join(dir, "a", "main.go"), Kind: Added},
+				{Path: filepath.Join(dir, "b", "lib.go"), Kind: Added},
+			},
+		},
+		"files": {
+			config: Config{
+				Dir:   dir,
+				Files: []string{"b/lib.go"},
+			},
+			expected: []FileEvent{
+				{Path: "b/lib.go", Kind: Added},
+			},
+		},
+		"custom detect": {
+			config: Config{
+				Detect:          func() []string { return []string{"x.go", "x_test.go"} },
+				ExcludePatterns: []string{"**/*_test.go"},
+			},
+			expected: []FileEvent{
+				{Path: "x.go", Kind: Modified},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			events := newDetectEvents(tc.config)()
+			sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+			if !reflect.DeepEqual(events, tc.expected) {
+				t.Errorf("Events should be %v; got: %v", tc.expected, events)
+			}
+		})
+	}
+}
+
+func TestPrintChangesConfig(t *testing.T) {
+	changes := make(chan []string, 2)
+	changes <- []string{}
+	changes <- []string{"main.go", "main_test.go"}
+	config := Config{
+		Interval: 10 * time.Millisecond,
+		Detect: func() []string {
+			select {
+			case c := <-changes:
+				return c
+			default:
+				return []string{}
+			}
+		},
+		ExcludePatterns: []string{"**/*_test.go"},
+	}
+
+	output := &syncBuffer{}
+	stop := make(chan struct{})
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- printChanges(config, output, stop)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(output.Bytes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	if err := <-stopped; err != nil {
+		t.Errorf("printChanges() err should be nil; got: %v", err)
+	}
+	if got := string(output.Bytes()); got != "main.go\n" {
+		t.Errorf("Printed changes should be %q; got: %q", "main.go\n", got)
+	}
+}
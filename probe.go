@@ -0,0 +1,140 @@
+package revolver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// runStartupProbes runs the startup probes of the config one by one. If a
+// probe fails, its output is logged and an error is returned.
+func runStartupProbes(config Config, logger Logger) error {
+	env, err := config.env()
+	if err != nil {
+		return err
+	}
+	opts := commandOptions{Env: env}
+
+	for _, probe := range config.StartupProbe {
+		command, args, err := shellCommand(config.Shell, probe)
+		if err != nil {
+			return err
+		}
+		cmd := opts.command(context.Background(), command, args...)
+
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			if out := strings.TrimSpace(output.String()); out != "" {
+				logger.Info("%s", out)
+			}
+			return fmt.Errorf("Error running startup probe: \"%s\": %w", probe, err)
+		}
+	}
+	return nil
+}
+
+// RunProbe checks whether a started RunFunc is ready, e.g. a web server
+// accepts requests.
+type RunProbe interface {
+	// Probe returns an error if the RunFunc is not ready.
+	Probe() error
+}
+
+// RunProbeFunc is a func implementing RunProbe.
+type RunProbeFunc func() error
+
+// Probe calls the func.
+func (f RunProbeFunc) Probe() error {
+	return f()
+}
+
+// HTTPProbe returns a RunProbe that sends a GET request to url, and succeeds
+// if the response arrives within timeout with a status code below 400.
+func HTTPProbe(url string, timeout time.Duration) RunProbe {
+	client := &http.Client{Timeout: timeout}
+	return RunProbeFunc(func() error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("%s: %s", url, resp.Status)
+		}
+		return nil
+	})
+}
+
+// TCPProbe returns a RunProbe that succeeds if a TCP connection to addr can
+// be opened within timeout.
+func TCPProbe(addr string, timeout time.Duration) RunProbe {
+	return RunProbeFunc(func() error {
+		conn, err := net.DialTimeout("tcp", addr, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// readinessInterval is the interval of polling a readiness probe.
+const readinessInterval = 100 * time.Millisecond
+
+// RunWithProbe returns a RunFunc that starts run and polls the probe until it
+// succeeds. If the probe doesn't succeed within timeout, the started run is
+// stopped and an error is returned.
+func RunWithProbe(run RunFunc, probe RunProbe, timeout time.Duration) RunFunc {
+	return func() (func(), error) {
+		stop, err := run()
+		if err != nil {
+			return nil, err
+		}
+
+		deadline := time.Now().Add(timeout)
+		for {
+			err := probe.Probe()
+			if err == nil {
+				return stop, nil
+			}
+			if !time.Now().Before(deadline) {
+				if stop != nil {
+					stop()
+				}
+				return nil, fmt.Errorf("Error executing run func: not ready after %v: %w", timeout, err)
+			}
+			time.Sleep(readinessInterval)
+		}
+	}
+}
+
+// ReadinessProbe configures the RunProbe of an action. Exactly one of HTTP
+// and TCP should be set.
+type ReadinessProbe struct {
+	// HTTP is the URL checked by an HTTPProbe.
+	HTTP string `yaml:"http,omitempty"`
+	// TCP is the address checked by a TCPProbe.
+	TCP string `yaml:"tcp,omitempty"`
+}
+
+// probeTimeout is the timeout of a single readiness probe.
+const probeTimeout = time.Second
+
+// runProbe returns the RunProbe configured by the ReadinessProbe.
+func (p ReadinessProbe) runProbe() (RunProbe, error) {
+	switch {
+	case p.HTTP != "" && p.TCP != "":
+		return nil, errors.New("readinessProbe should have either http or tcp")
+	case p.HTTP != "":
+		return HTTPProbe(p.HTTP, probeTimeout), nil
+	case p.TCP != "":
+		return TCPProbe(p.TCP, probeTimeout), nil
+	}
+	return nil, errors.New("readinessProbe should have http or tcp")
+}
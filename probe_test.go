@@ -0,0 +1,167 @@
+package revolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunStartupProbes(t *testing.T) {
+	type testCase struct {
+		probes []string
+		err    bool
+		logged string
+	}
+
+	testCases := map[string]testCase{
+		"no probes": {},
+		"passing probes": {
+			probes: []string{"true", "echo ok"},
+		},
+		"failing probe": {
+			probes: []string{"true", "echo go is missing >&2; exit 3", "echo never"},
+			err:    true,
+			logged: "go is missing",
+		},
+		"missing command": {
+			probes: []string{"revolver-not-existing-command"},
+			err:    true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			logger := &recordLogger{}
+			err := runStartupProbes(Config{Shell: "sh -c", StartupProbe: tc.probes}, logger)
+			if (err != nil) != tc.err {
+				t.Fatalf("runStartupProbes() err should be %v; got: %v", tc.err, err)
+			}
+			if tc.logged != "" && !strings.Contains(strings.Join(logger.lines, "\n"), tc.logged) {
+				t.Errorf("probe output %q should be logged; got: %v", tc.logged, logger.lines)
+			}
+		})
+	}
+}
+
+func TestWatcherStartupProbeFails(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:          dir,
+		StartupProbe: []string{"false"},
+		Actions:      []Action{{BuildCommands: []string{"true"}}},
+	})
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	if err := watcher.Wait(); err == nil || !strings.Contains(err.Error(), "startup probe") {
+		t.Errorf("Wait() err should be the startup probe error; got: %v", err)
+	}
+}
+
+func TestRunProbes(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	defer listener.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	closed.Close()
+
+	type testCase struct {
+		probe RunProbe
+		err   bool
+	}
+	for name, tc := range map[string]testCase{
+		"http ready": {
+			probe: HTTPProbe(ok.URL, time.Second),
+		},
+		"http error status": {
+			probe: HTTPProbe(failing.URL, time.Second),
+			err:   true,
+		},
+		"tcp ready": {
+			probe: TCPProbe(listener.Addr().String(), time.Second),
+		},
+		"tcp closed": {
+			probe: TCPProbe(closed.Addr().String(), time.Second),
+			err:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := tc.probe.Probe(); (err != nil) != tc.err {
+				t.Errorf("Probe() err should be non-nil: %v; got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestRunWithProbe(t *testing.T) {
+	type testCase struct {
+		readyAfter int
+		err        bool
+	}
+	for name, tc := range map[string]testCase{
+		"ready": {
+			readyAfter: 0,
+		},
+		"ready after attempts": {
+			readyAfter: 2,
+		},
+		"never ready": {
+			readyAfter: 100,
+			err:        true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stopped := false
+			run := func() (func(), error) {
+				return func() { stopped = true }, nil
+			}
+			attempts := 0
+			probe := RunProbeFunc(func() error {
+				attempts++
+				if attempts > tc.readyAfter {
+					return nil
+				}
+				return errors.New("not ready")
+			})
+
+			stop, err := RunWithProbe(run, probe, 350*time.Millisecond)()
+			if tc.err {
+				if err == nil {
+					t.Fatalf("RunFunc err should not be nil")
+				}
+				if !stopped {
+					t.Errorf("RunFunc should be stopped if it never becomes ready")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RunFunc err should be nil; got: %v", err)
+			}
+			if stop == nil || stopped {
+				t.Errorf("RunFunc should keep running once ready")
+			}
+			if attempts != tc.readyAfter+1 {
+				t.Errorf("Probe should be polled %d times; got: %d", tc.readyAfter+1, attempts)
+			}
+		})
+	}
+}
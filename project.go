@@ -0,0 +1,93 @@
+package revolver
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The project types returned by DetectProjectType.
+const (
+	ProjectGo      = "go"
+	ProjectNode    = "node"
+	ProjectUnknown = "unknown"
+)
+
+// projectDirs are skipped when counting the source files of a project.
+var projectDirs = []string{".git", "node_modules", "vendor"}
+
+// DetectProjectType returns the type of the project in dir: ProjectGo,
+// ProjectNode or ProjectUnknown. The manifest files (go.mod, package.json) in
+// dir decide the type. Without them the type of the most source files wins.
+func DetectProjectType(dir string) (string, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return "", fmt.Errorf("Error detecting project type: %w", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+		return ProjectGo, nil
+	}
+	if _, err := os.Stat(filepath.Join(dir, "package.json")); err == nil {
+		return ProjectNode, nil
+	}
+
+	counts := make(map[string]int)
+	err := filepath.Walk(dir, func(path string, file os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if file.IsDir() {
+			if path != dir && matchPatterns(projectDirs, file.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".go":
+			counts[ProjectGo]++
+		case ".ts", ".tsx", ".js", ".jsx":
+			counts[ProjectNode]++
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("Error detecting project type: %w", err)
+	}
+
+	switch {
+	case counts[ProjectGo] == 0 && counts[ProjectNode] == 0:
+		return ProjectUnknown, nil
+	case counts[ProjectGo] >= counts[ProjectNode]:
+		return ProjectGo, nil
+	default:
+		return ProjectNode, nil
+	}
+}
+
+// StarterConfig returns a starter config file for the project type.
+func StarterConfig(projectType string) string {
+	switch projectType {
+	case ProjectGo:
+		return `excludeDir: [".git", "vendor"]
+action:
+  - name: "app"
+    pattern: "**/*.go"
+    exclude: "**/*_test.go"
+    build: ["go build ./...", "go build -o app ."]
+    run: "./app"
+`
+	case ProjectNode:
+		return `excludeDir: [".git", "node_modules", "dist"]
+action:
+  - name: "app"
+    pattern: ["**/*.ts", "**/*.js", "package.json"]
+    build: ["npm run build"]
+    run: "npm start"
+`
+	}
+	return `excludeDir: ".git"
+action:
+  - name: "app"
+    pattern: "**/*"
+    build: ["echo changed"]
+`
+}
@@ -0,0 +1,83 @@
+package revolver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectProjectType(t *testing.T) {
+	type testCase struct {
+		files    []string
+		expected string
+	}
+	for name, tc := range map[string]testCase{
+		"empty": {
+			files:    []string{},
+			expected: ProjectUnknown,
+		},
+		"go.mod": {
+			files:    []string{"go.mod", "web/app.ts", "web/util.ts"},
+			expected: ProjectGo,
+		},
+		"package.json": {
+			files:    []string{"package.json", "tools/gen.go"},
+			expected: ProjectNode,
+		},
+		"go files": {
+			files:    []string{"main.go", "cmd/app/app.go"},
+			expected: ProjectGo,
+		},
+		"typescript files": {
+			files:    []string{"src/index.ts", "src/app.ts", "node_modules/lib/x.go"},
+			expected: ProjectNode,
+		},
+		"vendored go files": {
+			files:    []string{"index.js", "vendor/a/a.go", "vendor/b/b.go"},
+			expected: ProjectNode,
+		},
+		"other files": {
+			files:    []string{"README.md", "docs/index.html"},
+			expected: ProjectUnknown,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			for _, name := range tc.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+					t.Fatalf("Cannot create dir: %v", err)
+				}
+				if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+					t.Fatalf("Cannot write file: %v", err)
+				}
+			}
+
+			projectType, err := DetectProjectType(dir)
+			if err != nil {
+				t.Fatalf("DetectProjectType() err should be nil; got: %v", err)
+			}
+			if projectType != tc.expected {
+				t.Errorf("DetectProjectType() should return %q; got: %q", tc.expected, projectType)
+			}
+		})
+	}
+
+	if _, err := DetectProjectType("not_exists"); err == nil {
+		t.Errorf("DetectProjectType() err should not be nil for a missing dir")
+	}
+}
+
+func TestStarterConfig(t *testing.T) {
+	for _, projectType := range []string{ProjectGo, ProjectNode, ProjectUnknown} {
+		config, err := parseConfig([]byte(StarterConfig(projectType)))
+		if err != nil {
+			t.Fatalf("Starter config of %s should be valid yaml; got: %v", projectType, err)
+		}
+		if err := config.validate(); err != nil {
+			t.Errorf("Starter config of %s should be valid; got: %v", projectType, err)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package revolver
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar"
+)
+
+// FileInfo is the state of a file recorded by a FileRegistry.
+type FileInfo struct {
+	ModTime time.Time
+	Size    int64
+	// Hash is the SHA-256 hash of the content of the file. It is only set
+	// if the registry uses hashes.
+	Hash [sha256.Size]byte
+	// Oversized marks a file bigger than the MaxFileSize of the registry.
+	// Diff ignores its changes, but it isn't reported as deleted while it
+	// exists.
+	Oversized bool
+}
+
+// FileRegistry records the state of the files in a directory tree and detects
+// the changes between the recorded states.
+type FileRegistry struct {
+	// ExcludeDirs are patterns of directories skipped by Snapshot.
+	ExcludeDirs []string
+	// IncludeDirs are patterns of directories tracked by Snapshot. If it is
+	// not empty, only the files of the matching directories and their
+	// subdirectories are tracked, besides the files of the walked dir.
+	IncludeDirs []string
+	// AllowList are patterns of files tracked by Snapshot. If it is not
+	// empty, every other file is ignored.
+	AllowList []string
+	// IgnorePatterns are patterns of files and directories skipped by
+	// Snapshot, usually read from an ignore file.
+	IgnorePatterns []string
+	// UseHash makes Diff compare the SHA-256 hashes of the files' content
+	// instead of their modification times. It's more reliable on filesystems
+	// with coarse or unreliable modification times, but every file is read
+	// on every Snapshot.
+	UseHash bool
+	// CaseInsensitive makes Diff compare the paths case-insensitively, like
+	// case-insensitive filesystems (macOS, Windows) do. A file whose path
+	// only changed in case is reported as modified under its new path
+	// instead of being deleted and added.
+	CaseInsensitive bool
+	// SkipHidden makes Snapshot skip the files and directories whose name
+	// starts with a dot.
+	SkipHidden bool
+	// MaxDepth is the maximum depth of the files tracked by Snapshot, the
+	// files of the walked dir being at depth 1. 0 means unlimited.
+	MaxDepth int
+	// MaxFileSize is the maximum size in bytes of the files tracked by
+	// Snapshot. Bigger files, e.g. generated binaries, are recorded as
+	// Oversized without being hashed. 0 means unlimited.
+	MaxFileSize int64
+	// OwnedByCurrentUser makes Snapshot skip the files that aren't owned by
+	// the user running revolver. It has no effect on Windows.
+	OwnedByCurrentUser bool
+	// FollowSymlinks makes Snapshot walk the directories that symlinks point
+	// to, as if they were in place of the symlinks. Symlinks pointing to one
+	// of their ancestors are skipped.
+	FollowSymlinks bool
+	// OnSkip is called with the errors of the paths skipped by Snapshot, e.g.
+	// symlink cycles, if it is not nil.
+	OnSkip func(err error)
+}
+
+// Snapshot walks the filesystem from the given dir recursively, skipping the
+// excluded dirs, and returns the state of the files by their path relative to
+// dir. If an error happens, the files walked so far are returned with it.
+func (registry FileRegistry) Snapshot(dir string) (map[string]FileInfo, error) {
+	files := make(map[string]FileInfo)
+	var ancestors []os.FileInfo
+	if info, err := os.Stat(dir); err == nil && registry.FollowSymlinks {
+		ancestors = append(ancestors, info)
+	}
+	err := registry.walk(dir, "", ancestors, files)
+	return files, err
+}
+
+// walk adds the state of the files under root to files, with their paths
+// relative to root prefixed by prefix. The ancestors are the directories
+// walked through symlinks containing root.
+func (registry FileRegistry) walk(root, prefix string, ancestors []os.FileInfo, files map[string]FileInfo) error {
+	return filepath.Walk(root, func(path string, file os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if prefix != "" {
+			name = filepath.Join(prefix, name)
+		}
+
+		// The name of a symlinked root is checked as the name of the symlink.
+		if path != root && registry.SkipHidden && strings.HasPrefix(file.Name(), ".") {
+			if file.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if registry.FollowSymlinks && file.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err == nil && target.IsDir() {
+				return registry.walkSymlink(path, name, target, ancestors, files)
+			}
+			if err == nil {
+				// Changes of the target file are detected.
+				file = target
+			}
+		}
+
+		if file.IsDir() {
+			if name != "." && registry.MaxDepth > 0 && depth(name) >= registry.MaxDepth {
+				return filepath.SkipDir
+			}
+			if matchPatterns(registry.ExcludeDirs, name) || matchPatterns(registry.IgnorePatterns, name) {
+				return filepath.SkipDir
+			}
+			if name != "." && !registry.includedDir(name) && !matchDirPrefix(registry.IncludeDirs, name) {
+				return filepath.SkipDir
+			}
+			if name != "." && len(registry.AllowList) > 0 && !matchDirPrefix(registry.AllowList, name) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !registry.matchAllowList(name) || matchPatterns(registry.IgnorePatterns, name) {
+			return nil
+		}
+		if dir := filepath.Dir(name); dir != "." && !registry.includedDir(dir) {
+			return nil
+		}
+		if info, ok := registry.fileInfo(path, file); ok {
+			files[name] = info
+		}
+		return nil
+	})
+}
+
+// SnapshotFiles returns the state of the given files like Snapshot does,
+// without walking any directory. Relative paths are resolved against dir and
+// the files are recorded by their path as given. Missing files are left out.
+func (registry FileRegistry) SnapshotFiles(dir string, paths []string) map[string]FileInfo {
+	files := make(map[string]FileInfo)
+	for _, name := range paths {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		file, err := os.Stat(path)
+		if err != nil || file.IsDir() {
+			continue
+		}
+		if info, ok := registry.fileInfo(path, file); ok {
+			files[filepath.Clean(name)] = info
+		}
+	}
+	return files
+}
+
+// fileInfo returns the state of the file at path, or false if the file is
+// skipped.
+func (registry FileRegistry) fileInfo(path string, file os.FileInfo) (FileInfo, bool) {
+	if registry.OwnedByCurrentUser && !ownedByCurrentUser(file) {
+		return FileInfo{}, false
+	}
+	info := FileInfo{
+		ModTime: file.ModTime(),
+		Size:    file.Size(),
+	}
+	if registry.MaxFileSize > 0 && file.Size() > registry.MaxFileSize {
+		info.Oversized = true
+		return info, true
+	}
+	if registry.UseHash {
+		hash, err := hashFile(path)
+		if err != nil {
+			// The file was removed or can't be read since it was walked.
+			return FileInfo{}, false
+		}
+		info.Hash = hash
+	}
+	return info, true
+}
+
+// walkSymlink walks the target dir of the symlink at path with the given
+// name, unless the target is one of the ancestors of the symlink, which would
+// make the walk loop forever.
+func (registry FileRegistry) walkSymlink(path, name string, target os.FileInfo, ancestors []os.FileInfo, files map[string]FileInfo) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		if info, err := os.Stat(dir); err == nil {
+			ancestors = append(ancestors[:len(ancestors):len(ancestors)], info)
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	for _, ancestor := range ancestors {
+		if os.SameFile(ancestor, target) {
+			registry.skip(fmt.Errorf("Error following symlink %s: cycle detected", name))
+			return nil
+		}
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		registry.skip(fmt.Errorf("Error following symlink %s: %w", name, err))
+		return nil
+	}
+	return registry.walk(resolved, name, append(ancestors, target), files)
+}
+
+// skip passes the error of a skipped path to OnSkip.
+func (registry FileRegistry) skip(err error) {
+	if registry.OnSkip != nil {
+		registry.OnSkip(err)
+	}
+}
+
+// depth returns the number of elements of the relative path name.
+func depth(name string) int {
+	return strings.Count(filepath.ToSlash(name), "/") + 1
+}
+
+// matchAllowList reports whether the file with the given name is tracked.
+func (registry FileRegistry) matchAllowList(name string) bool {
+	return len(registry.AllowList) == 0 || matchPatterns(registry.AllowList, name)
+}
+
+// includedDir reports whether the files of the dir with the given name are
+// tracked according to IncludeDirs: the dir or one of its ancestors matches
+// an include pattern. Dirs that could contain a matching dir are walked
+// without tracking their files.
+func (registry FileRegistry) includedDir(name string) bool {
+	if len(registry.IncludeDirs) == 0 {
+		return true
+	}
+	for dir := name; dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if matchPatterns(registry.IncludeDirs, filepath.ToSlash(dir)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDirPrefix reports whether any of the patterns could match a file under
+// the dir with the given name.
+func matchDirPrefix(patterns []string, dir string) bool {
+	dirParts := strings.Split(filepath.ToSlash(dir), "/")
+
+	for _, pattern := range patterns {
+		patternParts := strings.Split(filepath.ToSlash(pattern), "/")
+		if matchPrefixParts(patternParts, dirParts) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPrefixParts(patternParts, dirParts []string) bool {
+	for i, dirPart := range dirParts {
+		if i >= len(patternParts)-1 {
+			// The last part of the pattern matches the file itself.
+			return false
+		}
+		if patternParts[i] == "**" {
+			return true
+		}
+		if ok, _ := doublestar.Match(patternParts[i], dirPart); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns the change events between the prev and curr snapshots sorted
+// by path.
+func (registry FileRegistry) Diff(prev, curr map[string]FileInfo) []FileEvent {
+	if registry.CaseInsensitive {
+		return registry.diffCaseInsensitive(prev, curr)
+	}
+
+	events := []FileEvent{}
+	for name, file := range curr {
+		if file.Oversized {
+			continue
+		}
+		prevFile, ok := prev[name]
+		if !ok {
+			events = append(events, FileEvent{Path: name, Kind: Added})
+			continue
+		}
+		if registry.changed(prevFile, file) {
+			events = append(events, FileEvent{Path: name, Kind: Modified})
+		}
+	}
+	for name, file := range prev {
+		if _, ok := curr[name]; !ok && !file.Oversized {
+			events = append(events, FileEvent{Path: name, Kind: Deleted})
+		}
+	}
+
+	sortEvents(events)
+	return events
+}
+
+// diffCaseInsensitive returns the change events like Diff does, matching the
+// paths of prev and curr case-insensitively. The events have the paths of curr,
+// except for the deleted files.
+func (registry FileRegistry) diffCaseInsensitive(prev, curr map[string]FileInfo) []FileEvent {
+	prevByKey := make(map[string]FileInfo, len(prev))
+	for name, file := range prev {
+		prevByKey[strings.ToLower(name)] = file
+	}
+	currKeys := make(map[string]struct{}, len(curr))
+
+	events := []FileEvent{}
+	for name, file := range curr {
+		key := strings.ToLower(name)
+		currKeys[key] = struct{}{}
+		if file.Oversized {
+			continue
+		}
+		prevFile, ok := prevByKey[key]
+		if !ok {
+			events = append(events, FileEvent{Path: name, Kind: Added})
+			continue
+		}
+		if registry.changed(prevFile, file) {
+			events = append(events, FileEvent{Path: name, Kind: Modified})
+		}
+	}
+	for name, file := range prev {
+		if _, ok := currKeys[strings.ToLower(name)]; !ok && !file.Oversized {
+			events = append(events, FileEvent{Path: name, Kind: Deleted})
+		}
+	}
+
+	sortEvents(events)
+	return events
+}
+
+// sortEvents sorts the events by path.
+func sortEvents(events []FileEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+}
+
+// changed reports whether the file changed between the prev and curr states.
+func (registry FileRegistry) changed(prev, curr FileInfo) bool {
+	if registry.UseHash {
+		return prev.Hash != curr.Hash
+	}
+	return !prev.ModTime.Equal(curr.ModTime)
+}
+
+// hashFile returns the SHA-256 hash of the content of the file at path.
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+
+	file, err := os.Open(path)
+	if err != nil {
+		return hash, err
+	}
+	defer file.Close()
+
+	return hashReader(file)
+}
+
+// hashReader returns the SHA-256 hash of the content of r.
+func hashReader(r io.Reader) ([sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return hash, err
+	}
+	copy(hash[:], h.Sum(nil))
+	return hash, nil
+}
+
+// Merge returns a new snapshot with the files of base, overridden by the files
+// of overlay.
+func (registry FileRegistry) Merge(base, overlay map[string]FileInfo) map[string]FileInfo {
+	merged := make(map[string]FileInfo, len(base)+len(overlay))
+	for name, file := range base {
+		merged[name] = file
+	}
+	for name, file := range overlay {
+		merged[name] = file
+	}
+	return merged
+}
@@ -0,0 +1,533 @@
+package revolver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileRegistry(t *testing.T) {
+	t.Run("Snapshot", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		nested := createTempNestedDirs(t, dir)
+		excluded := filepath.Join(dir, "excluded")
+		if err := os.MkdirAll(excluded, 0700); err != nil {
+			t.Fatalf("Cannot create dir: %v", err)
+		}
+
+		file := createTempFile(t, dir, "")
+		nestedFile := relative(t, dir, filepath.Join(nested, createTempFile(t, nested, "")))
+		createTempFile(t, excluded, "")
+
+		files, err := FileRegistry{ExcludeDirs: []string{"excluded"}}.Snapshot(dir)
+		if err != nil {
+			t.Fatalf("Snapshot() err should be nil; got: %v", err)
+		}
+
+		names := []string{}
+		for name := range files {
+			names = append(names, name)
+		}
+		if expected := []string{file, nestedFile}; !equals(expected, names) {
+			t.Errorf("Snapshot() files should be: %v; got: %v", expected, names)
+		}
+	})
+
+	t.Run("Snapshot allow list", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		for _, name := range []string{"cmd/app", "cmd/other", "docs"} {
+			if err := os.MkdirAll(filepath.Join(dir, name), 0700); err != nil {
+				t.Fatalf("Cannot create dir: %v", err)
+			}
+		}
+		for _, name := range []string{"main.go", "README.md", "cmd/app/main.go", "cmd/other/main.go", "docs/index.md"} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+				t.Fatalf("Cannot create file: %v", err)
+			}
+		}
+
+		registry := FileRegistry{AllowList: []string{"*.go", "cmd/app/*.go"}}
+		files, err := registry.Snapshot(dir)
+		if err != nil {
+			t.Fatalf("Snapshot() err should be nil; got: %v", err)
+		}
+
+		names := []string{}
+		for name := range files {
+			names = append(names, filepath.ToSlash(name))
+		}
+		if expected := []string{"main.go", "cmd/app/main.go"}; !equals(expected, names) {
+			t.Errorf("Snapshot() files should be: %v; got: %v", expected, names)
+		}
+	})
+
+	t.Run("Snapshot options", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		for _, name := range []string{
+			"main.go",
+			".#main.go",
+			".git/HEAD",
+			"a/a.go",
+			"a/.DS_Store",
+			"a/b/b.go",
+			"a/b/c/c.go",
+		} {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatalf("Cannot create dir: %v", err)
+			}
+			if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+				t.Fatalf("Cannot write file: %v", err)
+			}
+		}
+
+		type testCase struct {
+			registry FileRegistry
+			expected []string
+		}
+		for name, tc := range map[string]testCase{
+			"default": {
+				registry: FileRegistry{},
+				expected: []string{"main.go", ".#main.go", ".git/HEAD", "a/a.go", "a/.DS_Store", "a/b/b.go", "a/b/c/c.go"},
+			},
+			"skip hidden": {
+				registry: FileRegistry{SkipHidden: true},
+				expected: []string{"main.go", "a/a.go", "a/b/b.go", "a/b/c/c.go"},
+			},
+			"max depth": {
+				registry: FileRegistry{MaxDepth: 2},
+				expected: []string{"main.go", ".#main.go", ".git/HEAD", "a/a.go", "a/.DS_Store"},
+			},
+			"skip hidden and max depth": {
+				registry: FileRegistry{SkipHidden: true, MaxDepth: 3},
+				expected: []string{"main.go", "a/a.go", "a/b/b.go"},
+			},
+			"include dirs": {
+				registry: FileRegistry{IncludeDirs: []string{"a/b"}},
+				expected: []string{"main.go", ".#main.go", "a/b/b.go", "a/b/c/c.go"},
+			},
+			"include dirs pattern": {
+				registry: FileRegistry{IncludeDirs: []string{"**/c"}},
+				expected: []string{"main.go", ".#main.go", "a/b/c/c.go"},
+			},
+			"include and exclude dirs": {
+				registry: FileRegistry{IncludeDirs: []string{"a/b"}, ExcludeDirs: []string{"a/b/c"}},
+				expected: []string{"main.go", ".#main.go", "a/b/b.go"},
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				files, err := tc.registry.Snapshot(dir)
+				if err != nil {
+					t.Fatalf("Snapshot() err should be nil; got: %v", err)
+				}
+				names := []string{}
+				for name := range files {
+					names = append(names, filepath.ToSlash(name))
+				}
+				if !equals(tc.expected, names) {
+					t.Errorf("Snapshot() files should be: %v; got: %v", tc.expected, names)
+				}
+			})
+		}
+
+		detect := DetectWithOptions(dir, nil, DetectOptions{SkipHidden: true, MaxDepth: 1})
+		if changes := detect(); !equals([]string{"main.go"}, changes) {
+			t.Errorf("DetectWithOptions() should return: %v; got: %v", []string{"main.go"}, changes)
+		}
+	})
+
+	t.Run("Snapshot max file size", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		small, large := filepath.Join(dir, "main.go"), filepath.Join(dir, "app.wasm")
+		if err := ioutil.WriteFile(small, make([]byte, 10), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+		if err := ioutil.WriteFile(large, make([]byte, 100), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+
+		registry := FileRegistry{MaxFileSize: 50, UseHash: true}
+		prev, err := registry.Snapshot(dir)
+		if err != nil {
+			t.Fatalf("Snapshot() err should be nil; got: %v", err)
+		}
+		if prev["main.go"].Oversized || !prev["app.wasm"].Oversized || len(prev) != 2 {
+			t.Errorf("Snapshot() should record app.wasm as oversized; got: %v", prev)
+		}
+
+		if err := ioutil.WriteFile(large, make([]byte, 200), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+		curr, err := registry.Snapshot(dir)
+		if err != nil {
+			t.Fatalf("Snapshot() err should be nil; got: %v", err)
+		}
+		if events := registry.Diff(prev, curr); len(events) != 0 {
+			t.Errorf("Changes of large files should not be detected; got: %v", events)
+		}
+	})
+
+	t.Run("Snapshot file crossing max file size", func(t *testing.T) {
+		type testCase struct {
+			size     int
+			expected []FileEvent
+		}
+		for name, tc := range map[string]testCase{
+			"grows past": {
+				size:     100,
+				expected: []FileEvent{},
+			},
+			"stays below": {
+				size:     20,
+				expected: []FileEvent{{Path: "main.go", Kind: Modified}},
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				dir, teardown := createTempDir(t)
+				defer teardown()
+
+				path := filepath.Join(dir, "main.go")
+				if err := ioutil.WriteFile(path, make([]byte, 10), 0600); err != nil {
+					t.Fatalf("Cannot write file: %v", err)
+				}
+				for _, registry := range []FileRegistry{
+					{MaxFileSize: 50, UseHash: true},
+					{MaxFileSize: 50, CaseInsensitive: true, UseHash: true},
+				} {
+					prev, err := registry.Snapshot(dir)
+					if err != nil {
+						t.Fatalf("Snapshot() err should be nil; got: %v", err)
+					}
+					if err := ioutil.WriteFile(path, make([]byte, tc.size), 0600); err != nil {
+						t.Fatalf("Cannot write file: %v", err)
+					}
+					curr, err := registry.Snapshot(dir)
+					if err != nil {
+						t.Fatalf("Snapshot() err should be nil; got: %v", err)
+					}
+					if events := registry.Diff(prev, curr); !reflect.DeepEqual(events, tc.expected) {
+						t.Errorf("Diff() should return: %v; got: %v", tc.expected, events)
+					}
+					if err := ioutil.WriteFile(path, make([]byte, 10), 0600); err != nil {
+						t.Fatalf("Cannot write file: %v", err)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("Snapshot symlinks", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		for _, name := range []string{"src/main.go", "shared/lib.go", "target.go"} {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				t.Fatalf("Cannot create dir: %v", err)
+			}
+			if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+				t.Fatalf("Cannot write file: %v", err)
+			}
+		}
+		for link, target := range map[string]string{
+			"src/lib":      "../shared",
+			"src/loop":     ".",
+			"src/file.go":  "../target.go",
+			"shared/back":  "../src",
+			"src/dangling": "../not-exists",
+		} {
+			if err := os.Symlink(target, filepath.Join(dir, filepath.FromSlash(link))); err != nil {
+				t.Skipf("Cannot create symlink: %v", err)
+			}
+		}
+		src := filepath.Join(dir, "src")
+
+		type testCase struct {
+			registry FileRegistry
+			expected []string
+			skipped  int
+		}
+		for name, tc := range map[string]testCase{
+			"not followed": {
+				registry: FileRegistry{},
+				expected: []string{"main.go", "lib", "loop", "file.go", "dangling"},
+			},
+			"followed": {
+				registry: FileRegistry{FollowSymlinks: true},
+				expected: []string{"main.go", "lib/lib.go", "file.go", "dangling"},
+				skipped:  2,
+			},
+			"followed with excluded dir": {
+				registry: FileRegistry{FollowSymlinks: true, ExcludeDirs: []string{"lib"}},
+				expected: []string{"main.go", "file.go", "dangling"},
+				skipped:  1,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				skipped := []error{}
+				tc.registry.OnSkip = func(err error) {
+					skipped = append(skipped, err)
+				}
+				files, err := tc.registry.Snapshot(src)
+				if err != nil {
+					t.Fatalf("Snapshot() err should be nil; got: %v", err)
+				}
+				names := []string{}
+				for name := range files {
+					names = append(names, filepath.ToSlash(name))
+				}
+				if !equals(tc.expected, names) {
+					t.Errorf("Snapshot() files should be: %v; got: %v", tc.expected, names)
+				}
+				if len(skipped) != tc.skipped {
+					t.Errorf("Snapshot() should skip %d symlink cycles; got: %v", tc.skipped, skipped)
+				}
+			})
+		}
+
+		t.Run("target changes", func(t *testing.T) {
+			registry := FileRegistry{FollowSymlinks: true, OnSkip: func(error) {}}
+			prev, _ := registry.Snapshot(src)
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(filepath.Join(dir, "shared", "lib.go"), later, later); err != nil {
+				t.Fatalf("Cannot change mod time: %v", err)
+			}
+			if err := os.Chtimes(filepath.Join(dir, "target.go"), later, later); err != nil {
+				t.Fatalf("Cannot change mod time: %v", err)
+			}
+			curr, _ := registry.Snapshot(src)
+			expected := []FileEvent{
+				{Path: "file.go", Kind: Modified},
+				{Path: filepath.FromSlash("lib/lib.go"), Kind: Modified},
+			}
+			if events := registry.Diff(prev, curr); !reflect.DeepEqual(events, expected) {
+				t.Errorf("Diff() should be %v; got: %v", expected, events)
+			}
+		})
+	})
+
+	t.Run("matchDirPrefix", func(t *testing.T) {
+		type testCase struct {
+			patterns []string
+			dir      string
+			expected bool
+		}
+
+		testCases := map[string]testCase{
+			"literal prefix":    {patterns: []string{"cmd/app/*.go"}, dir: "cmd", expected: true},
+			"full literal dir":  {patterns: []string{"cmd/app/*.go"}, dir: "cmd/app", expected: true},
+			"other dir":         {patterns: []string{"cmd/app/*.go"}, dir: "cmd/other", expected: false},
+			"file pattern":      {patterns: []string{"*.go"}, dir: "cmd", expected: false},
+			"wildcard dir":      {patterns: []string{"*/main.go"}, dir: "cmd", expected: true},
+			"double star":       {patterns: []string{"**/*.go"}, dir: "cmd/app", expected: true},
+			"inner double star": {patterns: []string{"src/**/*.go"}, dir: "src/a/b", expected: true},
+			"any pattern":       {patterns: []string{"*.go", "docs/*.md"}, dir: "docs", expected: true},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				if got := matchDirPrefix(tc.patterns, tc.dir); got != tc.expected {
+					t.Errorf("matchDirPrefix(%v, %q) should be: %v; got: %v", tc.patterns, tc.dir, tc.expected, got)
+				}
+			})
+		}
+	})
+
+	t.Run("Snapshot not exists", func(t *testing.T) {
+		if _, err := (FileRegistry{}).Snapshot("testdata/not_exists"); err == nil {
+			t.Errorf("Snapshot() err should not be nil")
+		}
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		now := time.Now()
+		prev := map[string]FileInfo{
+			"same":     {ModTime: now},
+			"modified": {ModTime: now},
+			"deleted":  {ModTime: now},
+		}
+		curr := map[string]FileInfo{
+			"same":     {ModTime: now},
+			"modified": {ModTime: now.Add(time.Second)},
+			"added":    {ModTime: now},
+		}
+
+		events := FileRegistry{}.Diff(prev, curr)
+
+		expected := []FileEvent{
+			{Path: "added", Kind: Added},
+			{Path: "deleted", Kind: Deleted},
+			{Path: "modified", Kind: Modified},
+		}
+		if len(events) != len(expected) {
+			t.Fatalf("Diff() should return: %v; got: %v", expected, events)
+		}
+		for i := range events {
+			if events[i] != expected[i] {
+				t.Errorf("Diff() should return: %v; got: %v", expected, events)
+			}
+		}
+	})
+
+	t.Run("Diff hash", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		path := filepath.Join(dir, "main.go")
+		if err := ioutil.WriteFile(path, []byte("package main"), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+
+		registry := FileRegistry{UseHash: true}
+		prev, err := registry.Snapshot(dir)
+		if err != nil {
+			t.Fatalf("Snapshot() err should be nil; got: %v", err)
+		}
+
+		touched := time.Now().Add(time.Minute)
+		if err := os.Chtimes(path, touched, touched); err != nil {
+			t.Fatalf("Cannot touch file: %v", err)
+		}
+		curr, _ := registry.Snapshot(dir)
+		if events := registry.Diff(prev, curr); len(events) != 0 {
+			t.Errorf("Diff() of a touched file should be empty; got: %v", events)
+		}
+		if events := (FileRegistry{}).Diff(prev, curr); len(events) != 1 {
+			t.Errorf("Diff() of a touched file without hashes should return 1 event; got: %v", events)
+		}
+
+		if err := ioutil.WriteFile(path, []byte("package app"), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+		if err := os.Chtimes(path, touched, touched); err != nil {
+			t.Fatalf("Cannot touch file: %v", err)
+		}
+		next, _ := registry.Snapshot(dir)
+		expected := []FileEvent{{Path: "main.go", Kind: Modified}}
+		if events := registry.Diff(curr, next); len(events) != 1 || events[0] != expected[0] {
+			t.Errorf("Diff() of a changed file should return: %v; got: %v", expected, events)
+		}
+	})
+
+	t.Run("Diff case insensitive", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		upper := filepath.Join(dir, "FOO.go")
+		if err := ioutil.WriteFile(upper, []byte("package foo"), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+
+		registry := FileRegistry{CaseInsensitive: true}
+		prev, err := registry.Snapshot(dir)
+		if err != nil {
+			t.Fatalf("Snapshot() err should be nil; got: %v", err)
+		}
+
+		// Save the file under a different case like some editors do.
+		if err := os.Remove(upper); err != nil {
+			t.Fatalf("Cannot remove file: %v", err)
+		}
+		lower := filepath.Join(dir, "foo.go")
+		if err := ioutil.WriteFile(lower, []byte("package bar"), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+		saved := time.Now().Add(time.Minute)
+		if err := os.Chtimes(lower, saved, saved); err != nil {
+			t.Fatalf("Cannot touch file: %v", err)
+		}
+		curr, _ := registry.Snapshot(dir)
+
+		expected := []FileEvent{{Path: "foo.go", Kind: Modified}}
+		if events := registry.Diff(prev, curr); len(events) != 1 || events[0] != expected[0] {
+			t.Errorf("Diff() should return: %v; got: %v", expected, events)
+		}
+		if events := (FileRegistry{}).Diff(prev, curr); len(events) != 2 {
+			t.Errorf("Case sensitive Diff() should return 2 events; got: %v", events)
+		}
+		if events := registry.Diff(curr, map[string]FileInfo{}); len(events) != 1 || events[0].Kind != Deleted {
+			t.Errorf("Diff() should return a deleted event; got: %v", events)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		now := time.Now()
+		base := map[string]FileInfo{
+			"base":       {ModTime: now},
+			"overridden": {ModTime: now},
+		}
+		overlay := map[string]FileInfo{
+			"overridden": {ModTime: now.Add(time.Second)},
+			"overlay":    {ModTime: now},
+		}
+
+		merged := FileRegistry{}.Merge(base, overlay)
+
+		if len(merged) != 3 {
+			t.Errorf("Merge() should have 3 files; got: %v", merged)
+		}
+		if !merged["overridden"].ModTime.Equal(now.Add(time.Second)) {
+			t.Errorf("Merge() should override base with overlay")
+		}
+		if len(base) != 2 {
+			t.Errorf("Merge() should not modify base")
+		}
+	})
+}
+
+// BenchmarkSnapshotMaxDepth compares walking a deep tree fully and with a
+// limited depth. The tree has 6 levels of 4 dirs with 8 files each, ~11k
+// files, which is big enough to show the difference without filling /tmp.
+func BenchmarkSnapshotMaxDepth(b *testing.B) {
+	dir, err := ioutil.TempDir("", "revolver")
+	if err != nil {
+		b.Fatalf("Cannot create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var create func(dir string, level int)
+	create = func(dir string, level int) {
+		for i := 0; i < 8; i++ {
+			if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.go", i)), nil, 0600); err != nil {
+				b.Fatalf("Cannot write file: %v", err)
+			}
+		}
+		if level == 6 {
+			return
+		}
+		for i := 0; i < 4; i++ {
+			sub := filepath.Join(dir, fmt.Sprintf("dir%d", i))
+			if err := os.Mkdir(sub, 0700); err != nil {
+				b.Fatalf("Cannot create dir: %v", err)
+			}
+			create(sub, level+1)
+		}
+	}
+	create(dir, 1)
+
+	for name, maxDepth := range map[string]int{
+		"unlimited":  0,
+		"maxDepth=3": 3,
+	} {
+		b.Run(name, func(b *testing.B) {
+			registry := FileRegistry{MaxDepth: maxDepth}
+			for i := 0; i < b.N; i++ {
+				if _, err := registry.Snapshot(dir); err != nil {
+					b.Fatalf("Snapshot() err should be nil; got: %v", err)
+				}
+			}
+		})
+	}
+}
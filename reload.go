@@ -0,0 +1,99 @@
+package revolver
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+)
+
+// configReloader reloads the config file when it changes.
+type configReloader struct {
+	path    string
+	modTime time.Time
+}
+
+func newConfigReloader(path string) *configReloader {
+	reloader := &configReloader{path: path}
+	if info, err := os.Stat(path); err == nil {
+		reloader.modTime = info.ModTime()
+	}
+	return reloader
+}
+
+// reload returns the config parsed from the config file if the file changed
+// since the last call. If the new config is invalid, the error is logged and
+// the current config is kept.
+func (reloader *configReloader) reload(logger Logger) (*Config, bool) {
+	info, err := os.Stat(reloader.path)
+	if err != nil || info.ModTime().Equal(reloader.modTime) {
+		return nil, false
+	}
+	reloader.modTime = info.ModTime()
+
+	config, err := loadConfigFile(reloader.path)
+	if err != nil {
+		logger.Error(fmt.Errorf("Error reloading config: %w", err))
+		return nil, false
+	}
+	logger.Info("Config file changed, reloading...")
+	return config, true
+}
+
+// loadConfigFile parses the config file at path, validates it and sets the
+// default values.
+func loadConfigFile(path string) (*Config, error) {
+	config, err := ParseConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("Error validating config: %w", err)
+	}
+	config.setDefaults()
+	return config, nil
+}
+
+// reloadActions replaces the prev actions with the next ones. The run commands
+// of the removed and changed actions are stopped and the new and changed
+// actions are run, while the unchanged actions keep running. It returns the
+// number of the last cycle.
+func (r *runner) reloadActions(prev, next []action, cycle int) int {
+	sources := make(map[string]actionSource, len(next))
+	for _, a := range next {
+		sources[a.ID] = a.Source
+	}
+	unchanged := make(map[string]struct{}, len(prev))
+	for _, a := range prev {
+		source, ok := sources[a.ID]
+		if ok && reflect.DeepEqual(source, a.Source) {
+			unchanged[a.ID] = struct{}{}
+			continue
+		}
+		r.stopAction(a.ID)
+	}
+
+	started := []action{}
+	for _, a := range next {
+		if _, ok := unchanged[a.ID]; !ok {
+			started = append(started, a)
+		}
+	}
+	if len(started) == 0 {
+		return cycle
+	}
+	cycle++
+	r.report(r.runCycle(started, cycle), nil)
+	return cycle
+}
+
+// watchesSameFiles reports whether the configs detect the changes of the same
+// files.
+func watchesSameFiles(a, b Config) bool {
+	return a.Dir == b.Dir &&
+		reflect.DeepEqual(a.Dirs, b.Dirs) &&
+		reflect.DeepEqual(a.registry(), b.registry()) &&
+		a.AutoContentFilter == b.AutoContentFilter &&
+		a.Archive == b.Archive &&
+		a.ArchivePath == b.ArchivePath
+}
@@ -0,0 +1,84 @@
+package revolver
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcherReloadConfig(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	starts := filepath.Join(dir, "starts")
+	removed := filepath.Join(dir, "removed")
+	added := filepath.Join(dir, "added")
+	file := filepath.Join(dir, "revolver.yml")
+
+	writeConfig := func(actions string, modTime time.Time) {
+		content := "dir: " + dir + "\n" +
+			"interval: 10ms\n" +
+			"runOnStart: true\n" +
+			"watchConfig: true\n" +
+			"shell: sh -c\n" +
+			"action:\n" +
+			"  - name: server\n" +
+			"    pattern: \"*.go\"\n" +
+			"    run: \"echo start >> " + starts + "; exec sleep 30\"\n" +
+			actions
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatalf("Cannot write config: %v", err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatalf("Cannot touch config: %v", err)
+		}
+	}
+
+	writeConfig("  - name: removed\n    pattern: \"*.go\"\n    build: \"touch "+removed+"\"\n", time.Now())
+
+	config, err := loadConfigFile(file)
+	if err != nil {
+		t.Fatalf("loadConfigFile() err should be nil; got: %v", err)
+	}
+	watcher := newTestWatcher(t, *config)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	waitForPath(t, removed)
+	os.Remove(removed)
+
+	writeConfig("  - name: added\n    pattern: \"*.go\"\n    build: \"touch "+added+"\"\n", time.Now().Add(time.Minute))
+	waitForPath(t, added)
+
+	// Trigger the actions with a change to make sure the removed action
+	// doesn't run anymore.
+	os.Remove(added)
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+	waitForPath(t, added)
+
+	if _, err := os.Stat(removed); err == nil {
+		t.Errorf("Removed action should not run after the reload")
+	}
+	b, _ := ioutil.ReadFile(starts)
+	if n := strings.Count(string(b), "start"); n != 2 {
+		t.Errorf("Unchanged server should only be restarted by the file change: started %d times", n)
+	}
+}
+
+func waitForPath(t *testing.T, path string) {
+	for i := 0; i < 200; i++ {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s should exist", path)
+}
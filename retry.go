@@ -0,0 +1,42 @@
+package revolver
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Retry returns a BuildFunc that calls fn up to n times, sleeping delay
+// between the attempts, until an attempt succeeds. It returns the error of the
+// last attempt if every attempt failed. The failed attempts followed by a
+// retry are logged to os.Stdout.
+func Retry(n int, delay time.Duration, fn BuildFunc) BuildFunc {
+	return retry(n, delay, fn, nil, NewLogger("text", os.Stdout), nil)
+}
+
+// retry returns a BuildFunc like Retry does, logging the failed attempts with
+// logger. If retryIf is not nil, only the errors whose message matches it are
+// retried. It stops retrying when the context of ctx is done, e.g. when the
+// build is canceled.
+func retry(n int, delay time.Duration, fn BuildFunc, retryIf *regexp.Regexp, logger Logger, ctx *contextHolder) BuildFunc {
+	return func() error {
+		for attempt := 1; ; attempt++ {
+			err := fn()
+			if err == nil || attempt >= n {
+				return err
+			}
+			if retryIf != nil && !retryIf.MatchString(errorMessage(err)) {
+				logger.Info("Attempt %d/%d failed with %q, not matching retryIf, not retrying.", attempt, n, errorMessage(err))
+				return err
+			}
+			logger.Error(fmt.Errorf("Attempt %d/%d failed, retrying in %v: %w", attempt, n, delay, err))
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.get().Done():
+				return err
+			}
+		}
+	}
+}
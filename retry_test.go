@@ -0,0 +1,174 @@
+package revolver
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	type testCase struct {
+		n        int
+		retryIf  string
+		failures int
+		calls    int
+		err      bool
+		logged   []string
+	}
+	for name, tc := range map[string]testCase{
+		"success": {
+			n:      3,
+			calls:  1,
+			logged: []string{},
+		},
+		"success after retries": {
+			n:        3,
+			failures: 2,
+			calls:    3,
+			logged:   []string{"error: Attempt 1/3 failed, retrying in 1ms: flaky", "error: Attempt 2/3 failed, retrying in 1ms: flaky"},
+		},
+		"every attempt fails": {
+			n:        2,
+			failures: 5,
+			calls:    2,
+			err:      true,
+			logged:   []string{"error: Attempt 1/2 failed, retrying in 1ms: flaky"},
+		},
+		"retryIf matching": {
+			n:        3,
+			retryIf:  "flaky",
+			failures: 1,
+			calls:    2,
+			logged:   []string{"error: Attempt 1/3 failed, retrying in 1ms: flaky"},
+		},
+		"retryIf not matching": {
+			n:        3,
+			retryIf:  "timeout",
+			failures: 5,
+			calls:    1,
+			err:      true,
+			logged:   []string{`info: Attempt 1/3 failed with "flaky", not matching retryIf, not retrying.`},
+		},
+		"single attempt": {
+			n:        0,
+			failures: 5,
+			calls:    1,
+			err:      true,
+			logged:   []string{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			fn := func() error {
+				calls++
+				if calls <= tc.failures {
+					return errors.New("flaky")
+				}
+				return nil
+			}
+			logger := &recordLogger{lines: []string{}}
+			var retryIf *regexp.Regexp
+			if tc.retryIf != "" {
+				retryIf = regexp.MustCompile(tc.retryIf)
+			}
+
+			if err := retry(tc.n, time.Millisecond, fn, retryIf, logger, nil)(); (err != nil) != tc.err {
+				t.Errorf("Build err should be %v; got: %v", tc.err, err)
+			}
+			if calls != tc.calls {
+				t.Errorf("Build should be called %d times; got: %d", tc.calls, calls)
+			}
+			if !reflect.DeepEqual(logger.lines, tc.logged) {
+				t.Errorf("Logged should be %q; got: %q", tc.logged, logger.lines)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	holder := &contextHolder{}
+	holder.Set(ctx)
+
+	calls := 0
+	build := retry(3, time.Hour, func() error {
+		calls++
+		cancel()
+		return errors.New("flaky")
+	}, nil, NewLogger("text", ioutil.Discard), holder)
+
+	if err := build(); err == nil {
+		t.Errorf("Build err should not be nil")
+	}
+	if calls != 1 {
+		t.Errorf("Build should not be retried after it is canceled; got: %d calls", calls)
+	}
+}
+
+func TestActionRetry(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	actions, err := parseActions(Config{
+		Dir:    dir,
+		Shell:  "sh -c",
+		Logger: NewLogger("text", ioutil.Discard),
+		Actions: []Action{{
+			WorkingDir:    dir,
+			BuildCommands: []string{"echo attempt >> log; test $(wc -l < log) -ge 3"},
+			Retry:         3,
+			RetryDelay:    time.Millisecond,
+		}},
+	})
+	if err != nil {
+		t.Fatalf("parseActions() err should be nil; got: %v", err)
+	}
+	if _, err := Run(actions[0].BuildFuncs, nil); err != nil {
+		t.Errorf("Run() err should be nil; got: %v", err)
+	}
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatalf("Cannot read log: %v", err)
+	}
+	if expected := "attempt\nattempt\nattempt\n"; string(log) != expected {
+		t.Errorf("Log should be %q; got: %q", expected, log)
+	}
+}
+
+func TestActionRetryIf(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	actions, err := parseActions(Config{
+		Dir:    dir,
+		Shell:  "sh -c",
+		Logger: NewLogger("text", ioutil.Discard),
+		Actions: []Action{{
+			WorkingDir:    dir,
+			BuildCommands: []string{"echo attempt >> log; false"},
+			Retry:         3,
+			RetryDelay:    time.Millisecond,
+			RetryIf:       "timeout",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("parseActions() err should be nil; got: %v", err)
+	}
+	if _, err := Run(actions[0].BuildFuncs, nil); err == nil {
+		t.Errorf("Run() err should not be nil")
+	}
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	if err != nil {
+		t.Fatalf("Cannot read log: %v", err)
+	}
+	if expected := "attempt\n"; string(log) != expected {
+		t.Errorf("Errors not matching retryIf should not be retried; log should be %q; got: %q", expected, log)
+	}
+}
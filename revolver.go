@@ -1,17 +1,28 @@
 package revolver
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/bmatcuk/doublestar"
-	"github.com/logrusorgru/aurora"
 	"gopkg.in/yaml.v2"
 )
 
@@ -28,52 +39,183 @@ func matchPatterns(patterns []string, name string) bool {
 type DetectFunc func() []string
 
 // Detect returns a DetectFunc that will walk the filesystem from the given dir
-// recursively, skipping the excludeDirs and return the changed files.
+// recursively, skipping the excludeDirs and the paths matching the patterns of
+// the dir's IgnoreFile, and return the changed files.
 func Detect(dir string, excludeDirs []string) DetectFunc {
-	prev := make(map[string]os.FileInfo)
+	return detectFiles(dir, FileRegistry{ExcludeDirs: excludeDirs})
+}
+
+// DetectOptions are the options of DetectWithOptions.
+type DetectOptions struct {
+	// SkipHidden skips the files and directories whose name starts with a
+	// dot, e.g. the temporary files of editors.
+	SkipHidden bool
+	// MaxDepth is the maximum depth of the detected files, the files of dir
+	// being at depth 1. 0 means unlimited.
+	MaxDepth int
+	// MaxFileSize skips the files bigger than the given size in bytes. 0
+	// means unlimited.
+	MaxFileSize int64
+	// OwnedByCurrentUser skips the files that aren't owned by the user
+	// running revolver. It has no effect on Windows.
+	OwnedByCurrentUser bool
+	// FollowSymlinks detects the files of the directories that symlinks
+	// point to. Symlink cycles are skipped.
+	FollowSymlinks bool
+}
+
+// DetectWithOptions returns a DetectFunc like Detect does, configured by opts.
+func DetectWithOptions(dir string, excludeDirs []string, opts DetectOptions) DetectFunc {
+	return detectFiles(dir, FileRegistry{
+		ExcludeDirs:        excludeDirs,
+		SkipHidden:         opts.SkipHidden,
+		MaxDepth:           opts.MaxDepth,
+		MaxFileSize:        opts.MaxFileSize,
+		OwnedByCurrentUser: opts.OwnedByCurrentUser,
+		FollowSymlinks:     opts.FollowSymlinks,
+	})
+}
+
+func detectFiles(dir string, registry FileRegistry) DetectFunc {
+	return changedPaths(detectEvents(dir, registry))
+}
 
+// changedPaths returns a DetectFunc returning the paths of the change events
+// detected by detect.
+func changedPaths(detect DetectEventsFunc) DetectFunc {
 	return func() []string {
-		changed := []string{}
-		curr := make(map[string]os.FileInfo)
+		return eventPaths(detect())
+	}
+}
 
-		filepath.Walk(dir, func(path string, file os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+// eventPaths returns the paths of the events.
+func eventPaths(events []ChangeEvent) []string {
+	paths := []string{}
+	for _, event := range events {
+		paths = append(paths, event.Path)
+	}
+	return paths
+}
 
-			name, err := filepath.Rel(dir, path)
-			if err != nil {
-				return err
-			}
+// keepEvents returns the events whose path is one of the paths.
+func keepEvents(events []ChangeEvent, paths []string) []ChangeEvent {
+	keep := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		keep[path] = true
+	}
+	kept := []ChangeEvent{}
+	for _, event := range events {
+		if keep[event.Path] {
+			kept = append(kept, event)
+		}
+	}
+	return kept
+}
 
-			if file.IsDir() {
-				if matchPatterns(excludeDirs, name) {
-					return filepath.SkipDir
-				}
-				return nil
-			}
+// DetectFileList returns a DetectFunc that detects the changes of the given
+// files only, without walking any directory, which is much faster when only a
+// few files matter (ex: go.mod and go.sum). Relative paths are resolved
+// against dir, and the changed files are returned as given.
+func DetectFileList(dir string, files []string) DetectFunc {
+	return detectFileList(dir, files, FileRegistry{})
+}
 
-			curr[name] = file
+func detectFileList(dir string, files []string, registry FileRegistry) DetectFunc {
+	return changedPaths(detectFileListEvents(dir, files, registry))
+}
 
-			prevFile, ok := prev[name]
-			if !ok {
-				changed = append(changed, name)
-				return nil
-			}
-			if prevFile.ModTime() != file.ModTime() {
-				changed = append(changed, name)
-				return nil
-			}
+func detectFileListEvents(dir string, files []string, registry FileRegistry) DetectEventsFunc {
+	prev := make(map[string]FileInfo)
 
-			return nil
-		})
+	return func() []ChangeEvent {
+		curr := registry.SnapshotFiles(dir, files)
+		changed := registry.Diff(prev, curr)
+		prev = curr
+		return changed
+	}
+}
+
+// DetectDirs returns a DetectFunc that detects the changes in multiple root
+// directories like Detect does. The changed files are prefixed with their root
+// directory. The excludeDirs are matched relative to each root directory.
+func DetectDirs(dirs []string, excludeDirs []string) DetectFunc {
+	return detectDirs(dirs, FileRegistry{ExcludeDirs: excludeDirs})
+}
 
-		for name := range prev {
-			if _, ok := curr[name]; !ok {
-				changed = append(changed, name)
+func detectDirs(dirs []string, registry FileRegistry) DetectFunc {
+	return changedPaths(joinDirs(dirs, func(dir string) DetectEventsFunc {
+		return detectEvents(dir, registry)
+	}))
+}
+
+// joinDirs returns a DetectEventsFunc returning the change events of the
+// DetectEventsFuncs that newDetect returns for each dir, joined to the dir.
+func joinDirs(dirs []string, newDetect func(dir string) DetectEventsFunc) DetectEventsFunc {
+	detects := make([]DetectEventsFunc, len(dirs))
+	for i, dir := range dirs {
+		detects[i] = newDetect(dir)
+	}
+
+	return func() []ChangeEvent {
+		changed := []ChangeEvent{}
+		for i, detect := range detects {
+			for _, event := range detect() {
+				event.Path = filepath.Join(dirs[i], event.Path)
+				changed = append(changed, event)
 			}
 		}
+		return changed
+	}
+}
+
+// ChangeKind is the kind of a change of a file.
+type ChangeKind int
+
+// The kinds of changes.
+const (
+	Added ChangeKind = iota
+	Modified
+	Deleted
+)
+
+func (kind ChangeKind) String() string {
+	switch kind {
+	case Added:
+		return "added"
+	case Modified:
+		return "modified"
+	case Deleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(kind))
+}
+
+// ChangeEvent describes a change of a file.
+type ChangeEvent struct {
+	Path string     `json:"path"`
+	Kind ChangeKind `json:"kind"`
+}
+
+// DetectEventsFunc detects changes in a filesystem and returns the change events.
+type DetectEventsFunc func() []ChangeEvent
+
+// DetectEvents returns a DetectEventsFunc that will walk the filesystem from the
+// given dir recursively, skipping the excludeDirs and return the change events.
+func DetectEvents(dir string, excludeDirs []string) DetectEventsFunc {
+	return detectEvents(dir, FileRegistry{ExcludeDirs: excludeDirs})
+}
+
+func detectEvents(dir string, registry FileRegistry) DetectEventsFunc {
+	prev := make(map[string]FileInfo)
+
+	return func() []ChangeEvent {
+		registry := registry
+		if patterns, err := ParseIgnoreFile(filepath.Join(dir, IgnoreFile)); err == nil {
+			registry.IgnorePatterns = append(registry.IgnorePatterns, patterns...)
+		}
 
+		curr, _ := registry.Snapshot(dir)
+		changed := registry.Diff(prev, curr)
 		prev = curr
 		return changed
 	}
@@ -84,17 +226,308 @@ type BuildFunc func() error
 
 // BuildCommand returns a BuildFunc that can execute a command with arguments.
 func BuildCommand(command string, args ...string) BuildFunc {
+	return buildCommand(commandOptions{}, command, args...)
+}
+
+// BuildCommandCapture returns a BuildFunc like BuildCommand does, capturing
+// the output of the command instead of writing it to os.Stdout and os.Stderr.
+// The returned function returns the combined standard output and standard
+// error of the last execution of the BuildFunc.
+func BuildCommandCapture(command string, args ...string) (BuildFunc, func() []byte) {
+	output := &syncBuffer{}
+	build := buildCommand(commandOptions{Stdout: output, Stderr: output}, command, args...)
+	capture := func() error {
+		output.Reset()
+		return build()
+	}
+	return capture, output.Bytes
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Reset empties the buffer.
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+// Bytes returns a copy of the content of the buffer.
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// commandOptions configures the commands created for the actions.
+type commandOptions struct {
+	// Stdin is the standard input of the command. Nil means no input.
+	Stdin io.Reader
+	// Stdout receives the standard output of the command instead of
+	// os.Stdout if it is not nil.
+	Stdout io.Writer
+	// Stderr receives the standard error of the command instead of
+	// os.Stderr if it is not nil.
+	Stderr io.Writer
+	// Prefix is prepended to every line of the standard output and error of
+	// the build and run commands if it is not empty.
+	Prefix string
+	// Output receives a copy of the command's output if it is not nil.
+	Output io.Writer
+	// ErrOutput receives the copy of the command's standard error instead of
+	// Output if it is not nil.
+	ErrOutput io.Writer
+	// Routes send the matching lines of the standard output of build
+	// commands to files. The matching lines are also written to Stdout if
+	// RouteTee is set.
+	Routes   []outputRoute
+	RouteTee bool
+	// Env holds additional environment variables in "key=value" form.
+	Env []string
+	// Dir is the working directory of the command. Empty means the working
+	// directory of revolver.
+	Dir string
+	// Timeout is the maximum duration of a build command. Zero means no limit.
+	Timeout time.Duration
+	// Context cancels the running build commands when it's done, if it is
+	// not nil.
+	Context *contextHolder
+	// StopSignal is sent to a run command to stop it. Nil means kill. The run
+	// commands of actions default to defaultStopSignal.
+	StopSignal os.Signal
+	// StopTimeout is the maximum duration to wait for a run command to exit
+	// after StopSignal is sent, before it is killed. Zero means no limit.
+	StopTimeout time.Duration
+	// MinUptime is the minimum duration a run command should run for. If it
+	// exits by itself before that, EarlyExit is called with the error.
+	MinUptime time.Duration
+	EarlyExit func(err error)
+	// Exited is called when a run command exits by itself, if it is not nil.
+	Exited func()
+	// Restarted is called when a run command is restarted after it exited by
+	// itself, if it is not nil.
+	Restarted func()
+	// MaxRestarts is the maximum number of consecutive restarts of a run
+	// command that exits by itself within the BackoffWindow. The delay before
+	// the nth restart is BackoffBase * 2^(n-1).
+	MaxRestarts   int
+	BackoffBase   time.Duration
+	BackoffWindow time.Duration
+	// RetryIf restricts the restarts to the exits whose error message matches
+	// it. Nil means every exit is restarted.
+	RetryIf *regexp.Regexp
+	// Logger logs the messages of run commands. Defaults to a text Logger
+	// writing to os.Stdout.
+	Logger Logger
+}
+
+func (opts commandOptions) logger() Logger {
+	if opts.Logger == nil {
+		return NewLogger("text", os.Stdout)
+	}
+	return opts.Logger
+}
+
+// stdout returns the standard output of the commands, os.Stdout by default.
+func (opts commandOptions) stdout() io.Writer {
+	if opts.Stdout == nil {
+		return os.Stdout
+	}
+	return opts.Stdout
+}
+
+// stderr returns the standard error of the commands, os.Stderr by default.
+func (opts commandOptions) stderr() io.Writer {
+	if opts.Stderr == nil {
+		return os.Stderr
+	}
+	return opts.Stderr
+}
+
+// backoffWindow returns the minimum uptime after which a run command is
+// considered successfully started. It defaults to MinUptime.
+func (opts commandOptions) backoffWindow() time.Duration {
+	if opts.BackoffWindow > 0 {
+		return opts.BackoffWindow
+	}
+	return opts.MinUptime
+}
+
+// command creates an exec.Cmd configured by the options. The command is killed
+// when ctx is done.
+func (opts commandOptions) command(ctx context.Context, command string, args ...string) *exec.Cmd {
+	stdout, stderr := opts.stdout(), opts.stderr()
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if opts.Output != nil {
+		cmd.Stdout = io.MultiWriter(stdout, opts.Output)
+		cmd.Stderr = io.MultiWriter(stderr, opts.Output)
+	}
+	if opts.ErrOutput != nil {
+		cmd.Stderr = io.MultiWriter(stderr, opts.ErrOutput)
+	}
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
+	}
+	cmd.Dir = opts.Dir
+	return cmd
+}
+
+// contextHolder holds a replaceable context.
+type contextHolder struct {
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+// Set replaces the context of the holder.
+func (h *contextHolder) Set(ctx context.Context) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ctx = ctx
+}
+
+// get returns the context of the holder, or the background context if no
+// context is set.
+func (h *contextHolder) get() context.Context {
+	if h == nil {
+		return context.Background()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.ctx == nil {
+		return context.Background()
+	}
+	return h.ctx
+}
+
+// funcHolder holds a function that can be replaced while it's being called,
+// e.g. by a running command.
+type funcHolder struct {
+	mu sync.Mutex
+	fn func()
+}
+
+// Set replaces the function of the holder.
+func (h *funcHolder) Set(fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fn = fn
+}
+
+// call calls the function of the holder if it's set.
+func (h *funcHolder) call() {
+	h.mu.Lock()
+	fn := h.fn
+	h.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// buildCommand returns a BuildFunc like BuildCommand does, configured by opts.
+func buildCommand(opts commandOptions, command string, args ...string) BuildFunc {
 	return func() error {
-		cmd := exec.Command(command, args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
+		parent := opts.Context.get()
+		ctx := parent
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+
+		cmdOpts, flush := opts.withPrefix()
+		var router *outputRouter
+		if len(opts.Routes) > 0 {
+			router = newOutputRouter(cmdOpts.stdout(), opts.Routes, opts.RouteTee)
+			cmdOpts.Stdout = router
+		}
+
+		// The command isn't bound to ctx, because that would kill only the
+		// command itself, and the processes it starts, e.g. the commands of a
+		// shell, would keep its output open until they exit.
+		cmd := cmdOpts.command(context.Background(), command, args...)
+		err := runProcessGroup(ctx, cmd)
+		if router != nil {
+			if closeErr := router.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}
+		flush()
+		if err != nil {
+			if parent.Err() != nil {
+				err = fmt.Errorf("canceled: %w", parent.Err())
+			} else if ctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("timed out after %v", opts.Timeout)
+			}
 			return fmt.Errorf("Error executing build func: \"%s %s\": %w", command, strings.Join(args, ""), err)
 		}
 		return nil
 	}
 }
 
+// runProcessGroup runs the command in a new process group, and kills the
+// process group when ctx is done.
+func runProcessGroup(ctx context.Context, cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	killed := make(chan struct{})
+	go func() {
+		defer close(killed)
+		select {
+		case <-ctx.Done():
+			killProcess(cmd.Process)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+	<-killed
+	return err
+}
+
+// ExpectArtifacts returns a BuildFunc that checks that the build produced the
+// expected artifacts. The paths can contain patterns, which are satisfied by at
+// least one matching file.
+func ExpectArtifacts(paths ...string) BuildFunc {
+	return expectArtifacts("", paths...)
+}
+
+// expectArtifacts is ExpectArtifacts with the relative paths resolved against
+// dir.
+func expectArtifacts(dir string, paths ...string) BuildFunc {
+	return func() error {
+		for _, path := range paths {
+			pattern := path
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(dir, pattern)
+			}
+			matches, err := doublestar.Glob(pattern)
+			if err != nil {
+				return fmt.Errorf("Error checking artifact: %s: %w", path, err)
+			}
+			if len(matches) == 0 {
+				return fmt.Errorf("expected artifact not produced: %s", path)
+			}
+		}
+		return nil
+	}
+}
+
 // RunFunc is a function that runs like a daemon and can be stopped with the
 // returned stop function.
 type RunFunc func() (stop func(), err error)
@@ -102,20 +535,179 @@ type RunFunc func() (stop func(), err error)
 // RunCommand returns a RunFunc that can start a command line app with arguments.
 // It returns a function that can kill the started process.
 func RunCommand(command string, args ...string) RunFunc {
+	return runCommand(commandOptions{}, command, args...)
+}
+
+// RunCommandGraceful returns a RunFunc like RunCommand does, but the returned
+// stop function sends SIGTERM to the process first and only kills it if it
+// hasn't exited after drainTimeout.
+func RunCommandGraceful(command string, drainTimeout time.Duration, args ...string) RunFunc {
+	return runCommand(commandOptions{StopSignal: syscall.SIGTERM, StopTimeout: drainTimeout}, command, args...)
+}
+
+// runCommand returns a RunFunc like RunCommand does, configured by opts. The
+// returned stop function sends opts.StopSignal to the process (or kills it if
+// it is not set) and waits for it to exit, at most opts.StopTimeout before
+// killing it. If opts.MaxRestarts is set, the process is restarted with an
+// exponential backoff when it exits by itself, with an error matching
+// opts.RetryIf if it is set.
+func runCommand(opts commandOptions, command string, args ...string) RunFunc {
+	name := fmt.Sprintf("%s %s", command, strings.Join(args, " "))
+
 	return func() (func(), error) {
-		cmd := exec.Command(command, args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Start(); err != nil {
-			return nil, fmt.Errorf("Error executing run func: \"%s %s\": %w", command, strings.Join(args, " "), err)
+		current, err := startProcess(opts, command, args...)
+		if err != nil {
+			return nil, fmt.Errorf("Error executing run func: \"%s\": %w", name, err)
 		}
+
+		var mu sync.Mutex
+		stopped := make(chan struct{})
+
+		go func() {
+			p := current
+			failures := 0
+			for {
+				select {
+				case <-stopped:
+					return
+				case <-p.done:
+				}
+
+				if opts.Exited != nil {
+					opts.Exited()
+				}
+				uptime := time.Since(p.started)
+				if opts.MinUptime > 0 && uptime < opts.MinUptime && opts.EarlyExit != nil {
+					opts.EarlyExit(fmt.Errorf("Error executing run func: \"%s\": process exited too quickly after %v", name, uptime.Round(time.Millisecond)))
+				}
+				if opts.MaxRestarts <= 0 {
+					return
+				}
+				if opts.RetryIf != nil && !opts.RetryIf.MatchString(errorMessage(p.err)) {
+					opts.logger().Info("Run func exited with %q, not matching retryIf, not restarting.", errorMessage(p.err))
+					return
+				}
+
+				if window := opts.backoffWindow(); window > 0 && uptime >= window {
+					failures = 0
+				}
+				if failures >= opts.MaxRestarts {
+					opts.logger().Error(fmt.Errorf("Error executing run func: \"%s\": giving up after %d restarts", name, failures))
+					return
+				}
+				delay := opts.BackoffBase << uint(failures)
+				failures++
+				opts.logger().Info("Run func exited, restarting in %v (%d/%d)...", delay, failures, opts.MaxRestarts)
+
+				select {
+				case <-stopped:
+					return
+				case <-time.After(delay):
+				}
+
+				mu.Lock()
+				select {
+				case <-stopped:
+					mu.Unlock()
+					return
+				default:
+				}
+				next, err := startProcess(opts, command, args...)
+				if err != nil {
+					mu.Unlock()
+					opts.logger().Error(fmt.Errorf("Error executing run func: \"%s\": %w", name, err))
+					return
+				}
+				p = next
+				if opts.Restarted != nil {
+					opts.Restarted()
+				}
+				current = p
+				mu.Unlock()
+			}
+		}()
+
 		stop := func() {
-			cmd.Process.Kill()
+			mu.Lock()
+			close(stopped)
+			p := current
+			mu.Unlock()
+
+			if err := p.stop(opts.StopSignal, opts.StopTimeout, opts.logger()); err != nil {
+				opts.logger().Error(fmt.Errorf("Error stopping run func: \"%s\": %w", name, err))
+			}
 		}
 		return stop, nil
 	}
 }
 
+// process is a started run command.
+type process struct {
+	cmd     *exec.Cmd
+	started time.Time
+	done    chan struct{}
+	// err is the error the process exited with. It is set before done is
+	// closed.
+	err error
+}
+
+func startProcess(opts commandOptions, command string, args ...string) (*process, error) {
+	opts, flush := opts.withPrefix()
+	cmd := opts.command(context.Background(), command, args...)
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &process{
+		cmd:     cmd,
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		p.err = cmd.Wait()
+		flush()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// errorMessage returns the message of err, or an empty string if err is nil.
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// stop sends sig to the process, or kills it if sig is nil, and waits for it
+// to exit. If the signal can't be sent or the process doesn't exit within a
+// non-zero timeout, the process is killed.
+func (p *process) stop(sig os.Signal, timeout time.Duration, logger Logger) error {
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+
+	var err error
+	if sig != nil {
+		err = signalProcess(p.cmd.Process, sig, logger)
+	}
+	if sig == nil || err != nil {
+		killProcess(p.cmd.Process)
+	} else if timeout > 0 {
+		select {
+		case <-p.done:
+		case <-time.After(timeout):
+			logger.Info("Run func didn't exit in %v, killing it...", timeout)
+			killProcess(p.cmd.Process)
+		}
+	}
+	<-p.done
+	return err
+}
+
 // Run executes the build and run functions. All build functions are executed
 // before the run function. It returns an error and stops the executions if an
 // error happens. Otherwise it returns a function to stop the run function's execution.
@@ -140,12 +732,70 @@ type FilterFunc func(files []string) bool
 // Filter returns a FilterFunc that can filter files based on include and
 // exclude patterns.
 func Filter(includePatterns, excludePatterns []string) FilterFunc {
+	return filterFunc(globMatcher(includePatterns, excludePatterns))
+}
+
+// AnyFilter returns a FilterFunc that reports whether any of the filters
+// matches the files. Without filters it matches nothing.
+func AnyFilter(filters ...FilterFunc) FilterFunc {
 	return func(files []string) bool {
-		for _, file := range files {
-			if matchPatterns(excludePatterns, file) {
-				continue
+		for _, filter := range filters {
+			if filter(files) {
+				return true
 			}
-			if matchPatterns(includePatterns, file) {
+		}
+		return false
+	}
+}
+
+// AllFilter returns a FilterFunc that reports whether every filter matches the
+// files. Without filters it matches everything.
+func AllFilter(filters ...FilterFunc) FilterFunc {
+	return func(files []string) bool {
+		for _, filter := range filters {
+			if !filter(files) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func matchFile(includePatterns, excludePatterns []string, file string) bool {
+	return !matchPatterns(excludePatterns, file) && matchPatterns(includePatterns, file)
+}
+
+// matchFiles returns the files that match the include patterns and don't
+// match the exclude patterns.
+func matchFiles(includePatterns, excludePatterns, files []string) []string {
+	return selectFiles(globMatcher(includePatterns, excludePatterns), files)
+}
+
+// globMatcher returns a function that reports whether a file matches the
+// include patterns and doesn't match the exclude patterns.
+func globMatcher(includePatterns, excludePatterns []string) func(file string) bool {
+	return func(file string) bool {
+		return matchFile(includePatterns, excludePatterns, file)
+	}
+}
+
+// FilterRegex returns a FilterFunc like Filter does, but the include and
+// exclude patterns are regular expressions. A pattern matches a file if it
+// matches any part of the file's path.
+func FilterRegex(includePatterns, excludePatterns []string) (FilterFunc, error) {
+	match, err := regexMatcher(includePatterns, excludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	return filterFunc(match), nil
+}
+
+// filterFunc returns a FilterFunc that reports whether any of the files
+// matches.
+func filterFunc(match func(file string) bool) FilterFunc {
+	return func(files []string) bool {
+		for _, file := range files {
+			if match(file) {
 				return true
 			}
 		}
@@ -153,8 +803,82 @@ func Filter(includePatterns, excludePatterns []string) FilterFunc {
 	}
 }
 
+// selectFiles returns the files that match.
+func selectFiles(match func(file string) bool, files []string) []string {
+	matched := []string{}
+	for _, file := range files {
+		if match(file) {
+			matched = append(matched, file)
+		}
+	}
+	return matched
+}
+
+// regexMatcher returns a function that reports whether a file matches the
+// include regular expressions and doesn't match the exclude ones.
+func regexMatcher(includePatterns, excludePatterns []string) (func(file string) bool, error) {
+	includes, err := compileRegexps(includePatterns)
+	if err != nil {
+		return nil, err
+	}
+	excludes, err := compileRegexps(excludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	return func(file string) bool {
+		return !matchRegexps(excludes, file) && matchRegexps(includes, file)
+	}, nil
+}
+
+func compileRegexps(patterns []string) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Error compiling pattern: %w", err)
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
+func matchRegexps(regexps []*regexp.Regexp, file string) bool {
+	for _, re := range regexps {
+		if re.MatchString(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterEventsFunc can filter change events.
+type FilterEventsFunc func(events []ChangeEvent) bool
+
+// FilterEvents returns a FilterEventsFunc that can filter change events based
+// on the include and exclude patterns matched against the changed paths.
+func FilterEvents(includePatterns, excludePatterns []string) FilterEventsFunc {
+	filter := Filter(includePatterns, excludePatterns)
+	return func(events []ChangeEvent) bool {
+		files := make([]string, 0, len(events))
+		for _, event := range events {
+			files = append(files, event.Path)
+		}
+		return filter(files)
+	}
+}
+
 type stringArr []string
 
+// has reports whether s contains el.
+func (s stringArr) has(el string) bool {
+	for _, e := range s {
+		if e == el {
+			return true
+		}
+	}
+	return false
+}
+
 // UnmarshalYAML implements the Unmarshaler interface of the yaml pkg.
 func (s *stringArr) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var yamlStringArr []string
@@ -184,33 +908,452 @@ func (s *stringArr) Set(value string) error {
 	return nil
 }
 
-// Action is a block in a Config file
-type Action struct {
-	Name            string    `yaml:"name,omitempty"`
-	Patterns        stringArr `yaml:"pattern,omitempty"`
-	ExcludePatterns stringArr `yaml:"exclude,omitempty"`
-	BuildCommands   stringArr `yaml:"build,omitempty"`
-	RunCommand      string    `yaml:"run,omitempty"`
+// CrossCompileTarget is a platform an Action's build commands are executed for.
+type CrossCompileTarget struct {
+	GOOS      string `yaml:"goos"`
+	GOARCH    string `yaml:"goarch"`
+	OutputDir string `yaml:"outputDir,omitempty"`
 }
 
-// Config holds all the configuration for running revolver.
-type Config struct {
-	Dir         string        `yaml:"dir,omitempty"`
-	ExcludeDirs stringArr     `yaml:"excludeDir,omitempty"`
-	Interval    time.Duration `yaml:"interval,omitempty"`
-	Actions     []Action      `yaml:"action"`
+// env returns the environment variables of the target.
+func (target CrossCompileTarget) env() []string {
+	env := []string{"GOOS=" + target.GOOS, "GOARCH=" + target.GOARCH}
+	if target.OutputDir != "" {
+		env = append(env, "OUTPUT_DIR="+target.OutputDir)
+	}
+	return env
 }
 
-func (config *Config) validate() error {
-	if config.Actions == nil || len(config.Actions) == 0 {
-		return fmt.Errorf("config should have at least one action")
+// ChangedFileCount limits the number of matching changed files that trigger
+// an Action. Zero values mean no limit.
+type ChangedFileCount struct {
+	Min int `yaml:"min,omitempty"`
+	Max int `yaml:"max,omitempty"`
+}
+
+func (count ChangedFileCount) allows(n int) bool {
+	return n >= count.Min && (count.Max == 0 || n <= count.Max)
+}
+
+// Action is a block in a Config file
+type Action struct {
+	Name              string               `yaml:"name,omitempty"`
+	Patterns          stringArr            `yaml:"pattern,omitempty"`
+	ExcludePatterns   stringArr            `yaml:"exclude,omitempty"`
+	PatternType       string               `yaml:"patternType,omitempty"`
+	BuildCommands     stringArr            `yaml:"build,omitempty"`
+	PreBuild          stringArr            `yaml:"preBuild,omitempty"`
+	PostBuild         stringArr            `yaml:"postBuild,omitempty"`
+	RunCommand        string               `yaml:"run,omitempty"`
+	PreStop           string               `yaml:"preStop,omitempty"`
+	PostStop          string               `yaml:"postStop,omitempty"`
+	CrossCompile      []CrossCompileTarget `yaml:"crossCompile,omitempty"`
+	ChangedFileCount  ChangedFileCount     `yaml:"changedFileCount,omitempty"`
+	Timeout           time.Duration        `yaml:"timeout,omitempty"`
+	Retry             int                  `yaml:"retry,omitempty"`
+	RetryDelay        time.Duration        `yaml:"retryDelay,omitempty"`
+	Debounce          time.Duration        `yaml:"debounce,omitempty"`
+	StopSignal        string               `yaml:"stopSignal,omitempty"`
+	StopTimeout       time.Duration        `yaml:"stopTimeout,omitempty"`
+	GracePeriod       time.Duration        `yaml:"gracePeriod,omitempty"`
+	WorkingDir        string               `yaml:"workingDir,omitempty"`
+	OnSuccess         string               `yaml:"onSuccess,omitempty"`
+	OnFailure         string               `yaml:"onFailure,omitempty"`
+	Env               map[string]string    `yaml:"env,omitempty"`
+	EnvFile           string               `yaml:"envFile,omitempty"`
+	MinUptime         time.Duration        `yaml:"minUptime,omitempty"`
+	ReadinessProbe    *ReadinessProbe      `yaml:"readinessProbe,omitempty"`
+	StartTimeout      time.Duration        `yaml:"startTimeout,omitempty"`
+	Shell             string               `yaml:"shell,omitempty"`
+	ExpectedArtifacts stringArr            `yaml:"expectedArtifacts,omitempty"`
+	OutputRouter      []OutputRoute        `yaml:"outputRouter,omitempty"`
+	RouteMode         string               `yaml:"routeMode,omitempty"`
+	MaxRestarts       int                  `yaml:"maxRestarts,omitempty"`
+	RetryIf           string               `yaml:"retryIf,omitempty"`
+	BreakAfter        int                  `yaml:"breakAfter,omitempty"`
+	ResetAfter        time.Duration        `yaml:"resetAfter,omitempty"`
+	MaxConcurrent     int                  `yaml:"maxConcurrent,omitempty"`
+	QueuePolicy       string               `yaml:"queuePolicy,omitempty"`
+	OverlapPolicy     string               `yaml:"overlapPolicy,omitempty"`
+	LogPrefix         string               `yaml:"logPrefix,omitempty"`
+	BackoffBase       time.Duration        `yaml:"backoffBase,omitempty"`
+	BackoffWindow     time.Duration        `yaml:"backoffWindow,omitempty"`
+	Labels            map[string]string    `yaml:"labels,omitempty"`
+	Stage             string               `yaml:"stage,omitempty"`
+	StageCondition    string               `yaml:"stageCondition,omitempty"`
+}
+
+// defaultStopTimeout is the default StopTimeout of an action, after which a
+// run command ignoring the stop signal is killed.
+const defaultStopTimeout = 10 * time.Second
+
+// stopTimeout returns the maximum duration to wait for the run command of the
+// action to exit after the stop signal: the action's GracePeriod or
+// StopTimeout, or the StopTimeout of the config if neither is set, or
+// defaultStopTimeout.
+func (a Action) stopTimeout(config Config) time.Duration {
+	if a.GracePeriod > 0 {
+		return a.GracePeriod
 	}
-	for _, action := range config.Actions {
-		if ((action.BuildCommands == nil) || (len(action.BuildCommands) == 0)) && action.RunCommand == "" {
-			return fmt.Errorf("every action should have at least one run or build command")
-		}
+	if a.StopTimeout > 0 {
+		return a.StopTimeout
 	}
-	return nil
+	if config.StopTimeout > 0 {
+		return config.StopTimeout
+	}
+	return defaultStopTimeout
+}
+
+// defaultStartTimeout is the default StartTimeout of an action.
+const defaultStartTimeout = 30 * time.Second
+
+// startTimeout returns the maximum duration to wait for the run command of
+// the action to become ready.
+func (a Action) startTimeout() time.Duration {
+	if a.StartTimeout > 0 {
+		return a.StartTimeout
+	}
+	return defaultStartTimeout
+}
+
+// queuePolicy returns the QueuePolicy of the action, or its alias
+// OverlapPolicy.
+func (a Action) queuePolicy() string {
+	if a.QueuePolicy != "" {
+		return a.QueuePolicy
+	}
+	return a.OverlapPolicy
+}
+
+// logPrefix returns the prefix of the output lines of the commands of the
+// action, DefaultLogPrefix by default, or an empty string if it's NoLogPrefix.
+func (a Action) logPrefix() string {
+	switch a.LogPrefix {
+	case "":
+		return DefaultLogPrefix
+	case NoLogPrefix:
+		return ""
+	}
+	return a.LogPrefix
+}
+
+// workingDir returns the working directory of the commands of the action.
+// A relative WorkingDir is resolved against the Dir of the config.
+func (a Action) workingDir(config Config) string {
+	if a.WorkingDir == "" || filepath.IsAbs(a.WorkingDir) {
+		return a.WorkingDir
+	}
+	return filepath.Join(config.Dir, a.WorkingDir)
+}
+
+// Config holds all the configuration for running revolver.
+type Config struct {
+	Extends                 stringArr         `yaml:"extends,omitempty"`
+	Dir                     string            `yaml:"dir,omitempty"`
+	Dirs                    stringArr         `yaml:"dirs,omitempty"`
+	Files                   stringArr         `yaml:"files,omitempty"`
+	ExcludeDirs             stringArr         `yaml:"excludeDir,omitempty"`
+	IncludeDirs             stringArr         `yaml:"includeDir,omitempty"`
+	ExcludePatterns         stringArr         `yaml:"excludePattern,omitempty"`
+	AllowList               stringArr         `yaml:"allowList,omitempty"`
+	UseHash                 bool              `yaml:"useHash,omitempty"`
+	InputHash               bool              `yaml:"inputHash,omitempty"`
+	CaseInsensitive         bool              `yaml:"caseInsensitive,omitempty"`
+	SkipHidden              bool              `yaml:"skipHidden,omitempty"`
+	OwnedByCurrentUser      bool              `yaml:"ownedByCurrentUser,omitempty"`
+	FollowSymlinks          bool              `yaml:"followSymlinks,omitempty"`
+	MaxDepth                int               `yaml:"maxDepth,omitempty"`
+	MaxFileSize             int64             `yaml:"maxFileSize,omitempty"`
+	Archive                 bool              `yaml:"archive,omitempty"`
+	ArchivePath             string            `yaml:"archivePath,omitempty"`
+	Interval                time.Duration     `yaml:"interval,omitempty"`
+	IntervalJitter          time.Duration     `yaml:"intervalJitter,omitempty"`
+	MinInterval             time.Duration     `yaml:"minInterval,omitempty"`
+	MaxInterval             time.Duration     `yaml:"maxInterval,omitempty"`
+	StreamOutputURL         string            `yaml:"streamOutputURL,omitempty"`
+	CaptureOutput           bool              `yaml:"captureOutput,omitempty"`
+	Debounce                time.Duration     `yaml:"debounce,omitempty"`
+	AutoContentFilter       bool              `yaml:"autoContentFilter,omitempty"`
+	Parallel                bool              `yaml:"parallel,omitempty"`
+	CircuitBreaker          CircuitBreaker    `yaml:"circuitBreaker,omitempty"`
+	Env                     map[string]string `yaml:"env,omitempty"`
+	EnvFile                 string            `yaml:"envFile,omitempty"`
+	Vars                    map[string]string `yaml:"vars,omitempty"`
+	Shell                   string            `yaml:"shell,omitempty"`
+	RunOnStart              bool              `yaml:"runOnStart,omitempty"`
+	RunOnce                 bool              `yaml:"runOnce,omitempty"`
+	PrintOnly               bool              `yaml:"printOnly,omitempty"`
+	DryRun                  bool              `yaml:"dryRun,omitempty"`
+	CheckForUpdates         bool              `yaml:"checkForUpdates,omitempty"`
+	PrintFormat             string            `yaml:"printFormat,omitempty"`
+	LogFormat               string            `yaml:"logFormat,omitempty"`
+	MaxImmediateRetriggers  int               `yaml:"maxImmediateRetriggers,omitempty"`
+	Selector                string            `yaml:"selector,omitempty"`
+	ChangeVelocityWindow    time.Duration     `yaml:"changeVelocityWindow,omitempty"`
+	ChangeVelocityThreshold float64           `yaml:"changeVelocityThreshold,omitempty"`
+	WatchConfig             bool              `yaml:"watchConfig,omitempty"`
+	StartupProbe            stringArr         `yaml:"startupProbe,omitempty"`
+	AuditLog                string            `yaml:"auditLog,omitempty"`
+	StatusAddr              string            `yaml:"statusAddr,omitempty"`
+	MetricsAddr             string            `yaml:"metricsAddr,omitempty"`
+	WSAddr                  string            `yaml:"wsAddr,omitempty"`
+	SocketPath              string            `yaml:"socketPath,omitempty"`
+	FileTypeIndicators      bool              `yaml:"fileTypeIndicators,omitempty"`
+	FileTypeIndicatorMap    map[string]string `yaml:"fileTypeIndicatorMap,omitempty"`
+	StopTimeout             time.Duration     `yaml:"stopTimeout,omitempty"`
+	CycleBudget             time.Duration     `yaml:"cycleBudget,omitempty"`
+	MutexFile               string            `yaml:"mutexFile,omitempty"`
+	LockTimeout             time.Duration     `yaml:"lockTimeout,omitempty"`
+	StageOrder              stringArr         `yaml:"stageOrder,omitempty"`
+	BeforeCycle             string            `yaml:"beforeCycle,omitempty"`
+	AfterCycle              string            `yaml:"afterCycle,omitempty"`
+	ErrorCorrelation        bool              `yaml:"errorCorrelation,omitempty"`
+	OnError                 string            `yaml:"onError,omitempty"`
+	NotifyCommand           string            `yaml:"notifyCommand,omitempty"`
+	ErrorPattern            string            `yaml:"errorPattern,omitempty"`
+	Actions                 []Action          `yaml:"action"`
+
+	// File is the path of the config file the config was parsed from.
+	File string `yaml:"-"`
+	// Logger logs the messages of revolver. If it is nil, the messages are
+	// written to os.Stdout in the LogFormat.
+	Logger Logger `yaml:"-"`
+	// Detect detects the changed files instead of walking the Dir, e.g. a
+	// fake in tests or a detection based on filesystem notifications. Its
+	// first call should return the initial files. If it is nil, the files are
+	// detected like Detect does, according to the config.
+	Detect DetectFunc `yaml:"-"`
+}
+
+// logger returns config.Logger, or the Logger writing the messages of
+// revolver to os.Stdout in the configured format if it is nil.
+func (config Config) logger() Logger {
+	if config.Logger != nil {
+		return config.Logger
+	}
+	return NewLogger(config.LogFormat, os.Stdout)
+}
+
+// violations returns every violation of the config found before watching.
+func (config *Config) violations() []error {
+	var errs []error
+	if _, err := NewPrintFormatter(config.PrintFormat); err != nil {
+		errs = append(errs, err)
+	}
+	if !validLogFormat(config.LogFormat) {
+		errs = append(errs, fmt.Errorf("unknown log format: %s", config.LogFormat))
+	}
+	if config.Archive && config.ArchivePath == "" {
+		errs = append(errs, fmt.Errorf("archive mode should have an archivePath"))
+	}
+	if len(config.Files) > 0 && len(config.Dirs) > 0 {
+		errs = append(errs, fmt.Errorf("files and dirs should not be used together"))
+	}
+	if config.IntervalJitter < 0 {
+		errs = append(errs, fmt.Errorf("intervalJitter should not be negative"))
+	}
+	if config.MinInterval < 0 {
+		errs = append(errs, fmt.Errorf("minInterval should not be negative"))
+	}
+	if config.MaxInterval < 0 {
+		errs = append(errs, fmt.Errorf("maxInterval should not be negative"))
+	}
+	if config.MinInterval > 0 && config.MaxInterval > 0 && config.MinInterval > config.MaxInterval {
+		errs = append(errs, fmt.Errorf("minInterval should not be greater than maxInterval"))
+	}
+	if config.PrintOnly {
+		return errs
+	}
+	if config.Actions == nil || len(config.Actions) == 0 {
+		errs = append(errs, fmt.Errorf("config should have at least one action"))
+	}
+	if _, err := parseSelector(config.Selector); err != nil {
+		errs = append(errs, err)
+	}
+	if config.StopTimeout < 0 {
+		errs = append(errs, fmt.Errorf("stopTimeout should not be negative"))
+	}
+	if config.LockTimeout < 0 {
+		errs = append(errs, fmt.Errorf("lockTimeout should not be negative"))
+	}
+	for _, command := range []string{config.BeforeCycle, config.AfterCycle} {
+		if _, err := parseHook(config.Shell, command); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if config.ChangeVelocityWindow < 0 || config.ChangeVelocityThreshold < 0 {
+		errs = append(errs, fmt.Errorf("changeVelocityWindow and changeVelocityThreshold should not be negative"))
+	}
+	if config.ErrorCorrelation {
+		if _, err := config.errorPattern(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch config.OnError {
+	case "", "continue", "stop":
+	case "notify":
+		if config.NotifyCommand == "" {
+			errs = append(errs, fmt.Errorf("onError notify should have a notifyCommand"))
+		} else if _, err := parseHook(config.Shell, config.NotifyCommand); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown onError: %s", config.OnError))
+	}
+	if config.MaxDepth < 0 {
+		errs = append(errs, fmt.Errorf("maxDepth should not be negative"))
+	}
+	if config.MaxFileSize < 0 {
+		errs = append(errs, fmt.Errorf("maxFileSize should not be negative"))
+	}
+	if config.CycleBudget < 0 {
+		errs = append(errs, fmt.Errorf("cycleBudget should not be negative"))
+	}
+	if config.MaxImmediateRetriggers < 0 {
+		errs = append(errs, fmt.Errorf("maxImmediateRetriggers should not be negative"))
+	}
+	if config.CircuitBreaker.Threshold < 0 || config.CircuitBreaker.ResetAfter < 0 {
+		errs = append(errs, fmt.Errorf("circuit breaker threshold and resetAfter should not be negative"))
+	}
+	for _, action := range config.Actions {
+		if ((action.BuildCommands == nil) || (len(action.BuildCommands) == 0)) && action.RunCommand == "" {
+			errs = append(errs, fmt.Errorf("every action should have at least one run or build command"))
+		}
+		if action.MaxConcurrent < 0 {
+			errs = append(errs, fmt.Errorf("maxConcurrent should not be negative"))
+		}
+		if action.QueuePolicy != "" && action.OverlapPolicy != "" {
+			errs = append(errs, fmt.Errorf("queuePolicy and overlapPolicy should not be set both"))
+		}
+		if policy := action.queuePolicy(); policy != "" && policy != "queue" && policy != "drop" && policy != "skip" {
+			errs = append(errs, fmt.Errorf("unknown queue policy: %s", policy))
+		}
+		if action.ReadinessProbe != nil {
+			if action.RunCommand == "" {
+				errs = append(errs, fmt.Errorf("readinessProbe should have a run command"))
+			}
+			if _, err := action.ReadinessProbe.runProbe(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if action.StartTimeout < 0 {
+			errs = append(errs, fmt.Errorf("startTimeout should not be negative"))
+		}
+		if action.Retry < 0 || action.RetryDelay < 0 {
+			errs = append(errs, fmt.Errorf("retry and retryDelay should not be negative"))
+		}
+		for _, route := range action.OutputRouter {
+			if route.File == "" {
+				errs = append(errs, fmt.Errorf("outputRouter should have a file"))
+			}
+			if _, err := regexp.Compile(route.Pattern); err != nil {
+				errs = append(errs, fmt.Errorf("invalid outputRouter pattern: %w", err))
+			}
+		}
+		if action.RouteMode != "" && action.RouteMode != "tee" && action.RouteMode != "exclusive" {
+			errs = append(errs, fmt.Errorf("unknown route mode: %s", action.RouteMode))
+		}
+		if action.Debounce < 0 {
+			errs = append(errs, fmt.Errorf("debounce of the action should not be negative"))
+		}
+		if action.MaxRestarts < 0 || action.BackoffBase < 0 || action.BackoffWindow < 0 {
+			errs = append(errs, fmt.Errorf("maxRestarts, backoffBase and backoffWindow should not be negative"))
+		}
+		if _, err := regexp.Compile(action.RetryIf); err != nil {
+			errs = append(errs, fmt.Errorf("invalid retryIf: %w", err))
+		}
+		if action.BreakAfter < 0 || action.ResetAfter < 0 {
+			errs = append(errs, fmt.Errorf("breakAfter and resetAfter should not be negative"))
+		}
+		if len(config.StageOrder) > 0 && !config.StageOrder.has(action.Stage) {
+			errs = append(errs, fmt.Errorf("stage of the action should be in the stageOrder: %q", action.Stage))
+		}
+		if c := action.StageCondition; c != "" && c != StageSuccess && c != StageFailure && c != StageAlways {
+			errs = append(errs, fmt.Errorf("unknown stage condition: %s", c))
+		}
+		if action.PatternType != "" && action.PatternType != "glob" && action.PatternType != "regex" {
+			errs = append(errs, fmt.Errorf("unknown pattern type: %s", action.PatternType))
+		}
+		if _, err := parseSignal(action.StopSignal); err != nil {
+			errs = append(errs, err)
+		}
+		if dir := action.workingDir(*config); dir != "" {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				errs = append(errs, fmt.Errorf("working directory of the action should exist: %s", dir))
+			}
+		}
+		if action.StopTimeout < 0 || action.GracePeriod < 0 {
+			errs = append(errs, fmt.Errorf("stopTimeout and gracePeriod should not be negative"))
+		}
+		if count := action.ChangedFileCount; count.Min < 0 || count.Max < 0 || (count.Max > 0 && count.Min > count.Max) {
+			errs = append(errs, fmt.Errorf("changed file count should have a valid min and max"))
+		}
+		if len(action.CrossCompile) > 0 {
+			if action.RunCommand != "" {
+				errs = append(errs, fmt.Errorf("cross compiled actions should not have a run command"))
+			}
+			for _, target := range action.CrossCompile {
+				if target.GOOS == "" || target.GOARCH == "" {
+					errs = append(errs, fmt.Errorf("every cross compile target should have a goos and a goarch"))
+				}
+			}
+		}
+	}
+	if _, err := parseActions(*config); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// validate returns the first violation of the config.
+func (config *Config) validate() error {
+	if errs := config.violations(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateConfig checks the config without watching and returns every
+// violation. Besides the checks done before watching, it checks that the
+// executables of the build commands can be found.
+func ValidateConfig(config Config) []error {
+	errs := config.violations()
+	// Template errors are reported by parseActions.
+	vars, _ := expandVars(config)
+	for i, a := range config.Actions {
+		id := a.Name
+		if id == "" {
+			id = fmt.Sprintf("%d", i+1)
+		}
+		a, err := interpolate(a, templateData{Dir: config.Dir, ActionName: a.Name, ActionID: id, Vars: vars})
+		if err != nil {
+			continue
+		}
+		shell := a.Shell
+		if shell == "" {
+			shell = config.Shell
+		}
+		for _, command := range a.BuildCommands {
+			cmd, _, err := shellCommand(shell, command)
+			if err != nil {
+				// Reported by parseActions.
+				continue
+			}
+			if strings.ContainsRune(cmd, '/') || strings.ContainsRune(cmd, filepath.Separator) {
+				// Paths are resolved against the working dir instead of PATH.
+				if !filepath.IsAbs(cmd) {
+					cmd = filepath.Join(a.workingDir(config), cmd)
+				}
+				_, err = os.Stat(cmd)
+			} else {
+				_, err = exec.LookPath(cmd)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("[%s] executable of build command \"%s\" not found: %w", id, command, err))
+			}
+		}
+	}
+	return errs
 }
 
 func (config *Config) setDefaults() {
@@ -220,22 +1363,122 @@ func (config *Config) setDefaults() {
 	if config.Interval == 0 {
 		config.Interval = 500 * time.Millisecond
 	}
+	if config.ChangeVelocityWindow == 0 {
+		config.ChangeVelocityWindow = defaultChangeVelocityWindow
+	}
 	for i := 0; i < len(config.Actions); i++ {
 		if config.Actions[i].Patterns == nil || len(config.Actions[i].Patterns) == 0 {
 			config.Actions[i].Patterns = []string{"**/*"}
+			if config.Actions[i].PatternType == "regex" {
+				config.Actions[i].Patterns = []string{".*"}
+			}
 		}
 	}
 }
 
 type simpleConfig struct {
-	Dir         string        `yaml:"dir,omitempty"`
-	ExcludeDirs stringArr     `yaml:"excludeDir,omitempty"`
-	Interval    time.Duration `yaml:"interval,omitempty"`
-
-	Patterns        stringArr `yaml:"pattern,omitempty"`
-	ExcludePatterns stringArr `yaml:"exclude,omitempty"`
-	BuildCommands   stringArr `yaml:"build,omitempty"`
-	RunCommand      string    `yaml:"run,omitempty"`
+	Extends                 stringArr         `yaml:"extends,omitempty"`
+	Dir                     string            `yaml:"dir,omitempty"`
+	Dirs                    stringArr         `yaml:"dirs,omitempty"`
+	Files                   stringArr         `yaml:"files,omitempty"`
+	ExcludeDirs             stringArr         `yaml:"excludeDir,omitempty"`
+	IncludeDirs             stringArr         `yaml:"includeDir,omitempty"`
+	GlobalExcludePatterns   stringArr         `yaml:"excludePattern,omitempty"`
+	AllowList               stringArr         `yaml:"allowList,omitempty"`
+	UseHash                 bool              `yaml:"useHash,omitempty"`
+	InputHash               bool              `yaml:"inputHash,omitempty"`
+	CaseInsensitive         bool              `yaml:"caseInsensitive,omitempty"`
+	SkipHidden              bool              `yaml:"skipHidden,omitempty"`
+	OwnedByCurrentUser      bool              `yaml:"ownedByCurrentUser,omitempty"`
+	FollowSymlinks          bool              `yaml:"followSymlinks,omitempty"`
+	MaxDepth                int               `yaml:"maxDepth,omitempty"`
+	MaxFileSize             int64             `yaml:"maxFileSize,omitempty"`
+	Archive                 bool              `yaml:"archive,omitempty"`
+	ArchivePath             string            `yaml:"archivePath,omitempty"`
+	Interval                time.Duration     `yaml:"interval,omitempty"`
+	IntervalJitter          time.Duration     `yaml:"intervalJitter,omitempty"`
+	MinInterval             time.Duration     `yaml:"minInterval,omitempty"`
+	MaxInterval             time.Duration     `yaml:"maxInterval,omitempty"`
+	StreamOutputURL         string            `yaml:"streamOutputURL,omitempty"`
+	CaptureOutput           bool              `yaml:"captureOutput,omitempty"`
+	Debounce                time.Duration     `yaml:"debounce,omitempty"`
+	AutoContentFilter       bool              `yaml:"autoContentFilter,omitempty"`
+	Parallel                bool              `yaml:"parallel,omitempty"`
+	CircuitBreaker          CircuitBreaker    `yaml:"circuitBreaker,omitempty"`
+	Env                     map[string]string `yaml:"env,omitempty"`
+	EnvFile                 string            `yaml:"envFile,omitempty"`
+	Vars                    map[string]string `yaml:"vars,omitempty"`
+	Shell                   string            `yaml:"shell,omitempty"`
+	RunOnStart              bool              `yaml:"runOnStart,omitempty"`
+	RunOnce                 bool              `yaml:"runOnce,omitempty"`
+	PrintOnly               bool              `yaml:"printOnly,omitempty"`
+	DryRun                  bool              `yaml:"dryRun,omitempty"`
+	CheckForUpdates         bool              `yaml:"checkForUpdates,omitempty"`
+	PrintFormat             string            `yaml:"printFormat,omitempty"`
+	LogFormat               string            `yaml:"logFormat,omitempty"`
+	MaxImmediateRetriggers  int               `yaml:"maxImmediateRetriggers,omitempty"`
+	Selector                string            `yaml:"selector,omitempty"`
+	ChangeVelocityWindow    time.Duration     `yaml:"changeVelocityWindow,omitempty"`
+	ChangeVelocityThreshold float64           `yaml:"changeVelocityThreshold,omitempty"`
+	WatchConfig             bool              `yaml:"watchConfig,omitempty"`
+	StartupProbe            stringArr         `yaml:"startupProbe,omitempty"`
+	AuditLog                string            `yaml:"auditLog,omitempty"`
+	StatusAddr              string            `yaml:"statusAddr,omitempty"`
+	MetricsAddr             string            `yaml:"metricsAddr,omitempty"`
+	WSAddr                  string            `yaml:"wsAddr,omitempty"`
+	SocketPath              string            `yaml:"socketPath,omitempty"`
+	FileTypeIndicators      bool              `yaml:"fileTypeIndicators,omitempty"`
+	FileTypeIndicatorMap    map[string]string `yaml:"fileTypeIndicatorMap,omitempty"`
+
+	Patterns          stringArr            `yaml:"pattern,omitempty"`
+	ExcludePatterns   stringArr            `yaml:"exclude,omitempty"`
+	PatternType       string               `yaml:"patternType,omitempty"`
+	BuildCommands     stringArr            `yaml:"build,omitempty"`
+	PreBuild          stringArr            `yaml:"preBuild,omitempty"`
+	PostBuild         stringArr            `yaml:"postBuild,omitempty"`
+	RunCommand        string               `yaml:"run,omitempty"`
+	PreStop           string               `yaml:"preStop,omitempty"`
+	PostStop          string               `yaml:"postStop,omitempty"`
+	CrossCompile      []CrossCompileTarget `yaml:"crossCompile,omitempty"`
+	ChangedFileCount  ChangedFileCount     `yaml:"changedFileCount,omitempty"`
+	Timeout           time.Duration        `yaml:"timeout,omitempty"`
+	Retry             int                  `yaml:"retry,omitempty"`
+	RetryDelay        time.Duration        `yaml:"retryDelay,omitempty"`
+	StopSignal        string               `yaml:"stopSignal,omitempty"`
+	StopTimeout       time.Duration        `yaml:"stopTimeout,omitempty"`
+	CycleBudget       time.Duration        `yaml:"cycleBudget,omitempty"`
+	MutexFile         string               `yaml:"mutexFile,omitempty"`
+	LockTimeout       time.Duration        `yaml:"lockTimeout,omitempty"`
+	StageOrder        stringArr            `yaml:"stageOrder,omitempty"`
+	BeforeCycle       string               `yaml:"beforeCycle,omitempty"`
+	AfterCycle        string               `yaml:"afterCycle,omitempty"`
+	ErrorCorrelation  bool                 `yaml:"errorCorrelation,omitempty"`
+	OnError           string               `yaml:"onError,omitempty"`
+	NotifyCommand     string               `yaml:"notifyCommand,omitempty"`
+	ErrorPattern      string               `yaml:"errorPattern,omitempty"`
+	GracePeriod       time.Duration        `yaml:"gracePeriod,omitempty"`
+	WorkingDir        string               `yaml:"workingDir,omitempty"`
+	OnSuccess         string               `yaml:"onSuccess,omitempty"`
+	OnFailure         string               `yaml:"onFailure,omitempty"`
+	MinUptime         time.Duration        `yaml:"minUptime,omitempty"`
+	ReadinessProbe    *ReadinessProbe      `yaml:"readinessProbe,omitempty"`
+	StartTimeout      time.Duration        `yaml:"startTimeout,omitempty"`
+	ExpectedArtifacts stringArr            `yaml:"expectedArtifacts,omitempty"`
+	OutputRouter      []OutputRoute        `yaml:"outputRouter,omitempty"`
+	RouteMode         string               `yaml:"routeMode,omitempty"`
+	MaxRestarts       int                  `yaml:"maxRestarts,omitempty"`
+	RetryIf           string               `yaml:"retryIf,omitempty"`
+	BreakAfter        int                  `yaml:"breakAfter,omitempty"`
+	ResetAfter        time.Duration        `yaml:"resetAfter,omitempty"`
+	MaxConcurrent     int                  `yaml:"maxConcurrent,omitempty"`
+	QueuePolicy       string               `yaml:"queuePolicy,omitempty"`
+	OverlapPolicy     string               `yaml:"overlapPolicy,omitempty"`
+	LogPrefix         string               `yaml:"logPrefix,omitempty"`
+	BackoffBase       time.Duration        `yaml:"backoffBase,omitempty"`
+	BackoffWindow     time.Duration        `yaml:"backoffWindow,omitempty"`
+	Labels            map[string]string    `yaml:"labels,omitempty"`
+	Stage             string               `yaml:"stage,omitempty"`
+	StageCondition    string               `yaml:"stageCondition,omitempty"`
 }
 
 func parseSimpleConfig(content []byte) (*Config, error) {
@@ -246,15 +1489,109 @@ func parseSimpleConfig(content []byte) (*Config, error) {
 	}
 
 	return &Config{
-		Dir:         config.Dir,
-		ExcludeDirs: config.ExcludeDirs,
-		Interval:    config.Interval,
+		Dir:                     config.Dir,
+		Extends:                 config.Extends,
+		Dirs:                    config.Dirs,
+		Files:                   config.Files,
+		ExcludeDirs:             config.ExcludeDirs,
+		IncludeDirs:             config.IncludeDirs,
+		ExcludePatterns:         config.GlobalExcludePatterns,
+		AllowList:               config.AllowList,
+		UseHash:                 config.UseHash,
+		InputHash:               config.InputHash,
+		CaseInsensitive:         config.CaseInsensitive,
+		SkipHidden:              config.SkipHidden,
+		OwnedByCurrentUser:      config.OwnedByCurrentUser,
+		FollowSymlinks:          config.FollowSymlinks,
+		MaxDepth:                config.MaxDepth,
+		MaxFileSize:             config.MaxFileSize,
+		Archive:                 config.Archive,
+		ArchivePath:             config.ArchivePath,
+		Interval:                config.Interval,
+		IntervalJitter:          config.IntervalJitter,
+		MinInterval:             config.MinInterval,
+		MaxInterval:             config.MaxInterval,
+		StreamOutputURL:         config.StreamOutputURL,
+		CaptureOutput:           config.CaptureOutput,
+		Debounce:                config.Debounce,
+		AutoContentFilter:       config.AutoContentFilter,
+		Parallel:                config.Parallel,
+		CircuitBreaker:          config.CircuitBreaker,
+		Env:                     config.Env,
+		EnvFile:                 config.EnvFile,
+		Vars:                    config.Vars,
+		Shell:                   config.Shell,
+		RunOnStart:              config.RunOnStart,
+		RunOnce:                 config.RunOnce,
+		PrintOnly:               config.PrintOnly,
+		DryRun:                  config.DryRun,
+		CycleBudget:             config.CycleBudget,
+		MutexFile:               config.MutexFile,
+		LockTimeout:             config.LockTimeout,
+		StageOrder:              config.StageOrder,
+		BeforeCycle:             config.BeforeCycle,
+		AfterCycle:              config.AfterCycle,
+		ErrorCorrelation:        config.ErrorCorrelation,
+		OnError:                 config.OnError,
+		NotifyCommand:           config.NotifyCommand,
+		ErrorPattern:            config.ErrorPattern,
+		CheckForUpdates:         config.CheckForUpdates,
+		PrintFormat:             config.PrintFormat,
+		LogFormat:               config.LogFormat,
+		MaxImmediateRetriggers:  config.MaxImmediateRetriggers,
+		Selector:                config.Selector,
+		ChangeVelocityWindow:    config.ChangeVelocityWindow,
+		ChangeVelocityThreshold: config.ChangeVelocityThreshold,
+		WatchConfig:             config.WatchConfig,
+		StartupProbe:            config.StartupProbe,
+		AuditLog:                config.AuditLog,
+		StatusAddr:              config.StatusAddr,
+		MetricsAddr:             config.MetricsAddr,
+		WSAddr:                  config.WSAddr,
+		SocketPath:              config.SocketPath,
+		FileTypeIndicators:      config.FileTypeIndicators,
+		FileTypeIndicatorMap:    config.FileTypeIndicatorMap,
 		Actions: []Action{
 			{
-				Patterns:        config.Patterns,
-				ExcludePatterns: config.ExcludePatterns,
-				BuildCommands:   config.BuildCommands,
-				RunCommand:      config.RunCommand,
+				Patterns:          config.Patterns,
+				ExcludePatterns:   config.ExcludePatterns,
+				PatternType:       config.PatternType,
+				BuildCommands:     config.BuildCommands,
+				PreBuild:          config.PreBuild,
+				PostBuild:         config.PostBuild,
+				RunCommand:        config.RunCommand,
+				PreStop:           config.PreStop,
+				PostStop:          config.PostStop,
+				CrossCompile:      config.CrossCompile,
+				ChangedFileCount:  config.ChangedFileCount,
+				Timeout:           config.Timeout,
+				Retry:             config.Retry,
+				RetryDelay:        config.RetryDelay,
+				StopSignal:        config.StopSignal,
+				StopTimeout:       config.StopTimeout,
+				GracePeriod:       config.GracePeriod,
+				WorkingDir:        config.WorkingDir,
+				OnSuccess:         config.OnSuccess,
+				OnFailure:         config.OnFailure,
+				MinUptime:         config.MinUptime,
+				OutputRouter:      config.OutputRouter,
+				RouteMode:         config.RouteMode,
+				ReadinessProbe:    config.ReadinessProbe,
+				StartTimeout:      config.StartTimeout,
+				ExpectedArtifacts: config.ExpectedArtifacts,
+				MaxRestarts:       config.MaxRestarts,
+				RetryIf:           config.RetryIf,
+				BreakAfter:        config.BreakAfter,
+				ResetAfter:        config.ResetAfter,
+				MaxConcurrent:     config.MaxConcurrent,
+				QueuePolicy:       config.QueuePolicy,
+				OverlapPolicy:     config.OverlapPolicy,
+				LogPrefix:         config.LogPrefix,
+				BackoffBase:       config.BackoffBase,
+				BackoffWindow:     config.BackoffWindow,
+				Labels:            config.Labels,
+				Stage:             config.Stage,
+				StageCondition:    config.StageCondition,
 			},
 		},
 	}, nil
@@ -285,43 +1622,155 @@ func parseConfig(content []byte) (*Config, error) {
 	return config, nil
 }
 
-// parseConfigFile parses a Config from a yaml file
-func parseConfigFile(path string) (*Config, error) {
+// parseConfigTOML parses a Config from a toml file's content. The document
+// is converted to yaml first, so it's parsed exactly like a yaml config file,
+// e.g. durations can be written as strings like "500ms".
+func parseConfigTOML(content []byte) (*Config, error) {
+	var document map[string]interface{}
+	if _, err := toml.Decode(string(content), &document); err != nil {
+		return nil, fmt.Errorf("Error parsing config: %w", err)
+	}
+	content, err := yaml.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing config: %w", err)
+	}
+	return parseConfig(content)
+}
+
+// parseConfigJSON parses a Config from a json file's content. Like toml, the
+// document is converted to yaml first, so unknown fields are errors too.
+func parseConfigJSON(content []byte) (*Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("Error parsing config: %w", err)
+	}
+	content, err := yaml.Marshal(jsonNumbers(document))
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing config: %w", err)
+	}
+	return parseConfig(content)
+}
+
+// jsonNumbers converts the json numbers of a decoded json document to ints,
+// or floats if they are not integers, so they are marshaled as yaml numbers.
+func jsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, el := range v {
+			v[key] = jsonNumbers(el)
+		}
+	case []interface{}:
+		for i, el := range v {
+			v[i] = jsonNumbers(el)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return value
+}
+
+// ParseConfigBytes parses a Config from content in the given format: yaml,
+// yml, json or toml. Unknown fields are errors in every format. The config is
+// not validated.
+func ParseConfigBytes(content []byte, format string) (*Config, error) {
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		return parseConfig(content)
+	case "json":
+		return parseConfigJSON(content)
+	case "toml":
+		return parseConfigTOML(content)
+	}
+	return nil, fmt.Errorf("Error parsing config: unknown format: %s", format)
+}
+
+// ParseConfigFile parses a Config from a file in the format of its extension:
+// .yaml, .yml, .json or .toml. Files with other extensions are parsed as yaml.
+// If the config extends other config files, they are parsed first and merged
+// with MergeConfigs. The config is not validated.
+func ParseConfigFile(path string) (*Config, error) {
+	return parseConfigFile(path, make(map[string]bool))
+}
+
+// parseConfigFileFormat parses a Config from a file in the format of its
+// extension, without following its extends.
+func parseConfigFileFormat(path string) (*Config, error) {
 	content, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	return parseConfig(content)
+	format := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if format != "json" && format != "toml" {
+		format = "yaml"
+	}
+	config, err := ParseConfigBytes(content, format)
+	if err != nil {
+		return nil, err
+	}
+	config.File = path
+	return config, nil
 }
 
 // ParseFlags parses a Config from command line flags, validates it and sets
-// the default values. If no build(b) or run(r) flags are found it will parse the
-// config from a yaml file based on the configFile(c) flag.
+// the default values. If build(b, build) or run(r, run) flags are found and
+// no configFile(c) flag is set, the config contains a single action made of
+// the flags. Otherwise it will parse the config from a yaml file based on the
+// configFile(c) flag, and the flags that are set override the corresponding
+// fields of the file.
 func ParseFlags(args []string) (*Config, error) {
 	var (
-		configFile, dir, runCommand                           string
-		interval                                              time.Duration
+		configFile, dir, runCommand, printFormat, selector    string
+		interval, debounce                                    time.Duration
 		excludeDirs, patterns, excludePatterns, buildCommands stringArr
+		printOnly, dryRun, upgradeCheck                       bool
 	)
 	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
 	flags.StringVar(&configFile, "c", "revolver.yml", "Path to config file")
 	flags.StringVar(&dir, "d", "", "Directory to watch")
+	flags.StringVar(&dir, "dir", "", "Directory to watch")
+	flags.StringVar(&dir, "watch", "", "Directory to watch")
 	flags.Var(&excludeDirs, "ed", "Excluded directories")
 	flags.DurationVar(&interval, "i", 0, "Poll interval")
+	flags.DurationVar(&interval, "interval", 0, "Poll interval")
+	flags.DurationVar(&debounce, "debounce", 0, "Debounce of the changes")
 	flags.Var(&patterns, "p", "File watch patterns")
+	flags.Var(&patterns, "pattern", "File watch patterns")
 	flags.Var(&excludePatterns, "e", "File watch exclude patterns")
+	flags.Var(&excludePatterns, "exclude", "File watch exclude patterns")
 	flags.Var(&buildCommands, "b", "Build commands")
+	flags.Var(&buildCommands, "build", "Build commands")
 	flags.StringVar(&runCommand, "r", "", "Run command")
+	flags.StringVar(&runCommand, "run", "", "Run command")
+	flags.BoolVar(&printOnly, "print-only", false, "Print the changed files instead of executing actions")
+	flags.StringVar(&printFormat, "print-format", "text", "Format of the printed changes: text, json, nul or csv")
+	flags.BoolVar(&dryRun, "dry-run", false, "Print the commands of the actions instead of executing them")
+	flags.BoolVar(&upgradeCheck, "upgrade-check", false, "Print a notice if a newer version of revolver is available")
+	flags.StringVar(&selector, "selector", "", "Label selector of the actions to run, e.g. \"env=dev,tier!=test\"")
 	if err := flags.Parse(args[1:]); err != nil {
 		return nil, err
 	}
 
 	var config *Config
-	if (buildCommands != nil && len(buildCommands) > 0) || runCommand != "" {
+	if printOnly {
+		config = &Config{
+			Dir:         dir,
+			ExcludeDirs: excludeDirs,
+			Interval:    interval,
+			PrintOnly:   true,
+			PrintFormat: printFormat,
+		}
+	} else if (len(buildCommands) > 0 || runCommand != "") && !isFlagSet(flags, "c") {
 		config = &Config{
 			Dir:         dir,
 			ExcludeDirs: excludeDirs,
 			Interval:    interval,
+			Debounce:    debounce,
 			Actions: []Action{
 				{
 					Patterns:        patterns,
@@ -333,10 +1782,55 @@ func ParseFlags(args []string) (*Config, error) {
 		}
 	} else {
 		var err error
-		config, err = parseConfigFile(configFile)
+		config, err = ParseConfigFile(configFile)
 		if err != nil {
 			return nil, err
 		}
+
+		if dir != "" {
+			config.Dir = dir
+		}
+		if len(excludeDirs) > 0 {
+			config.ExcludeDirs = excludeDirs
+		}
+		if interval > 0 {
+			config.Interval = interval
+		}
+		if debounce > 0 {
+			config.Debounce = debounce
+		}
+		if len(patterns) > 0 || len(excludePatterns) > 0 || len(buildCommands) > 0 || runCommand != "" {
+			switch len(config.Actions) {
+			case 0:
+				config.Actions = []Action{{}}
+			case 1:
+			default:
+				return nil, fmt.Errorf("Error overriding config: action flags need a config with a single action; got: %d", len(config.Actions))
+			}
+			action := &config.Actions[0]
+			if len(patterns) > 0 {
+				action.Patterns = patterns
+			}
+			if len(excludePatterns) > 0 {
+				action.ExcludePatterns = excludePatterns
+			}
+			if len(buildCommands) > 0 {
+				action.BuildCommands = buildCommands
+			}
+			if runCommand != "" {
+				action.RunCommand = runCommand
+			}
+		}
+	}
+
+	if selector != "" {
+		config.Selector = selector
+	}
+	if dryRun {
+		config.DryRun = true
+	}
+	if upgradeCheck {
+		config.CheckForUpdates = true
 	}
 
 	if err := config.validate(); err != nil {
@@ -348,9 +1842,43 @@ func ParseFlags(args []string) (*Config, error) {
 	return config, nil
 }
 
-func parseCommand(command string) (string, []string) {
-	parts := strings.Split(command, " ")
-	return parts[0], parts[1:]
+// isFlagSet reports whether the flag with the given name is set.
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// parseCommand splits the command into the executable and its arguments,
+// respecting quotes and escapes like a shell does.
+func parseCommand(command string) (string, []string, error) {
+	parts, err := shellSplit(command)
+	if err != nil {
+		return "", nil, fmt.Errorf("Error parsing command: \"%s\": %w", command, err)
+	}
+	if len(parts) == 0 {
+		return "", nil, fmt.Errorf("Error parsing command: \"%s\": empty command", command)
+	}
+	return parts[0], parts[1:], nil
+}
+
+// shellCommand returns the command and its arguments for executing command
+// with the shell. If shell is empty, the command is split into arguments.
+// Otherwise the shell is executed with the command as its last argument, e.g.
+// the shell "/bin/sh -c" executes "/bin/sh" "-c" "<command>".
+func shellCommand(shell, command string) (string, []string, error) {
+	if shell == "" {
+		return parseCommand(command)
+	}
+	cmd, args, err := parseCommand(shell)
+	if err != nil {
+		return "", nil, err
+	}
+	return cmd, append(args, command), nil
 }
 
 type action struct {
@@ -359,25 +1887,77 @@ type action struct {
 	Filter     FilterFunc
 	BuildFuncs []BuildFunc
 	RunFunc    RunFunc
+
+	// Match returns the changed files that match the action's patterns.
+	Match func(files []string) []string
+	// ChangedFileCount limits the number of matching files.
+	ChangedFileCount ChangedFileCount
+	// TriggeredBy are the changed files that triggered the action.
+	TriggeredBy []string
+
+	// Output receives a copy of the standard output of the BuildFuncs, and
+	// ErrOutput a copy of their standard error.
+	Output    *sink
+	ErrOutput *sink
+	// Context cancels the running build commands when it's done.
+	Context *contextHolder
+	// Inputs is the hash of the inputs of the last successful build.
+	Inputs *inputHash
+	// Restarted is called when the run command is restarted after it
+	// exited by itself.
+	Restarted *funcHolder
+
+	// OnSuccess and OnFailure are the hooks run after a successful or
+	// failed build. They are nil if the action has no such hook.
+	OnSuccess *hookCommand
+	OnFailure *hookCommand
+
+	// Source is the configuration the action was created from.
+	Source actionSource
 }
 
-func parseActions(config []Action) []action {
-	ids := make(map[string]struct{})
+// hookCommand is a parsed command of a hook.
+type hookCommand struct {
+	Command string
+	Args    []string
+}
 
-	actions := []action{}
-	for i, a := range config {
-		builds := []BuildFunc{}
-		for _, command := range a.BuildCommands {
-			cmd, args := parseCommand(command)
-			builds = append(builds, BuildCommand(cmd, args...))
-		}
+// parseHook parses the command of a hook in the shell. It returns nil if the
+// command is empty.
+func parseHook(shell, command string) (*hookCommand, error) {
+	if command == "" {
+		return nil, nil
+	}
+	cmd, args, err := shellCommand(shell, command)
+	if err != nil {
+		return nil, err
+	}
+	return &hookCommand{Command: cmd, Args: args}, nil
+}
 
-		var run RunFunc
-		if a.RunCommand != "" {
-			cmd, args := parseCommand(a.RunCommand)
-			run = RunCommand(cmd, args...)
-		}
+// actionSource is the configuration of an action. Actions with equal sources
+// run the same commands.
+type actionSource struct {
+	Action      Action
+	Env         []string
+	Shell       string
+	StopTimeout time.Duration
+}
+
+func parseActions(config Config) ([]action, error) {
+	sel, _ := parseSelector(config.Selector)
+	ids := make(map[string]struct{})
+	vars, err := expandVars(config)
+	if err != nil {
+		return nil, err
+	}
+	configEnv, err := config.env()
+	if err != nil {
+		return nil, err
+	}
 
+	actions := []action{}
+	for i, a := range config.Actions {
 		id := a.Name
 		if id == "" {
 			id = fmt.Sprintf("%d", i+1)
@@ -386,63 +1966,1157 @@ func parseActions(config []Action) []action {
 		}
 		ids[a.Name] = struct{}{}
 
-		actions = append(actions, action{
-			ID:         id,
-			Name:       a.Name,
-			Filter:     Filter(a.Patterns, a.ExcludePatterns),
-			BuildFuncs: builds,
-			RunFunc:    run,
-		})
-	}
-	return actions
-}
-
-// Watch runs commands based on file changes.
-func Watch(config Config) error {
-	detect := Detect(config.Dir, config.ExcludeDirs)
-
-	actions := parseActions(config.Actions)
-
-	var err error
-	stopFuncs := make(map[string]func())
-
-	for {
-		changes := detect()
-		if len(changes) == 0 {
-			time.Sleep(config.Interval)
+		if !sel.matches(a.Labels) {
 			continue
 		}
+		a.Patterns, a.ExcludePatterns = splitNegations(a)
 
-		for _, action := range actions {
-			if ok := action.Filter(changes); !ok {
-				continue
-			}
+		// Later variables override the earlier ones with the same key.
+		actionEnv, err := envFileList(a.EnvFile)
+		if err != nil {
+			return nil, err
+		}
+		env := append(append(configEnv[:len(configEnv):len(configEnv)], actionEnv...), envList(a.Env)...)
 
-			if stop, ok := stopFuncs[action.ID]; ok && stop != nil {
-				stop()
-				printInfo("[%s] Stopping...", action.ID)
+		if len(a.CrossCompile) == 0 {
+			interpolated, err := interpolate(a, templateData{Dir: config.Dir, ActionName: a.Name, ActionID: id, Vars: vars})
+			if err != nil {
+				return nil, err
 			}
-
-			stopFuncs[action.ID], err = Run(action.BuildFuncs, action.RunFunc)
+			action, err := newAction(config, id, interpolated, env)
 			if err != nil {
-				printErr(err)
-				continue
+				return nil, err
+			}
+			actions = append(actions, action)
+			continue
+		}
+		for _, target := range a.CrossCompile {
+			targetID := fmt.Sprintf("%s-%s-%s", id, target.GOOS, target.GOARCH)
+			interpolated, err := interpolate(a, templateData{Dir: config.Dir, ActionName: a.Name, ActionID: targetID, Vars: vars})
+			if err != nil {
+				return nil, err
+			}
+			action, err := newAction(config, targetID, interpolated, append(env[:len(env):len(env)], target.env()...))
+			if err != nil {
+				return nil, err
 			}
-			printSuccess("[%s] Built successfully.", action.ID)
+			actions = append(actions, action)
 		}
-
-		time.Sleep(config.Interval)
 	}
+	return actions, nil
 }
 
-func printSuccess(msg string, args ...interface{}) {
-	fmt.Println(aurora.Sprintf(aurora.Green(msg), args...))
+// splitNegations moves the patterns of the action starting with "!" to its
+// exclude patterns. If every pattern is negated, the action includes every
+// other file.
+func splitNegations(a Action) (stringArr, stringArr) {
+	includes := stringArr{}
+	excludes := append(stringArr{}, a.ExcludePatterns...)
+	for _, pattern := range a.Patterns {
+		if strings.HasPrefix(pattern, "!") {
+			excludes = append(excludes, strings.TrimPrefix(pattern, "!"))
+			continue
+		}
+		includes = append(includes, pattern)
+	}
+	if len(includes) == 0 && len(excludes) > len(a.ExcludePatterns) {
+		includes = stringArr{"**/*"}
+		if a.PatternType == "regex" {
+			includes = stringArr{".*"}
+		}
+	}
+	return includes, excludes
 }
 
-func printInfo(msg string, args ...interface{}) {
-	fmt.Println(aurora.Sprintf(aurora.Yellow(msg), args...))
+// envList converts env variables to "key=value" form sorted by key.
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for key, value := range env {
+		list = append(list, key+"="+value)
+	}
+	sort.Strings(list)
+	return list
 }
 
-func printErr(err error, args ...interface{}) {
-	fmt.Println(aurora.Sprintf(aurora.Red(err), args...))
+// newAction creates an action from its config. The commands of the action are
+// executed with the additional env variables.
+func newAction(config Config, id string, a Action, env []string) (action, error) {
+	shell := a.Shell
+	if shell == "" {
+		shell = config.Shell
+	}
+
+	output, errOutput := &sink{}, &sink{}
+	buildCtx := &contextHolder{}
+	inputs := &inputHash{}
+	restarted := &funcHolder{}
+	workingDir := a.workingDir(config)
+	opts := commandOptions{Output: output, ErrOutput: errOutput, Context: buildCtx, Env: env, Dir: workingDir, Timeout: a.Timeout, Prefix: a.LogPrefix}
+	for _, route := range a.OutputRouter {
+		pattern, err := regexp.Compile(route.Pattern)
+		if err != nil {
+			return action{}, fmt.Errorf("[%s] Error parsing outputRouter: %w", id, err)
+		}
+		file := route.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(workingDir, file)
+		}
+		opts.Routes = append(opts.Routes, outputRoute{pattern: pattern, file: file})
+	}
+	opts.RouteTee = a.RouteMode != "exclusive"
+
+	var retryIf *regexp.Regexp
+	if a.RetryIf != "" {
+		var err error
+		retryIf, err = regexp.Compile(a.RetryIf)
+		if err != nil {
+			return action{}, fmt.Errorf("[%s] Error parsing retryIf: %w", id, err)
+		}
+	}
+
+	builds := []BuildFunc{}
+	for _, command := range a.BuildCommands {
+		cmd, args, err := shellCommand(shell, command)
+		if err != nil {
+			return action{}, fmt.Errorf("[%s] %w", id, err)
+		}
+		build := buildCommand(opts, cmd, args...)
+		if a.Retry > 1 {
+			build = retry(a.Retry, a.RetryDelay, build, retryIf, loggerFor(config.logger(), id), buildCtx)
+		}
+		builds = append(builds, build)
+	}
+	if len(a.ExpectedArtifacts) > 0 {
+		dir := workingDir
+		if dir == "" {
+			dir = config.Dir
+		}
+		builds = append(builds, expectArtifacts(dir, a.ExpectedArtifacts...))
+	}
+	if len(a.PreBuild) > 0 || len(a.PostBuild) > 0 {
+		pre := []BuildFunc{}
+		for _, command := range a.PreBuild {
+			cmd, args, err := shellCommand(shell, command)
+			if err != nil {
+				return action{}, fmt.Errorf("[%s] %w", id, err)
+			}
+			pre = append(pre, buildCommand(opts, cmd, args...))
+		}
+		post := []PostBuildFunc{}
+		for _, command := range a.PostBuild {
+			cmd, args, err := shellCommand(shell, command)
+			if err != nil {
+				return action{}, fmt.Errorf("[%s] %w", id, err)
+			}
+			post = append(post, postBuildCommand(opts, cmd, args...))
+		}
+		builds = []BuildFunc{BuildWithHooks(pre, builds, post)}
+	}
+
+	var run RunFunc
+	if a.RunCommand != "" {
+		logger := loggerFor(config.logger(), id)
+		stopSignal, _ := parseSignal(a.StopSignal)
+		if stopSignal == nil {
+			stopSignal = defaultStopSignal
+		}
+		stopTimeout := a.stopTimeout(config)
+		opts := commandOptions{
+			Env:           env,
+			Dir:           workingDir,
+			Prefix:        a.LogPrefix,
+			StopSignal:    stopSignal,
+			StopTimeout:   stopTimeout,
+			MinUptime:     a.MinUptime,
+			EarlyExit:     logger.Error,
+			Exited:        inputs.Reset,
+			Restarted:     restarted.call,
+			MaxRestarts:   a.MaxRestarts,
+			BackoffBase:   a.BackoffBase,
+			BackoffWindow: a.BackoffWindow,
+			RetryIf:       retryIf,
+			Logger:        logger,
+		}
+		cmd, args, err := shellCommand(shell, a.RunCommand)
+		if err != nil {
+			return action{}, fmt.Errorf("[%s] %w", id, err)
+		}
+		run = runCommand(opts, cmd, args...)
+		if a.ReadinessProbe != nil {
+			probe, err := a.ReadinessProbe.runProbe()
+			if err != nil {
+				return action{}, fmt.Errorf("[%s] %w", id, err)
+			}
+			run = RunWithProbe(run, probe, a.startTimeout())
+		}
+		if a.PreStop != "" || a.PostStop != "" {
+			preStop, err := parseHook(shell, a.PreStop)
+			if err != nil {
+				return action{}, fmt.Errorf("[%s] %w", id, err)
+			}
+			postStop, err := parseHook(shell, a.PostStop)
+			if err != nil {
+				return action{}, fmt.Errorf("[%s] %w", id, err)
+			}
+			hookOpts := commandOptions{Env: env, Dir: workingDir, Prefix: a.LogPrefix, Timeout: stopTimeout}
+			run = withStopHooks(run, hookOpts, preStop, postStop, logger)
+		}
+	}
+
+	match := globMatcher(a.Patterns, a.ExcludePatterns)
+	if a.PatternType == "regex" {
+		var err error
+		if match, err = regexMatcher(a.Patterns, a.ExcludePatterns); err != nil {
+			return action{}, fmt.Errorf("[%s] %w", id, err)
+		}
+	}
+
+	onSuccess, err := parseHook(shell, a.OnSuccess)
+	if err != nil {
+		return action{}, fmt.Errorf("[%s] %w", id, err)
+	}
+	onFailure, err := parseHook(shell, a.OnFailure)
+	if err != nil {
+		return action{}, fmt.Errorf("[%s] %w", id, err)
+	}
+
+	return action{
+		ID:         id,
+		Name:       a.Name,
+		Filter:     filterFunc(match),
+		BuildFuncs: builds,
+		RunFunc:    run,
+		Output:     output,
+		ErrOutput:  errOutput,
+		Context:    buildCtx,
+		Inputs:     inputs,
+		Restarted:  restarted,
+		Match: func(files []string) []string {
+			return selectFiles(match, files)
+		},
+		ChangedFileCount: a.ChangedFileCount,
+		OnSuccess:        onSuccess,
+		OnFailure:        onFailure,
+		Source: actionSource{
+			Action:      a,
+			Env:         env,
+			Shell:       shell,
+			StopTimeout: a.stopTimeout(config),
+		},
+	}, nil
+}
+
+// triggered reports whether the changed files should trigger the action.
+func (a action) triggered(changes []string) bool {
+	if !a.Filter(changes) {
+		return false
+	}
+	return a.ChangedFileCount.allows(len(a.Match(changes)))
+}
+
+// changesRoot returns the dir the changed files are relative to. The changes
+// of multiple dirs are prefixed with their root dir instead.
+func (config Config) changesRoot() string {
+	if len(config.Dirs) > 0 && len(config.Files) == 0 {
+		return ""
+	}
+	return config.Dir
+}
+
+// registry returns the FileRegistry tracking the files of the watched dirs.
+func (config Config) registry() FileRegistry {
+	return FileRegistry{
+		ExcludeDirs:        config.ExcludeDirs,
+		IncludeDirs:        config.IncludeDirs,
+		AllowList:          config.AllowList,
+		UseHash:            config.UseHash,
+		CaseInsensitive:    config.CaseInsensitive,
+		SkipHidden:         config.SkipHidden,
+		OwnedByCurrentUser: config.OwnedByCurrentUser,
+		FollowSymlinks:     config.FollowSymlinks,
+		MaxDepth:           config.MaxDepth,
+		MaxFileSize:        config.MaxFileSize,
+		OnSkip:             logOnce(config.logger()),
+	}
+}
+
+// logOnce returns a function logging every distinct error once, so the errors
+// repeated by every poll are only logged the first time.
+func logOnce(logger Logger) func(err error) {
+	var mu sync.Mutex
+	logged := make(map[string]bool)
+	return func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !logged[err.Error()] {
+			logged[err.Error()] = true
+			logger.Error(err)
+		}
+	}
+}
+
+// newDetect returns the DetectFunc for the config. The changes matching the
+// ExcludePatterns of the config are dropped before any action sees them.
+func newDetect(config Config) DetectFunc {
+	return changedPaths(newDetectEvents(config))
+}
+
+// newDetectEvents returns the DetectEventsFunc for the config, detecting the
+// same changes as newDetect. The changes detected by Config.Detect are
+// reported as modified, since their kind is unknown.
+func newDetectEvents(config Config) DetectEventsFunc {
+	var detect DetectEventsFunc
+	if config.Detect != nil {
+		detect = modifiedEvents(config.Detect)
+	} else {
+		detect = detectSources(config)
+	}
+	if len(config.ExcludePatterns) > 0 {
+		detect = excludeChanges(detect, config.ExcludePatterns)
+	}
+	return detect
+}
+
+// modifiedEvents returns a DetectEventsFunc reporting the changes detected by
+// detect as modified.
+func modifiedEvents(detect DetectFunc) DetectEventsFunc {
+	return func() []ChangeEvent {
+		changed := []ChangeEvent{}
+		for _, path := range detect() {
+			changed = append(changed, ChangeEvent{Path: path, Kind: Modified})
+		}
+		return changed
+	}
+}
+
+// excludeChanges returns a DetectEventsFunc returning the changes detected by
+// detect that don't match the exclude patterns.
+func excludeChanges(detect DetectEventsFunc, excludePatterns []string) DetectEventsFunc {
+	return func() []ChangeEvent {
+		events := detect()
+		return keepEvents(events, matchFiles([]string{"**/*"}, excludePatterns, eventPaths(events)))
+	}
+}
+
+// detectSources returns the DetectEventsFunc of the dirs, the files or the
+// archive of the config.
+func detectSources(config Config) DetectEventsFunc {
+	if config.Archive {
+		return DetectArchiveEvents(config.ArchivePath)
+	}
+	if len(config.Files) > 0 {
+		return detectFileListEvents(config.Dir, config.Files, config.registry())
+	}
+	registry := config.registry()
+	detect := func(dir string) DetectEventsFunc {
+		detect := detectEvents(dir, registry)
+		if config.AutoContentFilter {
+			detect = filterContentEvents(dir, registry, detect)
+		}
+		return detect
+	}
+	if len(config.Dirs) == 0 {
+		return detect(config.Dir)
+	}
+	return joinDirs(config.Dirs, detect)
+}
+
+// filterContentEvents returns a DetectEventsFunc returning the change events
+// detected by detect that are significant according to the
+// ExtensionContentFilters.
+func filterContentEvents(dir string, registry FileRegistry, detect DetectEventsFunc) DetectEventsFunc {
+	var events []ChangeEvent
+	significant := detectWithContentFilters(dir, registry, func() []string {
+		events = detect()
+		return eventPaths(events)
+	}, ExtensionContentFilters)
+
+	return func() []ChangeEvent {
+		return keepEvents(events, significant())
+	}
+}
+
+// Watch runs commands based on file changes. In print only mode it prints the
+// changed files to stdout instead. It blocks until an error happens or
+// revolver receives SIGINT or SIGTERM, which stop the running commands.
+// SIGHUP resets the circuit breakers instead.
+func Watch(config Config) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	return WatchSignals(config, sigs)
+}
+
+// WatchUntilError watches like Watch does until a build fails, and returns
+// the error of the first failed build, like onError "stop" does, regardless
+// of RunOnce. It overrides the OnError of the config. It returns nil if
+// revolver receives SIGINT or SIGTERM first.
+func WatchUntilError(config Config) error {
+	config.OnError = "stop"
+	return Watch(config)
+}
+
+// WatchSignals watches like Watch does until an error happens or a signal is
+// received from sigs. On a signal the running commands are stopped before it
+// returns, except for SIGHUP, which resets the circuit breakers.
+func WatchSignals(config Config, sigs <-chan os.Signal) error {
+	watcher, events, cancel, err := watchEvents(config)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case sig := <-sigs:
+			if sig == syscall.SIGHUP {
+				config.logger().Info("Received %v, resetting the circuit breakers...", sig)
+				watcher.ResetBreakers()
+				continue
+			}
+			config.logger().Info("Received %v, stopping...", sig)
+			cancel()
+			sigs = nil
+		case event := <-events:
+			if event.Kind == WatchStopped {
+				return event.Err
+			}
+		}
+	}
+}
+
+// triggered returns the actions triggered by the changes.
+func triggered(actions []action, changes []string) []action {
+	result := []action{}
+	for _, action := range actions {
+		if action.triggered(changes) {
+			result = append(result, action)
+		}
+	}
+	return result
+}
+
+// CycleResult is the result of a watch cycle.
+type CycleResult struct {
+	// Cycle is the number of the cycle.
+	Cycle int
+	// Changes are the changed files that triggered the cycle. It is empty
+	// for the cycle run on start.
+	Changes []string
+	// Actions are the IDs of the actions run in the cycle.
+	Actions []string
+	// Errors are the errors of the failed actions by their ID.
+	Errors map[string]error
+}
+
+// Success reports whether every action of the cycle succeeded.
+func (result CycleResult) Success() bool {
+	return len(result.Errors) == 0
+}
+
+// err returns the error of the failed action of the cycle with the first ID
+// in sorted order, or nil if every action succeeded.
+func (result CycleResult) err() error {
+	if result.Success() {
+		return nil
+	}
+	ids := make([]string, 0, len(result.Errors))
+	for id := range result.Errors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return fmt.Errorf("Error building action %s: %w", ids[0], result.Errors[ids[0]])
+}
+
+// runner executes the actions and keeps track of their stop functions.
+type runner struct {
+	config Config
+	logger Logger
+
+	breaker *breaker
+	// actionBreakers are the circuit breakers of the actions with a
+	// BreakAfter by their ID.
+	actionBreakers map[string]*breaker
+	// onCycle receives the result of every cycle if it is not nil.
+	onCycle func(CycleResult)
+	// onBuild receives the record of every build if it is not nil.
+	onBuild func(BuildRecord)
+	// onEvent receives the events of the actions if it is not nil.
+	onEvent func(Event)
+	// errorPattern matches the errors in the build output if errors are
+	// correlated with the changed files.
+	errorPattern *regexp.Regexp
+	// audit records the decisions of the runner if it is not nil.
+	audit *auditLog
+
+	// done is set when watching should stop: after a failed build if
+	// config.OnError stops watching, or after the first cycle running any
+	// actions if config.RunOnce is set.
+	done bool
+	// failed is the error watching stops with when done is set.
+	failed error
+	// debounced are the triggered builds of the actions with a Debounce by
+	// their ID, waiting for the changes to settle.
+	debounced map[string]*debouncedBuild
+
+	mu        sync.Mutex
+	stopFuncs map[string]func()
+	// slots limit the concurrent runs of the actions by their ID.
+	slots map[string]chan struct{}
+}
+
+func newRunner(config Config) *runner {
+	logger := config.logger()
+	var errorPattern *regexp.Regexp
+	if config.ErrorCorrelation {
+		errorPattern, _ = config.errorPattern()
+	}
+	return &runner{
+		errorPattern:   errorPattern,
+		config:         config,
+		logger:         logger,
+		breaker:        newBreaker(config.CircuitBreaker, logger),
+		stopFuncs:      make(map[string]func()),
+		slots:          make(map[string]chan struct{}),
+		debounced:      make(map[string]*debouncedBuild),
+		actionBreakers: make(map[string]*breaker),
+	}
+}
+
+// acquire takes a concurrency slot of the action, waiting for a free one
+// unless the action drops the runs over its limit. It returns the function
+// releasing the slot, or false if the run should be dropped.
+func (r *runner) acquire(a action) (func(), bool) {
+	r.mu.Lock()
+	slots, ok := r.slots[a.ID]
+	if !ok {
+		limit := a.Source.Action.MaxConcurrent
+		if limit <= 0 {
+			limit = 1
+		}
+		slots = make(chan struct{}, limit)
+		r.slots[a.ID] = slots
+	}
+	r.mu.Unlock()
+
+	release := func() { <-slots }
+	if policy := a.Source.Action.queuePolicy(); policy == "drop" || policy == "skip" {
+		select {
+		case slots <- struct{}{}:
+			return release, true
+		default:
+			return nil, false
+		}
+	}
+	slots <- struct{}{}
+	return release, true
+}
+
+// runCycle executes the actions. The actions are executed one after the
+// other, all at once if config.Parallel is set, or stage by stage if
+// config.StageOrder is set. If the cycle takes longer than
+// config.CycleBudget, the running builds are canceled and the remaining
+// actions are skipped.
+func (r *runner) runCycle(actions []action, cycle int) CycleResult {
+	result := CycleResult{
+		Cycle:   cycle,
+		Actions: []string{},
+		Errors:  make(map[string]error),
+	}
+
+	ctx := context.Background()
+	if r.config.CycleBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.config.CycleBudget)
+		defer cancel()
+	}
+	for _, action := range actions {
+		if action.Context != nil {
+			action.Context.Set(ctx)
+		}
+	}
+
+	start := time.Now()
+	if len(actions) > 0 {
+		r.runCycleHook(r.config.BeforeCycle, nil)
+	}
+
+	if unlock, ok := r.lockMutexFile(actions, &result); ok {
+		switch {
+		case len(r.config.StageOrder) > 0:
+			r.runStages(ctx, actions, cycle, &result)
+		case r.config.Parallel:
+			r.runParallel(actions, cycle, &result)
+		default:
+			r.runSequential(ctx, actions, cycle, &result)
+		}
+		unlock()
+	}
+
+	if len(actions) > 0 {
+		r.runCycleHook(r.config.AfterCycle, []string{
+			fmt.Sprintf("REVOLVER_CYCLE_SUCCESS=%t", result.Success()),
+			fmt.Sprintf("REVOLVER_CYCLE_DURATION_MS=%d", time.Since(start).Milliseconds()),
+		})
+	}
+	return result
+}
+
+// lockMutexFile locks config.MutexFile before the builds of a cycle, so the
+// builds of multiple revolver instances don't run at the same time. It
+// returns the function unlocking it, or false if it can't be locked, in which
+// case every action of the cycle fails.
+func (r *runner) lockMutexFile(actions []action, result *CycleResult) (func(), bool) {
+	if r.config.MutexFile == "" || len(actions) == 0 {
+		return func() {}, true
+	}
+	unlock, err := lockMutexFile(r.config.MutexFile, r.config.LockTimeout)
+	if err != nil {
+		r.logger.Error(err)
+		for _, a := range actions {
+			result.Errors[a.ID] = err
+		}
+		return nil, false
+	}
+	return unlock, true
+}
+
+// runCycleHook executes the BeforeCycle or AfterCycle command with the
+// additional env variables and waits for it. Its errors are only logged.
+func (r *runner) runCycleHook(command string, env []string) {
+	if command == "" {
+		return
+	}
+	cmd, args, err := shellCommand(r.config.Shell, command)
+	if err != nil {
+		r.logger.Error(err)
+		return
+	}
+	opts := commandOptions{Env: env, Dir: r.config.Dir}
+	if err := buildCommand(opts, cmd, args...)(); err != nil {
+		r.logger.Error(err)
+	}
+}
+
+// skipBudget reports whether the cycle budget is exceeded and the action
+// should be skipped.
+func (r *runner) skipBudget(ctx context.Context, a action, cycle int) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	loggerFor(r.logger, a.ID).Info("Skipped, the cycle budget of %v is exceeded.", r.config.CycleBudget)
+	r.recordAudit(AuditRecord{Type: AuditBuildSkip, Action: a.ID, Cycle: cycle, Outcome: "cycle budget exceeded"})
+	return true
+}
+
+// skipBreaker reports whether the circuit breaker or the breaker of the action
+// is open and the action should be skipped.
+func (r *runner) skipBreaker(a action, cycle int) bool {
+	if !r.breaker.allow() {
+		loggerFor(r.logger, a.ID).Info("Skipped, the circuit breaker is open.")
+		r.recordAudit(AuditRecord{Type: AuditBuildSkip, Action: a.ID, Cycle: cycle, Outcome: "circuit breaker open"})
+		return true
+	}
+	if !r.actionBreaker(a).allow() {
+		// The build doesn't run, so a trial of the global breaker is left
+		// for the next build.
+		r.breaker.release()
+		loggerFor(r.logger, a.ID).Info("Skipped, the action is broken after %d consecutive failures.", a.Source.Action.BreakAfter)
+		r.recordAudit(AuditRecord{Type: AuditBuildSkip, Action: a.ID, Cycle: cycle, Outcome: "action broken"})
+		return true
+	}
+	return false
+}
+
+// actionBreaker returns the circuit breaker of the action, which opens after
+// BreakAfter consecutive failures of the action. It is created again if the
+// config of the action changed on reload.
+func (r *runner) actionBreaker(a action) *breaker {
+	config := CircuitBreaker{Threshold: a.Source.Action.BreakAfter, ResetAfter: a.Source.Action.ResetAfter}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.actionBreakers[a.ID]
+	if !ok || b.config != config {
+		b = newBreaker(config, loggerFor(r.logger, a.ID))
+		b.manual = config.ResetAfter == 0
+		r.actionBreakers[a.ID] = b
+	}
+	return b
+}
+
+// errBuildSkipped is returned by runAction if the build of the action is
+// skipped, so it is neither a success nor a failure.
+var errBuildSkipped = errors.New("build skipped")
+
+// recordBreakers records the result of the build of the action in the
+// circuit breaker and the breaker of the action. A skipped build gives back
+// the trials of the breakers instead.
+func (r *runner) recordBreakers(a action, err error) {
+	if errors.Is(err, errBuildSkipped) {
+		r.breaker.release()
+		r.actionBreaker(a).release()
+		return
+	}
+	r.breaker.record(err)
+	r.actionBreaker(a).record(err)
+}
+
+// resetBreakers closes the circuit breaker and the breakers of the actions.
+func (r *runner) resetBreakers() {
+	r.breaker.reset()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.actionBreakers {
+		b.reset()
+	}
+}
+
+// runSequential executes the actions one after the other.
+func (r *runner) runSequential(ctx context.Context, actions []action, cycle int, result *CycleResult) {
+	for _, action := range actions {
+		if r.skipBudget(ctx, action, cycle) || r.skipBreaker(action, cycle) {
+			continue
+		}
+		err := r.runAction(action, cycle)
+		r.recordBreakers(action, err)
+		if errors.Is(err, errBuildSkipped) {
+			continue
+		}
+		result.Actions = append(result.Actions, action.ID)
+		if err != nil {
+			result.Errors[action.ID] = err
+			loggerFor(r.logger, action.ID).Error(err)
+		}
+	}
+}
+
+// runParallel executes the actions all at once.
+func (r *runner) runParallel(actions []action, cycle int, result *CycleResult) {
+	started := []action{}
+	for _, a := range actions {
+		if !r.skipBreaker(a, cycle) {
+			started = append(started, a)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(started))
+	for i, a := range started {
+		wg.Add(1)
+		go func(i int, a action) {
+			defer wg.Done()
+			errs[i] = r.runAction(a, cycle)
+			r.recordBreakers(a, errs[i])
+		}(i, a)
+	}
+	wg.Wait()
+
+	for i, a := range started {
+		if errors.Is(errs[i], errBuildSkipped) {
+			continue
+		}
+		result.Actions = append(result.Actions, a.ID)
+		if errs[i] != nil {
+			result.Errors[a.ID] = errs[i]
+			loggerFor(r.logger, a.ID).Error(errs[i])
+		}
+	}
+}
+
+// runStages executes the actions stage by stage in config.StageOrder. The
+// actions of a stage are executed all at once. An action is skipped if its
+// stage condition isn't met by the result of the previous stages.
+func (r *runner) runStages(ctx context.Context, actions []action, cycle int, result *CycleResult) {
+	failed := false
+	for _, stage := range r.config.StageOrder {
+		stageActions := []action{}
+		for _, a := range actions {
+			if a.Source.Action.Stage != stage || r.skipBudget(ctx, a, cycle) {
+				continue
+			}
+			if condition := a.Source.Action.StageCondition; !stageConditionMet(condition, failed) {
+				loggerFor(r.logger, a.ID).Info("Skipped, the stage condition %q is not met.", condition)
+				r.recordAudit(AuditRecord{Type: AuditBuildSkip, Action: a.ID, Cycle: cycle, Outcome: "stage condition not met"})
+				continue
+			}
+			stageActions = append(stageActions, a)
+		}
+
+		errs := len(result.Errors)
+		r.runParallel(stageActions, cycle, result)
+		if len(result.Errors) > errs {
+			failed = true
+		}
+	}
+}
+
+// The stage conditions of actions.
+const (
+	// StageSuccess runs the action if every previous stage succeeded.
+	StageSuccess = "success"
+	// StageFailure runs the action if a previous stage failed.
+	StageFailure = "failure"
+	// StageAlways runs the action regardless of the previous stages.
+	StageAlways = "always"
+)
+
+// stageConditionMet reports whether the stage condition is met if the
+// previous stages failed or not. The default condition is StageSuccess.
+func stageConditionMet(condition string, failed bool) bool {
+	switch condition {
+	case StageAlways:
+		return true
+	case StageFailure:
+		return failed
+	}
+	return !failed
+}
+
+// runChanges runs the actions triggered by the changes. If more changes are
+// detected right after the run, e.g. files changed during the build, the
+// triggered actions are run again immediately, at most MaxImmediateRetriggers
+// times. It returns the number of the last cycle.
+func (r *runner) runChanges(detect DetectFunc, actions []action, changes []string, cycle int) int {
+	cycle++
+	r.report(r.runCycle(r.triggered(actions, changes, cycle), cycle), changes)
+
+	for i := 0; i < r.config.MaxImmediateRetriggers && !r.done; i++ {
+		changes = detect()
+		if len(changes) == 0 {
+			break
+		}
+		cycle++
+		r.report(r.runCycle(r.triggered(actions, changes, cycle), cycle), changes)
+	}
+	return cycle
+}
+
+// triggered returns the actions triggered by the changes like triggered does
+// and records the changes and the decisions in the audit log. The actions
+// with a Debounce are not returned but delayed until runDebounced.
+func (r *runner) triggered(actions []action, changes []string, cycle int) []action {
+	r.recordAudit(AuditRecord{Type: AuditChanges, Cycle: cycle, Files: changes})
+
+	result := []action{}
+	for _, a := range actions {
+		outcome := "skipped"
+		matched := a.Match(changes)
+		if a.triggered(changes) {
+			if a.Source.Action.Debounce > 0 {
+				outcome = "debounced"
+				r.debounce(a, matched)
+			} else {
+				outcome = "triggered"
+				a.TriggeredBy = matched
+				result = append(result, a)
+			}
+		}
+		r.recordAudit(AuditRecord{Type: AuditFilter, Action: a.ID, Cycle: cycle, Files: matched, Outcome: outcome})
+	}
+	return result
+}
+
+// debouncedBuild is a build delayed until the changes triggering it settle.
+type debouncedBuild struct {
+	// deadline is when the build runs unless more changes trigger it.
+	deadline time.Time
+	// files are the changed files that triggered the build.
+	files []string
+}
+
+// debounce delays the build of the action triggered by the files until no
+// more changes trigger it for its Debounce duration.
+func (r *runner) debounce(a action, files []string) {
+	build, ok := r.debounced[a.ID]
+	if !ok {
+		build = &debouncedBuild{}
+		r.debounced[a.ID] = build
+	}
+	build.deadline = time.Now().Add(a.Source.Action.Debounce)
+	for _, file := range files {
+		if !stringArr(build.files).has(file) {
+			build.files = append(build.files, file)
+		}
+	}
+}
+
+// runDebounced runs the debounced builds whose deadline passed in a new cycle
+// and returns the number of the last cycle.
+func (r *runner) runDebounced(actions []action, cycle int) int {
+	now := time.Now()
+	due := []action{}
+	changes := stringArr{}
+	for _, a := range actions {
+		build, ok := r.debounced[a.ID]
+		if !ok || now.Before(build.deadline) {
+			continue
+		}
+		a.TriggeredBy = build.files
+		due = append(due, a)
+		for _, file := range build.files {
+			if !changes.has(file) {
+				changes = append(changes, file)
+			}
+		}
+	}
+	// The builds of the actions removed by a config reload are dropped.
+	for id, build := range r.debounced {
+		if !now.Before(build.deadline) {
+			delete(r.debounced, id)
+		}
+	}
+	if len(due) == 0 {
+		return cycle
+	}
+
+	cycle++
+	r.report(r.runCycle(due, cycle), changes)
+	return cycle
+}
+
+// recordAudit appends the record to the audit log. Errors are logged, they
+// don't stop the runner.
+func (r *runner) recordAudit(record AuditRecord) {
+	if err := r.audit.record(record); err != nil {
+		r.logger.Error(fmt.Errorf("Error writing audit log: %w", err))
+	}
+}
+
+// emit passes the event to onEvent.
+func (r *runner) emit(event Event) {
+	if r.onEvent != nil {
+		r.onEvent(event)
+	}
+}
+
+// report passes the result of a cycle triggered by the changes to onCycle.
+func (r *runner) report(result CycleResult, changes []string) {
+	result.Changes = changes
+	if !r.done && r.config.OnError == "stop" && len(result.Errors) > 0 {
+		r.done, r.failed = true, result.err()
+	}
+	if !r.done && r.config.RunOnce && (len(result.Actions) > 0 || len(result.Errors) > 0) {
+		r.done, r.failed = true, result.err()
+	}
+	if r.onCycle != nil {
+		r.onCycle(result)
+	}
+}
+
+// stopAction stops the running command of the action with the given id.
+func (r *runner) stopAction(id string) {
+	r.mu.Lock()
+	stop, ok := r.stopFuncs[id]
+	delete(r.stopFuncs, id)
+	r.mu.Unlock()
+	if ok && stop != nil {
+		stop()
+		loggerFor(r.logger, id).Info("Stopping...")
+		r.recordAudit(AuditRecord{Type: AuditRunStop, Action: id})
+		r.emit(Event{ActionID: id, Kind: RunStopped})
+	}
+}
+
+// stopAll stops the running commands of every action.
+func (r *runner) stopAll() {
+	r.mu.Lock()
+	ids := make([]string, 0, len(r.stopFuncs))
+	for id := range r.stopFuncs {
+		ids = append(ids, id)
+	}
+	r.mu.Unlock()
+
+	for _, id := range ids {
+		r.stopAction(id)
+	}
+}
+
+// runAction stops the previous run of the action and executes it again.
+func (r *runner) runAction(action action, cycle int) error {
+	logger := loggerFor(r.logger, action.ID)
+	release, ok := r.acquire(action)
+	if !ok {
+		logger.Info("action %s at concurrency limit, dropping", action.ID)
+		r.recordAudit(AuditRecord{Type: AuditBuildSkip, Action: action.ID, Cycle: cycle, Outcome: "concurrency limit"})
+		return errBuildSkipped
+	}
+	defer release()
+
+	var inputs [sha256.Size]byte
+	hashed := r.config.InputHash && len(action.TriggeredBy) > 0
+	if hashed {
+		inputs = hashInputs(r.config.changesRoot(), action.TriggeredBy)
+		if action.Inputs.matches(inputs) {
+			logger.Info("Skipped (inputs unchanged since last successful build).")
+			r.recordAudit(AuditRecord{Type: AuditBuildSkip, Action: action.ID, Cycle: cycle, Outcome: "inputs unchanged"})
+			return errBuildSkipped
+		}
+	}
+	r.stopAction(action.ID)
+
+	var stream io.WriteCloser
+	if r.config.StreamOutputURL != "" {
+		stream = streamOutput(r.config.StreamOutputURL, action.ID, cycle)
+	}
+	var output bytes.Buffer
+	capture := (r.errorPattern != nil || r.config.CaptureOutput) && action.Output != nil
+	// Both standard output and error go to the stream and the captured
+	// output, the sink serializing their writes.
+	combined := &sink{}
+	switch {
+	case stream != nil && capture:
+		combined.Set(io.MultiWriter(stream, &output))
+	case stream != nil:
+		combined.Set(stream)
+	case capture:
+		combined.Set(&output)
+	}
+	stdout, stderr := newTailBuffer(historyOutputSize), newTailBuffer(historyOutputSize)
+	if action.Output != nil && action.ErrOutput != nil {
+		action.Output.Set(io.MultiWriter(stdout, combined))
+		action.ErrOutput.Set(io.MultiWriter(stderr, combined))
+	}
+
+	if len(action.TriggeredBy) > 0 {
+		logger.Info("Building, triggered by: %s", strings.Join(action.TriggeredBy, ", "))
+	}
+	r.recordAudit(AuditRecord{Type: AuditBuildStart, Action: action.ID, Cycle: cycle, Files: action.TriggeredBy})
+	r.emit(Event{ActionID: action.ID, Kind: BuildStarted})
+	start := time.Now()
+	if action.Restarted != nil {
+		action.Restarted.Set(func() {
+			r.emit(Event{ActionID: action.ID, Kind: RunRestarted})
+		})
+	}
+	stop, err := Run(action.BuildFuncs, action.RunFunc)
+	duration := time.Since(start)
+	if r.onBuild != nil {
+		r.onBuild(BuildRecord{
+			Action:      action.ID,
+			Cycle:       cycle,
+			Timestamp:   start,
+			Duration:    duration,
+			TriggeredBy: action.TriggeredBy,
+			Stdout:      stdout.Bytes(),
+			Stderr:      stderr.Bytes(),
+			Err:         err,
+		})
+	}
+
+	if action.Output != nil && action.ErrOutput != nil {
+		action.Output.Set(nil)
+		action.ErrOutput.Set(nil)
+	}
+	if stream != nil {
+		if err := stream.Close(); err != nil {
+			logger.Error(err)
+		}
+	}
+	var captured []byte
+	if r.config.CaptureOutput && capture {
+		captured = output.Bytes()
+	}
+	if err != nil && r.errorPattern != nil {
+		if callouts := correlateErrors(r.errorPattern, output.Bytes(), action.TriggeredBy); len(callouts) > 0 {
+			err = fmt.Errorf("%s\n%w", strings.Join(callouts, "\n"), err)
+		}
+	}
+	r.notify(action, err)
+	if hashed && err == nil {
+		action.Inputs.Set(inputs)
+	} else {
+		action.Inputs.Reset()
+	}
+	if err != nil {
+		r.recordAudit(AuditRecord{Type: AuditBuildResult, Action: action.ID, Cycle: cycle, Outcome: err.Error()})
+		r.emit(Event{ActionID: action.ID, Kind: BuildFailed, Err: err, Duration: duration, Output: captured})
+		return err
+	}
+	r.recordAudit(AuditRecord{Type: AuditBuildResult, Action: action.ID, Cycle: cycle, Outcome: "success"})
+	r.emit(Event{ActionID: action.ID, Kind: BuildSucceeded, Duration: duration, Output: captured})
+
+	r.mu.Lock()
+	r.stopFuncs[action.ID] = stop
+	r.mu.Unlock()
+	if stop != nil {
+		r.recordAudit(AuditRecord{Type: AuditRunStart, Action: action.ID, Cycle: cycle})
+		r.emit(Event{ActionID: action.ID, Kind: RunStarted})
+	}
+
+	logger.Success("Built in %.2fs.", duration.Seconds())
+	return nil
+}
+
+// notify runs the OnSuccess or OnFailure hook of the action depending on the
+// result of its build, and the NotifyCommand of the config on failure if
+// config.OnError is "notify". The hooks run in the background and their errors
+// are only logged. The hooks receive the ID of the action in REVOLVER_ACTION
+// and the error of the build in REVOLVER_ERROR.
+func (r *runner) notify(a action, buildErr error) {
+	hook, msg := a.OnSuccess, ""
+	if buildErr != nil {
+		hook, msg = a.OnFailure, buildErr.Error()
+	}
+
+	env := append([]string{}, a.Source.Env...)
+	env = append(env, "REVOLVER_ACTION="+a.ID, "REVOLVER_ERROR="+msg)
+	opts := commandOptions{Env: env, Dir: a.Source.Action.workingDir(r.config)}
+	logger := loggerFor(r.logger, a.ID)
+	if hook != nil {
+		runHook(opts, hook, logger)
+	}
+	if buildErr != nil && r.config.OnError == "notify" {
+		hook, err := parseHook(r.config.Shell, r.config.NotifyCommand)
+		if err != nil {
+			logger.Error(err)
+		} else if hook != nil {
+			runHook(opts, hook, logger)
+		}
+	}
+}
+
+// runHook runs the hook in the background and logs its errors.
+func runHook(opts commandOptions, hook *hookCommand, logger Logger) {
+	go func() {
+		cmd := opts.command(context.Background(), hook.Command, hook.Args...)
+		if err := cmd.Run(); err != nil {
+			logger.Error(fmt.Errorf("Error executing hook: \"%s\": %w", hook.Command, err))
+		}
+	}()
+}
+
+// debounce keeps detecting changes every interval until the debounce duration
+// elapses and returns all the detected changes merged with the given changes.
+func debounce(detect DetectFunc, changes []string, duration, interval time.Duration) []string {
+	seen := make(map[string]struct{})
+	merge := func(files []string) {
+		for _, file := range files {
+			if _, ok := seen[file]; ok {
+				continue
+			}
+			seen[file] = struct{}{}
+			changes = append(changes, file)
+		}
+	}
+	for _, file := range changes {
+		seen[file] = struct{}{}
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			merge(detect())
+			return changes
+		case <-ticker.C:
+			merge(detect())
+		}
+	}
 }
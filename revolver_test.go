@@ -1,9 +1,16 @@
 package revolver
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -216,6 +223,166 @@ func TestDetect(t *testing.T) {
 	}
 }
 
+func TestDetectDirs(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	api := filepath.Join(dir, "services", "api")
+	frontend := filepath.Join(dir, "frontend")
+	for _, d := range []string{api, frontend, filepath.Join(frontend, "node_modules")} {
+		if err := os.MkdirAll(d, 0700); err != nil {
+			t.Fatalf("Cannot create dir: %v", err)
+		}
+	}
+
+	detect := DetectDirs([]string{api, frontend}, []string{"node_modules"})
+	detect()
+
+	apiFile := createTempFile(t, api, "")
+	frontendFile := createTempFile(t, frontend, "")
+	createTempFile(t, filepath.Join(frontend, "node_modules"), "")
+
+	expected := []string{filepath.Join(api, apiFile), filepath.Join(frontend, frontendFile)}
+	if changed := detect(); !equals(expected, changed) {
+		t.Errorf("Changed files should be: %v; got: %v", expected, changed)
+	}
+}
+
+func TestDetectFileList(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	if err := os.MkdirAll(filepath.Join(dir, "config"), 0700); err != nil {
+		t.Fatalf("Cannot create dir: %v", err)
+	}
+	write := func(name string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(name), 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+	write("go.mod")
+	write("main.go")
+
+	detect := DetectFileList(dir, []string{"go.mod", "go.sum", "config/app.yaml"})
+	if expected := []string{"go.mod"}; !equals(expected, detect()) {
+		t.Errorf("Initial files should be: %v", expected)
+	}
+
+	write("main.go")
+	write("go.sum")
+	write("config/app.yaml")
+	expected := []string{"go.sum", filepath.Join("config", "app.yaml")}
+	if changed := detect(); !equals(expected, changed) {
+		t.Errorf("Changed files should be: %v; got: %v", expected, changed)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "go.mod")); err != nil {
+		t.Fatalf("Cannot remove file: %v", err)
+	}
+	if changed := detect(); !equals([]string{"go.mod"}, changed) {
+		t.Errorf("Removed files should be: [go.mod]; got: %v", changed)
+	}
+}
+
+func TestNewDetectExcludePatterns(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	if err := os.MkdirAll(filepath.Join(dir, "gen"), 0700); err != nil {
+		t.Fatalf("Cannot create dir: %v", err)
+	}
+	detect := newDetect(Config{Dir: dir, ExcludePatterns: []string{"**/*.pb.go", "gen/**"}})
+	detect()
+
+	for _, name := range []string{"main.go", "api.pb.go", "gen/types.go"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), nil, 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+	if changed := detect(); !equals([]string{"main.go"}, changed) {
+		t.Errorf("Changed files should be: %v; got: %v", []string{"main.go"}, changed)
+	}
+}
+
+func TestParseConfigExcludePatterns(t *testing.T) {
+	config, err := parseConfig([]byte(`excludePattern: ["**/*.pb.go"]
+exclude: ["**/*_test.go"]
+build: go build`))
+	if err != nil {
+		t.Fatalf("parseConfig() err should be nil; got: %v", err)
+	}
+	if !equals([]string{"**/*.pb.go"}, config.ExcludePatterns) {
+		t.Errorf("ExcludePatterns should be: [**/*.pb.go]; got: %v", config.ExcludePatterns)
+	}
+	if len(config.Actions) != 1 || !equals([]string{"**/*_test.go"}, config.Actions[0].ExcludePatterns) {
+		t.Errorf("Action ExcludePatterns should be: [**/*_test.go]; got: %+v", config.Actions)
+	}
+}
+
+func TestDetectEvents(t *testing.T) {
+
+	type testCase func(t *testing.T, dir string) (expected []ChangeEvent, detect DetectEventsFunc)
+
+	for name, tc := range map[string]testCase{
+		"no change": func(t *testing.T, dir string) ([]ChangeEvent, DetectEventsFunc) {
+			createTempFile(t, dir, "")
+
+			detect := DetectEvents(dir, nil)
+			detect()
+
+			return []ChangeEvent{}, detect
+		},
+		"add file": func(t *testing.T, dir string) ([]ChangeEvent, DetectEventsFunc) {
+			detect := DetectEvents(dir, nil)
+			detect()
+
+			file := createTempFile(t, dir, "")
+
+			return []ChangeEvent{{Path: file, Kind: Added}}, detect
+		},
+		"change file": func(t *testing.T, dir string) ([]ChangeEvent, DetectEventsFunc) {
+			file := createTempFile(t, dir, "")
+
+			detect := DetectEvents(dir, nil)
+			detect()
+
+			writeFile(t, filepath.Join(dir, file))
+
+			return []ChangeEvent{{Path: file, Kind: Modified}}, detect
+		},
+		"delete file": func(t *testing.T, dir string) ([]ChangeEvent, DetectEventsFunc) {
+			file := createTempFile(t, dir, "")
+
+			detect := DetectEvents(dir, nil)
+			detect()
+
+			os.Remove(filepath.Join(dir, file))
+
+			return []ChangeEvent{{Path: file, Kind: Deleted}}, detect
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+
+			expected, detect := tc(t, dir)
+
+			time.Sleep(5 * time.Millisecond)
+
+			events := detect()
+
+			if len(events) != len(expected) {
+				t.Fatalf("Events should be: %v; got: %v", expected, events)
+			}
+			for i := range events {
+				if events[i] != expected[i] {
+					t.Errorf("Events should be: %v; got: %v", expected, events)
+				}
+			}
+		})
+	}
+}
+
 func TestRun(t *testing.T) {
 	buildCmd := func(command string, args ...string) func(t *testing.T) []BuildFunc {
 		return func(t *testing.T) []BuildFunc {
@@ -304,6 +471,368 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestBuildCommandTimeout(t *testing.T) {
+	type testCase struct {
+		timeout time.Duration
+		args    []string
+		err     bool
+	}
+	for name, tc := range map[string]testCase{
+		"no timeout": {
+			args: []string{"0.01"},
+			err:  false,
+		},
+		"within timeout": {
+			timeout: time.Second,
+			args:    []string{"0.01"},
+			err:     false,
+		},
+		"timed out": {
+			timeout: 50 * time.Millisecond,
+			args:    []string{"5"},
+			err:     true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			build := buildCommand(commandOptions{Timeout: tc.timeout}, "sleep", tc.args...)
+
+			start := time.Now()
+			err := build()
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Build should be killed after the timeout; took: %v", elapsed)
+			}
+			if (err != nil) != tc.err {
+				t.Errorf("Build err should be %v; got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestBuildCommandTimeoutKillsChildren(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("child processes are not killed on Windows")
+	}
+	var output bytes.Buffer
+	build := buildCommand(commandOptions{Timeout: 50 * time.Millisecond, Output: &output}, "sh", "-c", "sleep 5; true")
+
+	start := time.Now()
+	err := build()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Build should be killed with its children after the timeout; took: %v", elapsed)
+	}
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Build should time out; got: %v", err)
+	}
+}
+
+func TestBuildCommandCapture(t *testing.T) {
+	type testCase struct {
+		script   string
+		expected string
+		err      bool
+	}
+	for name, tc := range map[string]testCase{
+		"stdout": {
+			script:   "echo out",
+			expected: "out\n",
+		},
+		"stdout and stderr": {
+			script:   "echo out; echo err >&2",
+			expected: "out\nerr\n",
+		},
+		"failure": {
+			script:   "echo failed; exit 1",
+			expected: "failed\n",
+			err:      true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			build, output := BuildCommandCapture("sh", "-c", tc.script)
+			for i := 0; i < 2; i++ {
+				if err := build(); (err != nil) != tc.err {
+					t.Errorf("Build err should be %v; got: %v", tc.err, err)
+				}
+				if string(output()) != tc.expected {
+					t.Errorf("Output should be %q; got: %q", tc.expected, output())
+				}
+			}
+		})
+	}
+}
+
+func TestRunCommandMinUptime(t *testing.T) {
+	type testCase struct {
+		sleep     string
+		minUptime time.Duration
+		stopAfter time.Duration
+		earlyExit bool
+	}
+	for name, tc := range map[string]testCase{
+		"exits too quickly": {
+			sleep:     "0.05",
+			minUptime: 300 * time.Millisecond,
+			earlyExit: true,
+		},
+		"exits after min uptime": {
+			sleep:     "0.2",
+			minUptime: 50 * time.Millisecond,
+			earlyExit: false,
+		},
+		"stopped before min uptime": {
+			sleep:     "5",
+			minUptime: 300 * time.Millisecond,
+			stopAfter: 50 * time.Millisecond,
+			earlyExit: false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			earlyExit := make(chan error, 1)
+			opts := commandOptions{
+				MinUptime: tc.minUptime,
+				EarlyExit: func(err error) { earlyExit <- err },
+			}
+
+			stop, err := runCommand(opts, "sleep", tc.sleep)()
+			if err != nil {
+				t.Fatalf("Run func err should be nil; got: %v", err)
+			}
+			if tc.stopAfter > 0 {
+				time.Sleep(tc.stopAfter)
+				stop()
+			}
+
+			select {
+			case err := <-earlyExit:
+				if !tc.earlyExit {
+					t.Errorf("Process should not be reported as exited too quickly; got: %v", err)
+				}
+			case <-time.After(400 * time.Millisecond):
+				if tc.earlyExit {
+					t.Errorf("Process should be reported as exited too quickly")
+				}
+			}
+		})
+	}
+}
+
+func TestExpectArtifacts(t *testing.T) {
+	type testCase struct {
+		create   []string
+		expected []string
+		err      bool
+	}
+	for name, tc := range map[string]testCase{
+		"no artifacts": {
+			err: false,
+		},
+		"produced": {
+			create:   []string{"app"},
+			expected: []string{"app"},
+			err:      false,
+		},
+		"not produced": {
+			create:   []string{"app"},
+			expected: []string{"app", "app.wasm"},
+			err:      true,
+		},
+		"produced pattern": {
+			create:   []string{filepath.Join("bin", "a", "app.exe")},
+			expected: []string{"bin/**/*.exe"},
+			err:      false,
+		},
+		"not produced pattern": {
+			create:   []string{filepath.Join("bin", "a", "app")},
+			expected: []string{"bin/**/*.exe"},
+			err:      true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+
+			builds := []BuildFunc{}
+			for _, file := range tc.create {
+				path := filepath.Join(dir, file)
+				builds = append(builds,
+					BuildCommand("mkdir", "-p", filepath.Dir(path)),
+					BuildCommand("touch", path),
+				)
+			}
+			expected := []string{}
+			for _, file := range tc.expected {
+				expected = append(expected, filepath.Join(dir, file))
+			}
+			builds = append(builds, ExpectArtifacts(expected...))
+
+			_, err := Run(builds, nil)
+			if (err != nil) != tc.err {
+				t.Errorf("Run() err should be %v; got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestActionExpectedArtifacts(t *testing.T) {
+	type testCase struct {
+		workingDir string
+		create     string
+		expected   string
+		err        bool
+	}
+	for name, tc := range map[string]testCase{
+		"dir": {
+			create:   "app",
+			expected: "app",
+			err:      false,
+		},
+		"working dir": {
+			workingDir: "svc",
+			create:     filepath.Join("bin", "app"),
+			expected:   "bin/app",
+			err:        false,
+		},
+		"working dir pattern": {
+			workingDir: "svc",
+			create:     filepath.Join("bin", "app.exe"),
+			expected:   "bin/*.exe",
+			err:        false,
+		},
+		"working dir not produced": {
+			workingDir: "svc",
+			create:     filepath.Join("bin", "app"),
+			expected:   "bin/app.exe",
+			err:        true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			if err := os.MkdirAll(filepath.Join(dir, tc.workingDir), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			config := Config{
+				Dir:   dir,
+				Shell: "sh -c",
+				Actions: []Action{{
+					Patterns:          []string{"**/*"},
+					WorkingDir:        tc.workingDir,
+					BuildCommands:     []string{fmt.Sprintf("mkdir -p %s && touch %s", filepath.Dir(tc.create), tc.create)},
+					ExpectedArtifacts: []string{tc.expected},
+				}},
+			}
+			if tc.workingDir == "" {
+				config.Actions[0].WorkingDir = dir
+			}
+			actions, err := parseActions(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Run(actions[0].BuildFuncs, nil)
+			if (err != nil) != tc.err {
+				t.Errorf("Run() err should be %v; got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestRunCommandRestart(t *testing.T) {
+	type testCase struct {
+		script        string
+		maxRestarts   int
+		backoffWindow time.Duration
+		retryIf       string
+		stopAfter     time.Duration
+		starts        int
+	}
+	for name, tc := range map[string]testCase{
+		"no restarts": {
+			script: "exit 1",
+			starts: 1,
+		},
+		"restarts until max": {
+			script:      "exit 1",
+			maxRestarts: 3,
+			starts:      4,
+		},
+		"retryIf matching": {
+			script:      "exit 3",
+			maxRestarts: 2,
+			retryIf:     "exit status 3",
+			starts:      3,
+		},
+		"retryIf not matching": {
+			script:      "exit 1",
+			maxRestarts: 2,
+			retryIf:     "exit status 3",
+			starts:      1,
+		},
+		"stop cancels restarts": {
+			script:      "sleep 0.1; exit 1",
+			maxRestarts: 10,
+			stopAfter:   150 * time.Millisecond,
+			starts:      2,
+		},
+		"successful window resets restarts": {
+			script:        "sleep 0.05; exit 1",
+			maxRestarts:   1,
+			backoffWindow: 10 * time.Millisecond,
+			stopAfter:     400 * time.Millisecond,
+			starts:        -1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			file := filepath.Join(dir, "starts")
+
+			var mu sync.Mutex
+			restarts := 0
+			opts := commandOptions{
+				MaxRestarts:   tc.maxRestarts,
+				BackoffBase:   5 * time.Millisecond,
+				BackoffWindow: tc.backoffWindow,
+				Restarted: func() {
+					mu.Lock()
+					restarts++
+					mu.Unlock()
+				},
+			}
+			if tc.retryIf != "" {
+				opts.RetryIf = regexp.MustCompile(tc.retryIf)
+			}
+			stop, err := runCommand(opts, "sh", "-c", "echo start >> "+file+"; "+tc.script)()
+			if err != nil {
+				t.Fatalf("Run func err should be nil; got: %v", err)
+			}
+			if tc.stopAfter > 0 {
+				time.Sleep(tc.stopAfter)
+				stop()
+			} else {
+				time.Sleep(300 * time.Millisecond)
+			}
+
+			b, _ := ioutil.ReadFile(file)
+			starts := strings.Count(string(b), "start")
+			if tc.starts < 0 {
+				if starts <= tc.maxRestarts+1 {
+					t.Errorf("Process should be restarted more than %d times; got: %d", tc.maxRestarts, starts-1)
+				}
+				return
+			}
+			if starts != tc.starts {
+				t.Errorf("Process should be started %d times; got: %d", tc.starts, starts)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if restarts != starts-1 {
+				t.Errorf("Restarted should be called %d times; got: %d", starts-1, restarts)
+			}
+		})
+	}
+}
+
 func TestFilter(t *testing.T) {
 	type testCase struct {
 		files, includes, excludes []string
@@ -344,19 +873,235 @@ func TestFilter(t *testing.T) {
 	}
 }
 
-func configEquals(a, b Config) bool {
-	if a.Dir != b.Dir ||
-		len(a.ExcludeDirs) != len(b.ExcludeDirs) ||
-		a.Interval != b.Interval ||
-		len(a.Actions) != len(b.Actions) {
-		return false
+func TestFilterRegex(t *testing.T) {
+	type testCase struct {
+		files, includes, excludes []string
+		changed                   bool
+		err                       bool
 	}
-	for i := 0; i < len(a.Actions); i++ {
-		actionA := a.Actions[i]
-		actionB := b.Actions[i]
+	for name, tc := range map[string]testCase{
+		"empty": {
+			files:    []string{},
+			includes: []string{".*"},
+			changed:  false,
+		},
+		"uuid in name": {
+			files:    []string{"data/3f2b8c1e-9d4a-4c6b-8e2f-1a2b3c4d5e6f.json"},
+			includes: []string{`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`},
+			changed:  true,
+		},
+		"no uuid in name": {
+			files:    []string{"data/config.json"},
+			includes: []string{`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`},
+			changed:  false,
+		},
+		"exclude _test.go files": {
+			files:    []string{"file_test.go"},
+			includes: []string{`\.go$`},
+			excludes: []string{`_test\.go$`},
+			changed:  false,
+		},
+		"invalid include": {
+			includes: []string{"("},
+			err:      true,
+		},
+		"invalid exclude": {
+			includes: []string{".*"},
+			excludes: []string{"[a-"},
+			err:      true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filter, err := FilterRegex(tc.includes, tc.excludes)
+			if (err != nil) != tc.err {
+				t.Fatalf("FilterRegex() err should be %v; got: %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if changed := filter(tc.files); changed != tc.changed {
+				t.Errorf("FilterRegex() should return %v; got: %v", tc.changed, changed)
+			}
+		})
+	}
+}
 
-		if actionA.Name != actionB.Name ||
-			len(actionA.Patterns) != len(actionB.Patterns) ||
+func TestParseActionsNegatedPatterns(t *testing.T) {
+	type testCase struct {
+		patterns, excludes []string
+		expected           []string
+	}
+	files := []string{"main.go", "main_test.go", "vendor/lib.go", "README.md"}
+	for name, tc := range map[string]testCase{
+		"no negation": {
+			patterns: []string{"**/*.go"},
+			excludes: []string{"vendor/**"},
+			expected: []string{"main.go", "main_test.go"},
+		},
+		"negation": {
+			patterns: []string{"**/*.go", "!**/*_test.go"},
+			expected: []string{"main.go", "vendor/lib.go"},
+		},
+		"negation and exclude": {
+			patterns: []string{"!**/*_test.go", "**/*.go"},
+			excludes: []string{"vendor/**"},
+			expected: []string{"main.go"},
+		},
+		"only negation": {
+			patterns: []string{"!**/*.go"},
+			expected: []string{"README.md"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := Config{
+				Actions: []Action{
+					{Patterns: tc.patterns, ExcludePatterns: tc.excludes, BuildCommands: []string{"true"}},
+				},
+			}
+			actions := mustParseActions(t, config)
+			if matched := actions[0].Match(files); !equals(tc.expected, matched) {
+				t.Errorf("Matched files should be: %v; got: %v", tc.expected, matched)
+			}
+		})
+	}
+}
+
+func TestParseActionsPatternType(t *testing.T) {
+	config := Config{
+		Actions: []Action{
+			{
+				PatternType:     "regex",
+				Patterns:        []string{`^cmd/.*\.go$`},
+				ExcludePatterns: []string{`_test\.go$`},
+				BuildCommands:   []string{"true"},
+			},
+		},
+	}
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate() err should be nil; got: %v", err)
+	}
+	actions := mustParseActions(t, config)
+	files := []string{"cmd/main.go", "cmd/main_test.go", "main.go"}
+	if matched := actions[0].Match(files); !equals([]string{"cmd/main.go"}, matched) {
+		t.Errorf("Matched files should be: %v; got: %v", []string{"cmd/main.go"}, matched)
+	}
+
+	config.Actions[0].Patterns = []string{"**/*.go"}
+	if err := config.validate(); err == nil {
+		t.Errorf("validate() err should not be nil for an invalid regex")
+	}
+
+	config.Actions[0].Patterns = nil
+	config.setDefaults()
+	if actions := mustParseActions(t, config); !actions[0].Filter([]string{"main.go"}) {
+		t.Errorf("Regex actions without patterns should match every file")
+	}
+
+	config.Actions[0].PatternType = "fuzzy"
+	if err := config.validate(); err == nil {
+		t.Errorf("validate() err should not be nil for an unknown pattern type")
+	}
+}
+
+func TestComposeFilters(t *testing.T) {
+	goFiles := Filter([]string{"**/*.go"}, nil)
+	makefile := Filter([]string{"Makefile"}, nil)
+
+	type testCase struct {
+		files    []string
+		any, all bool
+	}
+	for name, tc := range map[string]testCase{
+		"empty": {
+			files: []string{},
+			any:   false,
+			all:   false,
+		},
+		"go file": {
+			files: []string{"main.go"},
+			any:   true,
+			all:   false,
+		},
+		"Makefile": {
+			files: []string{"Makefile"},
+			any:   true,
+			all:   false,
+		},
+		"go file and Makefile": {
+			files: []string{"cmd/main.go", "Makefile"},
+			any:   true,
+			all:   true,
+		},
+		"other": {
+			files: []string{"README.md"},
+			any:   false,
+			all:   false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := AnyFilter(goFiles, makefile)(tc.files); got != tc.any {
+				t.Errorf("AnyFilter() should return %v; got: %v", tc.any, got)
+			}
+			if got := AllFilter(goFiles, makefile)(tc.files); got != tc.all {
+				t.Errorf("AllFilter() should return %v; got: %v", tc.all, got)
+			}
+		})
+	}
+
+	if AnyFilter()([]string{"main.go"}) {
+		t.Errorf("AnyFilter() without filters should return false")
+	}
+	if !AllFilter()([]string{"main.go"}) {
+		t.Errorf("AllFilter() without filters should return true")
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	type testCase struct {
+		events             []ChangeEvent
+		includes, excludes []string
+		changed            bool
+	}
+	for name, tc := range map[string]testCase{
+		"empty": {
+			events:  []ChangeEvent{},
+			changed: false,
+		},
+		"included": {
+			events:   []ChangeEvent{{Path: "file.go", Kind: Deleted}},
+			includes: []string{"*.go"},
+			changed:  true,
+		},
+		"excluded": {
+			events:   []ChangeEvent{{Path: "file_test.go", Kind: Modified}},
+			includes: []string{"*.go"},
+			excludes: []string{"*_test.go"},
+			changed:  false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			changed := FilterEvents(tc.includes, tc.excludes)(tc.events)
+			if changed != tc.changed {
+				t.Errorf("FilterEvents() should return %v; got: %v", tc.changed, changed)
+			}
+		})
+	}
+}
+
+func configEquals(a, b Config) bool {
+	if a.Dir != b.Dir ||
+		len(a.ExcludeDirs) != len(b.ExcludeDirs) ||
+		a.Interval != b.Interval ||
+		a.Debounce != b.Debounce ||
+		len(a.Actions) != len(b.Actions) {
+		return false
+	}
+	for i := 0; i < len(a.Actions); i++ {
+		actionA := a.Actions[i]
+		actionB := b.Actions[i]
+
+		if actionA.Name != actionB.Name ||
+			len(actionA.Patterns) != len(actionB.Patterns) ||
 			len(actionA.ExcludePatterns) != len(actionB.ExcludePatterns) ||
 			len(actionA.BuildCommands) != len(actionB.BuildCommands) ||
 			actionA.RunCommand != actionB.RunCommand {
@@ -509,60 +1254,176 @@ run: "echo run"`,
 	}
 }
 
-func TestParseFlags(t *testing.T) {
+func TestParseConfigOverlapPolicy(t *testing.T) {
 	type testCase struct {
-		args   []string
-		config Config
-		err    bool
+		content string
 	}
 	for name, tc := range map[string]testCase{
-		"single build command": {
-			args: []string{"revolver", "-b", "echo 1"},
-			config: Config{
-				Dir:      ".",
-				Interval: 500 * time.Millisecond,
-				Actions: []Action{
-					{
-						Patterns:      []string{"**/*"},
-						BuildCommands: []string{"echo 1"},
-					},
-				},
-			},
+		"simple config": {
+			content: `build: "go test ./..."
+overlapPolicy: "skip"`,
 		},
-		"multiple build command": {
-			args: []string{"revolver", "-b", "echo 1", "-b", "echo 2"},
+		"action": {
+			content: `action:
+  - build: "go test ./..."
+    overlapPolicy: "skip"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := parseConfig([]byte(tc.content))
+			if err != nil {
+				t.Fatalf("ParseConfig() err should be nil; got: %v", err)
+			}
+			if policy := config.Actions[0].queuePolicy(); policy != "skip" {
+				t.Errorf("Queue policy should be skip; got: %q", policy)
+			}
+		})
+	}
+}
+
+func TestActionStopTimeout(t *testing.T) {
+	type testCase struct {
+		config   Config
+		action   Action
+		expected time.Duration
+	}
+	for name, tc := range map[string]testCase{
+		"default": {
+			expected: defaultStopTimeout,
+		},
+		"config": {
+			config:   Config{StopTimeout: time.Second},
+			expected: time.Second,
+		},
+		"action": {
+			config:   Config{StopTimeout: time.Second},
+			action:   Action{StopTimeout: 2 * time.Second},
+			expected: 2 * time.Second,
+		},
+		"grace period": {
+			config:   Config{StopTimeout: time.Second},
+			action:   Action{StopTimeout: 2 * time.Second, GracePeriod: 3 * time.Second},
+			expected: 3 * time.Second,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if timeout := tc.action.stopTimeout(tc.config); timeout != tc.expected {
+				t.Errorf("stopTimeout() should be %v; got: %v", tc.expected, timeout)
+			}
+		})
+	}
+}
+
+func TestParseConfigTOML(t *testing.T) {
+	type testCase struct {
+		content string
+		config  Config
+		err     bool
+	}
+	for name, tc := range map[string]testCase{
+		"config: malformed": {
+			content: `action = [`,
+			err:     true,
+		},
+		"config: full": {
+			content: `dir = "dir"
+excludeDir = ["exclude"]
+interval = "1s"
+
+[[action]]
+name = "action"
+pattern = ["**/*.go"]
+exclude = ["**/*_test.go"]
+build = ["echo build"]
+run = "echo run"`,
 			config: Config{
-				Dir:      ".",
-				Interval: 500 * time.Millisecond,
+				Dir:         "dir",
+				ExcludeDirs: []string{"exclude"},
+				Interval:    1 * time.Second,
 				Actions: []Action{
 					{
-						Patterns:      []string{"**/*"},
-						BuildCommands: []string{"echo 1", "echo 2"},
+						Name:            "action",
+						Patterns:        []string{"**/*.go"},
+						ExcludePatterns: []string{"**/*_test.go"},
+						BuildCommands:   []string{"echo build"},
+						RunCommand:      "echo run",
 					},
 				},
 			},
 		},
-		"run command": {
-			args: []string{"revolver", "-r", "echo 1"},
+		"config: unknown key": {
+			content: `unknown = 1
+
+[[action]]
+build = "echo build"`,
+			err: true,
+		},
+		"simple: without arrays": {
+			content: `excludeDir = "exclude"
+pattern = "**/*.go"
+build = "echo build"`,
 			config: Config{
-				Dir:      ".",
-				Interval: 500 * time.Millisecond,
+				ExcludeDirs: []string{"exclude"},
 				Actions: []Action{
 					{
-						Patterns:   []string{"**/*"},
-						RunCommand: "echo 1",
+						Patterns:      []string{"**/*.go"},
+						BuildCommands: []string{"echo build"},
 					},
 				},
 			},
 		},
-		"full": {
-			args: []string{"revolver", "-d", "dir", "-ed", "exclude", "-i", "1s", "-p", "**/*.go", "-e", "**/*_test.go", "-b", "echo build", "-r", "echo run"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := parseConfigTOML([]byte(tc.content))
+			if err != nil {
+				if !tc.err {
+					t.Errorf("parseConfigTOML() err should be nil; got: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				t.Errorf("parseConfigTOML() err should be %v; got: nil", err)
+				return
+			}
+
+			if !configEquals(*config, tc.config) {
+				t.Errorf("parseConfigTOML() should be %v; got: %v", tc.config, config)
+			}
+		})
+	}
+}
+
+func TestParseConfigJSON(t *testing.T) {
+	type testCase struct {
+		content string
+		config  Config
+		err     bool
+	}
+	for name, tc := range map[string]testCase{
+		"config: malformed": {
+			content: `{"action": [`,
+			err:     true,
+		},
+		"config: full": {
+			content: `{
+	"dir": "dir",
+	"excludeDir": ["exclude"],
+	"interval": "1s",
+	"action": [{
+		"name": "action",
+		"pattern": ["**/*.go"],
+		"exclude": ["**/*_test.go"],
+		"build": ["echo build"],
+		"run": "echo run"
+	}]
+}`,
 			config: Config{
 				Dir:         "dir",
 				ExcludeDirs: []string{"exclude"},
 				Interval:    1 * time.Second,
 				Actions: []Action{
 					{
+						Name:            "action",
 						Patterns:        []string{"**/*.go"},
 						ExcludePatterns: []string{"**/*_test.go"},
 						BuildCommands:   []string{"echo build"},
@@ -571,162 +1432,1653 @@ func TestParseFlags(t *testing.T) {
 				},
 			},
 		},
-		"configFile: not exists": {
-			args: []string{"revolver", "-c", "testdata/not_exists.yml"},
-			err:  true,
-		},
-		"configFile: empty": {
-			args: []string{"revolver", "-c", "testdata/empty.yml"},
-			err:  true,
-		},
-		"configFile: no action": {
-			args: []string{"revolver", "-c", "testdata/no_action.yml"},
-			err:  true,
+		"config: unknown key": {
+			content: `{"unknown": 1, "action": [{"build": "echo build"}]}`,
+			err:     true,
 		},
-		"configFile: no command": {
-			args: []string{"revolver", "-c", "testdata/no_command.yml"},
-			err:  true,
+		"simple: unknown key": {
+			content: `{"build": "echo build", "unknown": true}`,
+			err:     true,
 		},
-		"configFile and build command": {
-			args: []string{"revolver", "-b", "echo 1", "-c", "testdata/no_command.yml"},
+		"simple: numbers": {
+			content: `{"interval": 500000000, "build": "echo build"}`,
 			config: Config{
-				Dir:      ".",
 				Interval: 500 * time.Millisecond,
 				Actions: []Action{
-					{
-						Patterns:      []string{"**/*"},
-						BuildCommands: []string{"echo 1"},
-					},
+					{BuildCommands: []string{"echo build"}},
 				},
 			},
 		},
 	} {
 		t.Run(name, func(t *testing.T) {
-			config, err := ParseFlags(tc.args)
+			config, err := parseConfigJSON([]byte(tc.content))
 			if err != nil {
 				if !tc.err {
-					t.Errorf("ParseFlags() err should be nil; got: %v", err)
+					t.Errorf("parseConfigJSON() err should be nil; got: %v", err)
 				}
 				return
 			}
 			if tc.err {
-				t.Errorf("ParseFlags() err should be %v; got: nil", err)
+				t.Errorf("parseConfigJSON() err should be %v; got: nil", err)
 				return
 			}
 
 			if !configEquals(*config, tc.config) {
-				t.Errorf("ParseFlags() should be %v; got: %v", tc.config, config)
+				t.Errorf("parseConfigJSON() should be %v; got: %v", tc.config, config)
 			}
 		})
 	}
 }
 
-func TestParseActions(t *testing.T) {
-	type testAction struct {
-		id         string
-		name       string
-		buildFuncs int
-		runFunc    bool
-	}
-	equals := func(a action, b testAction) bool {
-		if a.ID != b.id ||
-			a.Name != b.name ||
-			len(a.BuildFuncs) != b.buildFuncs {
-			return false
-		}
-		if b.runFunc {
-			if a.RunFunc == nil {
-				return false
-			}
-		} else {
-			if a.RunFunc != nil {
-				return false
-			}
-		}
-		return true
+func TestParseConfigBytes(t *testing.T) {
+	expected := Config{
+		ExcludeDirs: []string{"exclude"},
+		Actions: []Action{
+			{BuildCommands: []string{"echo build"}},
+		},
 	}
+
 	type testCase struct {
-		actions  []Action
-		expected []testAction
+		content string
+		format  string
+		err     bool
 	}
 	for name, tc := range map[string]testCase{
-		"without name": {
-			actions: []Action{
-				{},
-			},
-			expected: []testAction{
-				{id: "1"},
-			},
+		"yaml": {
+			content: "excludeDir: exclude\nbuild: echo build",
+			format:  "yaml",
 		},
-		"multiple without name": {
-			actions: []Action{
-				{}, {}, {},
+		"yml": {
+			content: "excludeDir: exclude\nbuild: echo build",
+			format:  "YML",
+		},
+		"json": {
+			content: `{"excludeDir": "exclude", "build": "echo build"}`,
+			format:  "json",
+		},
+		"toml": {
+			content: "excludeDir = \"exclude\"\nbuild = \"echo build\"",
+			format:  "toml",
+		},
+		"json in yaml format": {
+			content: `{"excludeDir": "exclude", "build": "echo build"}`,
+			format:  "yaml",
+		},
+		"unknown format": {
+			content: "excludeDir: exclude\nbuild: echo build",
+			format:  "ini",
+			err:     true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := ParseConfigBytes([]byte(tc.content), tc.format)
+			if err != nil {
+				if !tc.err {
+					t.Errorf("ParseConfigBytes() err should be nil; got: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				t.Errorf("ParseConfigBytes() err should be %v; got: nil", err)
+				return
+			}
+
+			if !configEquals(*config, expected) {
+				t.Errorf("ParseConfigBytes() should be %v; got: %v", expected, config)
+			}
+		})
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	for file, content := range map[string]string{
+		"revolver.yml":  "build: echo build",
+		"revolver.json": `{"build": "echo build"}`,
+		"revolver.toml": `build = "echo build"`,
+		"revolver":      "build: echo build",
+	} {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join(dir, file)
+			if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatalf("Cannot write file: %v", err)
+			}
+			config, err := ParseConfigFile(path)
+			if err != nil {
+				t.Fatalf("ParseConfigFile() err should be nil; got: %v", err)
+			}
+			if len(config.Actions) != 1 || !equals(config.Actions[0].BuildCommands, []string{"echo build"}) {
+				t.Errorf("ParseConfigFile() should parse the build command; got: %v", config.Actions)
+			}
+			if config.File != path {
+				t.Errorf("File should be %s; got: %s", path, config.File)
+			}
+		})
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	if err := os.Mkdir(filepath.Join(dir, "scripts"), 0700); err != nil {
+		t.Fatalf("Cannot create dir: %v", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "scripts", "build.sh"), nil, 0700); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+
+	type testCase struct {
+		config Config
+		errs   int
+	}
+	for name, tc := range map[string]testCase{
+		"valid": {
+			config: Config{
+				Dir: dir,
+				Actions: []Action{
+					{BuildCommands: []string{"true"}},
+					{WorkingDir: "scripts", BuildCommands: []string{"./build.sh"}},
+				},
 			},
-			expected: []testAction{
-				{id: "1"}, {id: "2"}, {id: "3"},
+			errs: 0,
+		},
+		"every violation": {
+			config: Config{
+				Dir:         dir,
+				LogFormat:   "xml",
+				StopTimeout: -1,
+				Actions: []Action{
+					{Name: "a", MaxRestarts: -1, BuildCommands: []string{"true"}},
+					{Name: "b", StopSignal: "SIGFOO", BuildCommands: []string{"true"}},
+				},
 			},
+			errs: 4,
 		},
-		"with name": {
-			actions: []Action{
-				{Name: "name"},
+		"missing executables": {
+			config: Config{
+				Dir: dir,
+				Actions: []Action{
+					{BuildCommands: []string{"revolver-not-exists", "true", "./build.sh"}},
+				},
 			},
-			expected: []testAction{
-				{id: "name", name: "name"},
+			errs: 2,
+		},
+		"readiness probe": {
+			config: Config{
+				Dir: dir,
+				Actions: []Action{
+					{BuildCommands: []string{"true"}, ReadinessProbe: &ReadinessProbe{TCP: "localhost:8080"}},
+					{RunCommand: "true", ReadinessProbe: &ReadinessProbe{HTTP: "http://localhost:8080", TCP: "localhost:8080"}},
+				},
 			},
+			// The invalid probe fails parsing the actions too.
+			errs: 3,
 		},
-		"duplicate with name": {
-			actions: []Action{
-				{Name: "name"},
-				{Name: "name"},
+		"unknown onError": {
+			config: Config{
+				Dir:     dir,
+				OnError: "panic",
+				Actions: []Action{{BuildCommands: []string{"true"}}},
 			},
-			expected: []testAction{
-				{id: "name", name: "name"},
-				{id: "name-2", name: "name"},
+			errs: 1,
+		},
+		"onError notify without notifyCommand": {
+			config: Config{
+				Dir:     dir,
+				OnError: "notify",
+				Actions: []Action{{BuildCommands: []string{"true"}}},
 			},
+			errs: 1,
 		},
-		"mixed with and without name": {
-			actions: []Action{
-				{Name: "name"},
-				{},
-				{Name: "name"},
-				{Name: "asdf"},
-				{},
+		"stages": {
+			config: Config{
+				Dir:        dir,
+				StageOrder: []string{"build", "test"},
+				Actions: []Action{
+					{Stage: "build", BuildCommands: []string{"true"}},
+					{Stage: "deploy", BuildCommands: []string{"true"}},
+					{Stage: "test", StageCondition: "sometimes", BuildCommands: []string{"true"}},
+				},
 			},
-			expected: []testAction{
-				{id: "name", name: "name"},
-				{id: "2"},
-				{id: "name-3", name: "name"},
-				{id: "asdf", name: "asdf"},
-				{id: "5"},
+			errs: 2,
+		},
+		"recursive vars": {
+			config: Config{
+				Dir:  dir,
+				Vars: map[string]string{"a": "{{.Vars.b}}", "b": "{{.Vars.a}}"},
+				Actions: []Action{
+					{BuildCommands: []string{"echo {{.Vars.a}}"}},
+				},
 			},
+			errs: 1,
 		},
-		"build funcs": {
-			actions: []Action{
-				{BuildCommands: []string{"echo asdf", "echo asdf"}},
+		"templates": {
+			config: Config{
+				Dir:  dir,
+				Vars: map[string]string{"scripts": "{{.Dir}}/scripts"},
+				Actions: []Action{
+					{BuildCommands: []string{"{{.Vars.scripts}}/build.sh"}},
+				},
 			},
-			expected: []testAction{
-				{id: "1", buildFuncs: 2},
+			errs: 0,
+		},
+		"negative breakAfter": {
+			config: Config{
+				Dir: dir,
+				Actions: []Action{
+					{BreakAfter: -1, BuildCommands: []string{"true"}},
+					{BreakAfter: 3, ResetAfter: -time.Second, BuildCommands: []string{"true"}},
+				},
 			},
+			errs: 2,
 		},
-		"run func": {
-			actions: []Action{
-				{RunCommand: "echo asdf"},
+		"files and dirs": {
+			config: Config{
+				Dir:   dir,
+				Files: []string{"go.mod"},
+				Dirs:  []string{dir},
+				Actions: []Action{
+					{BuildCommands: []string{"true"}},
+				},
 			},
-			expected: []testAction{
-				{id: "1", runFunc: true},
+			errs: 1,
+		},
+		"negative maxDepth": {
+			config: Config{
+				Dir:      dir,
+				MaxDepth: -1,
+				Actions: []Action{
+					{BuildCommands: []string{"true"}},
+				},
+			},
+			errs: 1,
+		},
+		"negative retry": {
+			config: Config{
+				Dir: dir,
+				Actions: []Action{
+					{BuildCommands: []string{"true"}, Retry: -1},
+				},
+			},
+			errs: 1,
+		},
+		"minInterval greater than maxInterval": {
+			config: Config{
+				Dir:         dir,
+				MinInterval: 2 * time.Second,
+				MaxInterval: time.Second,
+				Actions: []Action{
+					{BuildCommands: []string{"true"}},
+				},
+			},
+			errs: 1,
+		},
+		"overlap policy": {
+			config: Config{
+				Dir: dir,
+				Actions: []Action{
+					{BuildCommands: []string{"true"}, OverlapPolicy: "skip"},
+				},
+			},
+			errs: 0,
+		},
+		"unknown overlap policy": {
+			config: Config{
+				Dir: dir,
+				Actions: []Action{
+					{BuildCommands: []string{"true"}, OverlapPolicy: "restart"},
+				},
+			},
+			errs: 1,
+		},
+		"queue and overlap policy": {
+			config: Config{
+				Dir: dir,
+				Actions: []Action{
+					{BuildCommands: []string{"true"}, QueuePolicy: "drop", OverlapPolicy: "drop"},
+				},
 			},
+			errs: 1,
 		},
 	} {
 		t.Run(name, func(t *testing.T) {
-			actions := parseActions(tc.actions)
-			if len(actions) != len(tc.expected) {
-				t.Errorf("Actions length should be: %v; got: %v", len(tc.expected), len(actions))
-				return
+			if errs := ValidateConfig(tc.config); len(errs) != tc.errs {
+				t.Errorf("ValidateConfig() should return %d errors; got: %v", tc.errs, errs)
 			}
-			for i := 0; i < len(actions); i++ {
-				if !equals(actions[i], tc.expected[i]) {
-					t.Errorf("Action should be: %v; got: %v", actions[i], tc.expected[i])
-				}
+		})
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	type testCase struct {
+		args   []string
+		config Config
+		err    bool
+	}
+	for name, tc := range map[string]testCase{
+		"single build command": {
+			args: []string{"revolver", "-b", "echo 1"},
+			config: Config{
+				Dir:      ".",
+				Interval: 500 * time.Millisecond,
+				Actions: []Action{
+					{
+						Patterns:      []string{"**/*"},
+						BuildCommands: []string{"echo 1"},
+					},
+				},
+			},
+		},
+		"dry run": {
+			args: []string{"revolver", "-dry-run", "-b", "echo 1"},
+			config: Config{
+				Dir:      ".",
+				Interval: 500 * time.Millisecond,
+				DryRun:   true,
+				Actions: []Action{
+					{
+						Patterns:      []string{"**/*"},
+						BuildCommands: []string{"echo 1"},
+					},
+				},
+			},
+		},
+		"upgrade check": {
+			args: []string{"revolver", "-upgrade-check", "-b", "echo 1"},
+			config: Config{
+				Dir:             ".",
+				Interval:        500 * time.Millisecond,
+				CheckForUpdates: true,
+				Actions: []Action{
+					{
+						Patterns:      []string{"**/*"},
+						BuildCommands: []string{"echo 1"},
+					},
+				},
+			},
+		},
+		"multiple build command": {
+			args: []string{"revolver", "-b", "echo 1", "-b", "echo 2"},
+			config: Config{
+				Dir:      ".",
+				Interval: 500 * time.Millisecond,
+				Actions: []Action{
+					{
+						Patterns:      []string{"**/*"},
+						BuildCommands: []string{"echo 1", "echo 2"},
+					},
+				},
+			},
+		},
+		"run command": {
+			args: []string{"revolver", "-r", "echo 1"},
+			config: Config{
+				Dir:      ".",
+				Interval: 500 * time.Millisecond,
+				Actions: []Action{
+					{
+						Patterns:   []string{"**/*"},
+						RunCommand: "echo 1",
+					},
+				},
+			},
+		},
+		"full": {
+			args: []string{"revolver", "-d", "dir", "-ed", "exclude", "-i", "1s", "-p", "**/*.go", "-e", "**/*_test.go", "-b", "echo build", "-r", "echo run"},
+			config: Config{
+				Dir:         "dir",
+				ExcludeDirs: []string{"exclude"},
+				Interval:    1 * time.Second,
+				Actions: []Action{
+					{
+						Patterns:        []string{"**/*.go"},
+						ExcludePatterns: []string{"**/*_test.go"},
+						BuildCommands:   []string{"echo build"},
+						RunCommand:      "echo run",
+					},
+				},
+			},
+		},
+		"flag-only mode": {
+			args: []string{"revolver", "--watch", "dir", "--interval", "1s", "--debounce", "100ms", "--pattern", "**/*.go", "--exclude", "**/*_test.go", "--build", "go build .", "--run", "./app"},
+			config: Config{
+				Dir:      "dir",
+				Interval: 1 * time.Second,
+				Debounce: 100 * time.Millisecond,
+				Actions: []Action{
+					{
+						Patterns:        []string{"**/*.go"},
+						ExcludePatterns: []string{"**/*_test.go"},
+						BuildCommands:   []string{"go build ."},
+						RunCommand:      "./app",
+					},
+				},
+			},
+		},
+		"print only": {
+			args: []string{"revolver", "-print-only", "-print-format", "json", "-d", "dir"},
+			config: Config{
+				Dir:      "dir",
+				Interval: 500 * time.Millisecond,
+			},
+		},
+		"print only: unknown format": {
+			args: []string{"revolver", "-print-only", "-print-format", "xml"},
+			err:  true,
+		},
+		"unclosed quote": {
+			args: []string{"revolver", "-b", `go build -ldflags "-X main.version=1.0`},
+			err:  true,
+		},
+		"invalid selector": {
+			args: []string{"revolver", "-b", "echo 1", "-selector", "=dev"},
+			err:  true,
+		},
+		"configFile: not exists": {
+			args: []string{"revolver", "-c", "testdata/not_exists.yml"},
+			err:  true,
+		},
+		"configFile: empty": {
+			args: []string{"revolver", "-c", "testdata/empty.yml"},
+			err:  true,
+		},
+		"configFile: no action": {
+			args: []string{"revolver", "-c", "testdata/no_action.yml"},
+			err:  true,
+		},
+		"configFile: no command": {
+			args: []string{"revolver", "-c", "testdata/no_command.yml"},
+			err:  true,
+		},
+		"configFile: toml": {
+			args: []string{"revolver", "-c", "testdata/full.toml"},
+			config: Config{
+				Dir:         "dir",
+				ExcludeDirs: []string{"exclude"},
+				Interval:    1 * time.Second,
+				Actions: []Action{
+					{
+						Name:            "action",
+						Patterns:        []string{"**/*.go"},
+						ExcludePatterns: []string{"**/*_test.go"},
+						BuildCommands:   []string{"echo build"},
+						RunCommand:      "echo run",
+					},
+				},
+			},
+		},
+		"configFile and build command": {
+			args: []string{"revolver", "-b", "echo 1", "-c", "testdata/no_command.yml"},
+			config: Config{
+				Dir:      ".",
+				Interval: 500 * time.Millisecond,
+				Actions: []Action{
+					{
+						Name:          "action",
+						Patterns:      []string{"**/*"},
+						BuildCommands: []string{"echo 1"},
+					},
+				},
+			},
+		},
+		"long flags": {
+			args: []string{"revolver", "--dir", "dir", "--build", "echo build", "--run", "echo run"},
+			config: Config{
+				Dir:      "dir",
+				Interval: 500 * time.Millisecond,
+				Actions: []Action{
+					{
+						Patterns:      []string{"**/*"},
+						BuildCommands: []string{"echo build"},
+						RunCommand:    "echo run",
+					},
+				},
+			},
+		},
+		"configFile: overridden by flags": {
+			args: []string{"revolver", "-c", "testdata/full.toml", "--dir", "other", "--build", "echo other"},
+			config: Config{
+				Dir:         "other",
+				ExcludeDirs: []string{"exclude"},
+				Interval:    1 * time.Second,
+				Actions: []Action{
+					{
+						Name:            "action",
+						Patterns:        []string{"**/*.go"},
+						ExcludePatterns: []string{"**/*_test.go"},
+						BuildCommands:   []string{"echo other"},
+						RunCommand:      "echo run",
+					},
+				},
+			},
+		},
+		"configFile: debounce overridden by flags": {
+			args: []string{"revolver", "-c", "testdata/full.toml", "--debounce", "200ms"},
+			config: Config{
+				Dir:         "dir",
+				ExcludeDirs: []string{"exclude"},
+				Interval:    1 * time.Second,
+				Debounce:    200 * time.Millisecond,
+				Actions: []Action{
+					{
+						Name:            "action",
+						Patterns:        []string{"**/*.go"},
+						ExcludePatterns: []string{"**/*_test.go"},
+						BuildCommands:   []string{"echo build"},
+						RunCommand:      "echo run",
+					},
+				},
+			},
+		},
+		"configFile: action flags with multiple actions": {
+			args: []string{"revolver", "-c", "testdata/multiple_actions.yml", "-b", "echo 1"},
+			err:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config, err := ParseFlags(tc.args)
+			if err != nil {
+				if !tc.err {
+					t.Errorf("ParseFlags() err should be nil; got: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				t.Errorf("ParseFlags() err should be %v; got: nil", err)
+				return
+			}
+
+			if !configEquals(*config, tc.config) {
+				t.Errorf("ParseFlags() should be %v; got: %v", tc.config, config)
+			}
+		})
+	}
+}
+
+func mustParseActions(t *testing.T, config Config) []action {
+	actions, err := parseActions(config)
+	if err != nil {
+		t.Fatalf("parseActions() err should be nil; got: %v", err)
+	}
+	return actions
+}
+
+func TestParseActions(t *testing.T) {
+	type testAction struct {
+		id         string
+		name       string
+		buildFuncs int
+		runFunc    bool
+	}
+	equals := func(a action, b testAction) bool {
+		if a.ID != b.id ||
+			a.Name != b.name ||
+			len(a.BuildFuncs) != b.buildFuncs {
+			return false
+		}
+		if b.runFunc {
+			if a.RunFunc == nil {
+				return false
+			}
+		} else {
+			if a.RunFunc != nil {
+				return false
+			}
+		}
+		return true
+	}
+	type testCase struct {
+		actions  []Action
+		expected []testAction
+	}
+	for name, tc := range map[string]testCase{
+		"without name": {
+			actions: []Action{
+				{},
+			},
+			expected: []testAction{
+				{id: "1"},
+			},
+		},
+		"multiple without name": {
+			actions: []Action{
+				{}, {}, {},
+			},
+			expected: []testAction{
+				{id: "1"}, {id: "2"}, {id: "3"},
+			},
+		},
+		"with name": {
+			actions: []Action{
+				{Name: "name"},
+			},
+			expected: []testAction{
+				{id: "name", name: "name"},
+			},
+		},
+		"duplicate with name": {
+			actions: []Action{
+				{Name: "name"},
+				{Name: "name"},
+			},
+			expected: []testAction{
+				{id: "name", name: "name"},
+				{id: "name-2", name: "name"},
+			},
+		},
+		"mixed with and without name": {
+			actions: []Action{
+				{Name: "name"},
+				{},
+				{Name: "name"},
+				{Name: "asdf"},
+				{},
+			},
+			expected: []testAction{
+				{id: "name", name: "name"},
+				{id: "2"},
+				{id: "name-3", name: "name"},
+				{id: "asdf", name: "asdf"},
+				{id: "5"},
+			},
+		},
+		"build funcs": {
+			actions: []Action{
+				{BuildCommands: []string{"echo asdf", "echo asdf"}},
+			},
+			expected: []testAction{
+				{id: "1", buildFuncs: 2},
+			},
+		},
+		"run func": {
+			actions: []Action{
+				{RunCommand: "echo asdf"},
+			},
+			expected: []testAction{
+				{id: "1", runFunc: true},
+			},
+		},
+		"cross compile": {
+			actions: []Action{
+				{
+					Name:          "name",
+					BuildCommands: []string{"go build"},
+					CrossCompile: []CrossCompileTarget{
+						{GOOS: "linux", GOARCH: "arm64"},
+						{GOOS: "js", GOARCH: "wasm"},
+					},
+				},
+				{},
+			},
+			expected: []testAction{
+				{id: "name-linux-arm64", name: "name", buildFuncs: 1},
+				{id: "name-js-wasm", name: "name", buildFuncs: 1},
+				{id: "2"},
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actions := mustParseActions(t, Config{Actions: tc.actions})
+			if len(actions) != len(tc.expected) {
+				t.Errorf("Actions length should be: %v; got: %v", len(tc.expected), len(actions))
+				return
+			}
+			for i := 0; i < len(actions); i++ {
+				if !equals(actions[i], tc.expected[i]) {
+					t.Errorf("Action should be: %v; got: %v", actions[i], tc.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseActionsCrossCompileEnv(t *testing.T) {
+	targets := []CrossCompileTarget{
+		{GOOS: "linux", GOARCH: "arm64", OutputDir: "bin/linux"},
+		{GOOS: "windows", GOARCH: "amd64"},
+		{GOOS: "js", GOARCH: "wasm"},
+	}
+	actions := mustParseActions(t, Config{Actions: []Action{
+		{BuildCommands: []string{"env"}, CrossCompile: targets},
+	}})
+	if len(actions) != len(targets) {
+		t.Fatalf("Actions length should be: %v; got: %v", len(targets), len(actions))
+	}
+
+	for i, target := range targets {
+		var output bytes.Buffer
+		actions[i].Output.Set(&output)
+		if _, err := Run(actions[i].BuildFuncs, actions[i].RunFunc); err != nil {
+			t.Fatalf("Run() err should be nil; got: %v", err)
+		}
+
+		env := strings.Split(output.String(), "\n")
+		for _, e := range target.env() {
+			if !contains(env, e) {
+				t.Errorf("Env of action %s should contain: %v", actions[i].ID, e)
+			}
+		}
+	}
+}
+
+func TestDebounce(t *testing.T) {
+	type testCase struct {
+		changes  []string
+		detected [][]string
+		expected []string
+	}
+	for name, tc := range map[string]testCase{
+		"no further changes": {
+			changes:  []string{"a"},
+			expected: []string{"a"},
+		},
+		"further changes": {
+			changes:  []string{"a"},
+			detected: [][]string{{"b"}, {}, {"c", "d"}},
+			expected: []string{"a", "b", "c", "d"},
+		},
+		"duplicate changes": {
+			changes:  []string{"a"},
+			detected: [][]string{{"a", "b"}, {"b"}},
+			expected: []string{"a", "b"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			detect := func() []string {
+				calls++
+				if calls > len(tc.detected) {
+					return []string{}
+				}
+				return tc.detected[calls-1]
+			}
+
+			changes := debounce(detect, tc.changes, 50*time.Millisecond, 5*time.Millisecond)
+
+			if !equals(tc.expected, changes) {
+				t.Errorf("Changes should be: %v; got: %v", tc.expected, changes)
+			}
+		})
+	}
+}
+
+func TestActionTriggered(t *testing.T) {
+	type testCase struct {
+		count     ChangedFileCount
+		changes   []string
+		triggered bool
+	}
+	for name, tc := range map[string]testCase{
+		"no limit: single file": {
+			changes:   []string{"a.go"},
+			triggered: true,
+		},
+		"no limit: no matching file": {
+			changes:   []string{"a.txt"},
+			triggered: false,
+		},
+		"min: single file": {
+			count:     ChangedFileCount{Min: 3},
+			changes:   []string{"a.go"},
+			triggered: false,
+		},
+		"min: batch": {
+			count:     ChangedFileCount{Min: 3},
+			changes:   []string{"a.go", "b.go", "c.go", "d.go"},
+			triggered: true,
+		},
+		"min: batch with not matching files": {
+			count:     ChangedFileCount{Min: 3},
+			changes:   []string{"a.go", "b.go", "c.txt", "d.txt"},
+			triggered: false,
+		},
+		"max: batch": {
+			count:     ChangedFileCount{Max: 3},
+			changes:   []string{"a.go", "b.go", "c.go"},
+			triggered: true,
+		},
+		"max: over limit": {
+			count:     ChangedFileCount{Max: 3},
+			changes:   []string{"a.go", "b.go", "c.go", "d.go"},
+			triggered: false,
+		},
+		"min and max: batch": {
+			count:     ChangedFileCount{Min: 2, Max: 3},
+			changes:   []string{"a.go", "b.go"},
+			triggered: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actions := mustParseActions(t, Config{Actions: []Action{
+				{Patterns: []string{"*.go"}, ChangedFileCount: tc.count},
+			}})
+			if triggered := actions[0].triggered(tc.changes); triggered != tc.triggered {
+				t.Errorf("triggered() should return %v; got: %v", tc.triggered, triggered)
+			}
+		})
+	}
+}
+
+func TestRunCycleParallel(t *testing.T) {
+	type testCase struct {
+		parallel bool
+		max      time.Duration
+		min      time.Duration
+	}
+	for name, tc := range map[string]testCase{
+		"sequential": {
+			parallel: false,
+			min:      400 * time.Millisecond,
+		},
+		"parallel": {
+			parallel: true,
+			max:      350 * time.Millisecond,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := Config{
+				Parallel: tc.parallel,
+				Actions: []Action{
+					{Patterns: []string{"*.go"}, BuildCommands: []string{"sleep 0.2"}},
+					{Patterns: []string{"*.css"}, BuildCommands: []string{"sleep 0.2"}},
+				},
+			}
+			actions := mustParseActions(t, config)
+
+			start := time.Now()
+			newRunner(config).runCycle(triggered(actions, []string{"main.go", "style.css"}), 1)
+			elapsed := time.Since(start)
+
+			if tc.min > 0 && elapsed < tc.min {
+				t.Errorf("Cycle should take at least %v; took: %v", tc.min, elapsed)
+			}
+			if tc.max > 0 && elapsed > tc.max {
+				t.Errorf("Cycle should take at most %v; took: %v", tc.max, elapsed)
+			}
+		})
+	}
+}
+
+func TestRunChanges(t *testing.T) {
+	type testCase struct {
+		maxRetriggers int
+		changingRuns  int
+		expectedRuns  int
+	}
+
+	testCases := map[string]testCase{
+		"disabled": {
+			maxRetriggers: 0,
+			changingRuns:  1,
+			expectedRuns:  1,
+		},
+		"change during build": {
+			maxRetriggers: 3,
+			changingRuns:  1,
+			expectedRuns:  2,
+		},
+		"no change during build": {
+			maxRetriggers: 3,
+			changingRuns:  0,
+			expectedRuns:  1,
+		},
+		"limited retriggers": {
+			maxRetriggers: 2,
+			changingRuns:  10,
+			expectedRuns:  3,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+
+			detect := Detect(dir, nil)
+			detect()
+
+			runs := 0
+			build := func() error {
+				runs++
+				if runs <= tc.changingRuns {
+					createTempFile(t, dir, "")
+				}
+				return nil
+			}
+
+			config := Config{MaxImmediateRetriggers: tc.maxRetriggers}
+			actions := []action{{
+				ID:         "build",
+				Filter:     Filter([]string{"**/*"}, nil),
+				Match:      func(files []string) []string { return files },
+				BuildFuncs: []BuildFunc{build},
+			}}
+			r := newRunner(config)
+			r.logger = NewLogger("text", ioutil.Discard)
+			r.breaker.logger = r.logger
+
+			cycle := r.runChanges(detect, actions, []string{"main.go"}, 0)
+			if runs != tc.expectedRuns {
+				t.Errorf("Build should run %d times; got: %d", tc.expectedRuns, runs)
+			}
+			if cycle != tc.expectedRuns {
+				t.Errorf("Last cycle should be %d; got: %d", tc.expectedRuns, cycle)
+			}
+		})
+	}
+}
+
+func TestRunCycleBreakAfter(t *testing.T) {
+	type testCase struct {
+		resetAfter time.Duration
+		wait       time.Duration
+		reset      bool
+		expected   int
+	}
+	for name, tc := range map[string]testCase{
+		"broken": {
+			expected: 2,
+		},
+		"manual reset": {
+			reset:    true,
+			expected: 3,
+		},
+		"cooldown not elapsed": {
+			resetAfter: time.Minute,
+			expected:   2,
+		},
+		"cooldown elapsed": {
+			resetAfter: 10 * time.Millisecond,
+			wait:       20 * time.Millisecond,
+			expected:   3,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			runs := 0
+			a := action{
+				ID: "build",
+				BuildFuncs: []BuildFunc{func() error {
+					runs++
+					return errors.New("build failed")
+				}},
+				Source: actionSource{
+					Action: Action{BreakAfter: 2, ResetAfter: tc.resetAfter},
+				},
+			}
+			r := newRunner(Config{})
+			r.logger = NewLogger("text", ioutil.Discard)
+
+			for cycle := 1; cycle <= 3; cycle++ {
+				r.runCycle([]action{a}, cycle)
+			}
+			time.Sleep(tc.wait)
+			if tc.reset {
+				r.resetBreakers()
+			}
+			r.runCycle([]action{a}, 4)
+
+			if runs != tc.expected {
+				t.Errorf("Build should run %d times; got: %d", tc.expected, runs)
+			}
+		})
+	}
+}
+
+func TestRunCycleBreakAfterHalfOpen(t *testing.T) {
+	failing := action{
+		ID:         "failing",
+		BuildFuncs: []BuildFunc{func() error { return errors.New("build failed") }},
+		Source: actionSource{
+			Action: Action{BreakAfter: 1, ResetAfter: time.Minute},
+		},
+	}
+	runs := 0
+	passing := action{
+		ID: "passing",
+		BuildFuncs: []BuildFunc{func() error {
+			runs++
+			return nil
+		}},
+	}
+	r := newRunner(Config{CircuitBreaker: CircuitBreaker{Threshold: 1, ResetAfter: 10 * time.Millisecond}})
+	r.logger = NewLogger("text", ioutil.Discard)
+
+	// The failure opens both the global and the action breaker.
+	r.runCycle([]action{failing}, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	// The global breaker is half-open, but the action breaker refuses.
+	r.runCycle([]action{failing}, 2)
+	r.runCycle([]action{passing}, 3)
+	r.runCycle([]action{passing}, 4)
+
+	if runs != 2 {
+		t.Errorf("Trial of the global breaker should be left for the next build; got %d builds", runs)
+	}
+}
+
+func TestRunCycleHooks(t *testing.T) {
+	type testCase struct {
+		build    string
+		actions  bool
+		expected string
+	}
+	for name, tc := range map[string]testCase{
+		"success": {
+			build:    "true",
+			actions:  true,
+			expected: "before\nbuild\nlint\nafter true\n",
+		},
+		"failure": {
+			build:    "false",
+			actions:  true,
+			expected: "before\nlint\nafter false\n",
+		},
+		"no actions": {
+			expected: "",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			log := filepath.Join(dir, "log")
+
+			config := Config{
+				Shell:       "sh -c",
+				BeforeCycle: "echo before >> " + log,
+				AfterCycle:  "echo after $REVOLVER_CYCLE_SUCCESS >> " + log + "; test -n \"$REVOLVER_CYCLE_DURATION_MS\"",
+				Actions: []Action{
+					{Name: "build", BuildCommands: []string{tc.build + " && echo build >> " + log}},
+					{Name: "lint", BuildCommands: []string{"echo lint >> " + log}},
+				},
+			}
+			r := newRunner(config)
+			r.logger = NewLogger("text", ioutil.Discard)
+			var actions []action
+			if tc.actions {
+				actions = mustParseActions(t, config)
+			}
+			r.runCycle(actions, 1)
+
+			b, _ := ioutil.ReadFile(log)
+			if string(b) != tc.expected {
+				t.Errorf("Commands should run in order: %q; got: %q", tc.expected, b)
+			}
+		})
+	}
+}
+
+func TestRunnerDebounce(t *testing.T) {
+	config := Config{
+		Actions: []Action{
+			{Name: "fast", Patterns: []string{"*.go"}, BuildCommands: []string{"true"}},
+			{Name: "slow", Patterns: []string{"*.go"}, Debounce: 100 * time.Millisecond, BuildCommands: []string{"true"}},
+		},
+	}
+	actions := mustParseActions(t, config)
+	r := newRunner(config)
+	r.logger = NewLogger("text", ioutil.Discard)
+	var builds []BuildRecord
+	r.onBuild = func(record BuildRecord) { builds = append(builds, record) }
+
+	type step struct {
+		sleep    time.Duration
+		changes  []string
+		expected []string
+	}
+	for i, step := range []step{
+		{changes: []string{"a.go"}, expected: []string{"fast"}},
+		{sleep: 60 * time.Millisecond, changes: []string{"b.go"}, expected: []string{"fast"}},
+		{sleep: 60 * time.Millisecond, expected: []string{}},
+		{sleep: 60 * time.Millisecond, expected: []string{"slow"}},
+		{sleep: 120 * time.Millisecond, expected: []string{}},
+	} {
+		time.Sleep(step.sleep)
+		builds = nil
+		cycle := r.runDebounced(actions, i)
+		if len(step.changes) > 0 {
+			r.runChanges(func() []string { return nil }, actions, step.changes, cycle)
+		}
+
+		built := []string{}
+		for _, build := range builds {
+			built = append(built, build.Action)
+		}
+		if !equals(step.expected, built) {
+			t.Fatalf("Step %d should build: %v; got: %v", i, step.expected, built)
+		}
+		if len(built) == 1 && built[0] == "slow" && !equals([]string{"a.go", "b.go"}, builds[0].TriggeredBy) {
+			t.Errorf("Debounced build should be triggered by every change; got: %v", builds[0].TriggeredBy)
+		}
+	}
+}
+
+func TestParseActionsEnv(t *testing.T) {
+	os.Setenv("REVOLVER_TEST_OS", "os")
+	defer os.Unsetenv("REVOLVER_TEST_OS")
+
+	actions := mustParseActions(t, Config{
+		Env: map[string]string{
+			"REVOLVER_TEST_GLOBAL": "global",
+			"REVOLVER_TEST_ACTION": "global",
+		},
+		Actions: []Action{
+			{
+				BuildCommands: []string{"env"},
+				Env:           map[string]string{"REVOLVER_TEST_ACTION": "action"},
+			},
+		},
+	})
+
+	var output bytes.Buffer
+	actions[0].Output.Set(&output)
+	if _, err := Run(actions[0].BuildFuncs, actions[0].RunFunc); err != nil {
+		t.Fatalf("Run() err should be nil; got: %v", err)
+	}
+
+	env := strings.Split(output.String(), "\n")
+	for _, e := range []string{
+		"REVOLVER_TEST_OS=os",
+		"REVOLVER_TEST_GLOBAL=global",
+		"REVOLVER_TEST_ACTION=action",
+	} {
+		if !contains(env, e) {
+			t.Errorf("Env should contain: %v", e)
+		}
+	}
+	if contains(env, "REVOLVER_TEST_ACTION=global") {
+		t.Errorf("Action env should override global env")
+	}
+}
+
+func TestRunCycleBudget(t *testing.T) {
+	type testCase struct {
+		parallel        bool
+		expectedActions []string
+		canceled        []string
+	}
+	for name, tc := range map[string]testCase{
+		"sequential": {
+			expectedActions: []string{"fast", "slow"},
+			canceled:        []string{"slow"},
+		},
+		"parallel": {
+			parallel:        true,
+			expectedActions: []string{"fast", "slow", "skipped"},
+			canceled:        []string{"slow", "skipped"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			marker := filepath.Join(dir, "started")
+
+			config := Config{
+				CycleBudget: 200 * time.Millisecond,
+				Parallel:    tc.parallel,
+				Actions: []Action{
+					{Name: "fast", BuildCommands: []string{"true"}},
+					{Name: "slow", BuildCommands: []string{"sleep 5"}},
+					{Name: "skipped", BuildCommands: []string{"sleep 5", "touch " + marker}},
+				},
+			}
+			r := newRunner(config)
+			r.logger = NewLogger("text", ioutil.Discard)
+
+			start := time.Now()
+			result := r.runCycle(mustParseActions(t, config), 1)
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Cycle should be canceled after its budget; took: %v", elapsed)
+			}
+
+			if !equals(tc.expectedActions, result.Actions) {
+				t.Errorf("Started actions should be: %v; got: %v", tc.expectedActions, result.Actions)
+			}
+			for _, id := range tc.canceled {
+				if err := result.Errors[id]; err == nil || !strings.Contains(err.Error(), "canceled") {
+					t.Errorf("Action %s should be canceled; got: %v", id, err)
+				}
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Errorf("Actions beyond the budget should not be started")
+			}
+		})
+	}
+}
+
+func TestRunCycleStages(t *testing.T) {
+	type testCase struct {
+		build           string
+		expectedActions []string
+	}
+	for name, tc := range map[string]testCase{
+		"success": {
+			build:           "true",
+			expectedActions: []string{"build", "lint", "test", "cleanup"},
+		},
+		"failure": {
+			build:           "false",
+			expectedActions: []string{"build", "lint", "report", "cleanup"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := Config{
+				StageOrder: []string{"build", "test", "finally"},
+				Actions: []Action{
+					{Name: "test", Stage: "test", BuildCommands: []string{"true"}},
+					{Name: "report", Stage: "test", StageCondition: StageFailure, BuildCommands: []string{"true"}},
+					{Name: "build", Stage: "build", BuildCommands: []string{tc.build}},
+					{Name: "lint", Stage: "build", BuildCommands: []string{"true"}},
+					{Name: "cleanup", Stage: "finally", StageCondition: StageAlways, BuildCommands: []string{"true"}},
+				},
+			}
+			r := newRunner(config)
+			r.logger = NewLogger("text", ioutil.Discard)
+
+			result := r.runCycle(mustParseActions(t, config), 1)
+			if !equals(tc.expectedActions, result.Actions) {
+				t.Errorf("Started actions should be: %v; got: %v", tc.expectedActions, result.Actions)
+			}
+			for i, id := range result.Actions {
+				if id == "cleanup" && i != len(result.Actions)-1 {
+					t.Errorf("Actions of a stage should run after the previous stages; got: %v", result.Actions)
+				}
+			}
+		})
+	}
+}
+
+func TestRunnerTriggeredBy(t *testing.T) {
+	config := Config{
+		Actions: []Action{
+			{Name: "server", Patterns: []string{"**/*.go"}, BuildCommands: []string{"true"}},
+			{Name: "docs", Patterns: []string{"**/*.md"}, BuildCommands: []string{"true"}},
+		},
+	}
+	actions := mustParseActions(t, config)
+
+	logger := &recordLogger{}
+	records := []BuildRecord{}
+	r := newRunner(config)
+	r.logger = logger
+	r.onBuild = func(record BuildRecord) {
+		records = append(records, record)
+	}
+
+	changes := []string{"main.go", "cmd/app/app.go", "style.css"}
+	r.runCycle(r.triggered(actions, changes, 1), 1)
+
+	if len(records) != 1 {
+		t.Fatalf("Only server should be built; got: %+v", records)
+	}
+	expected := []string{"main.go", "cmd/app/app.go"}
+	if records[0].Action != "server" || !equals(expected, records[0].TriggeredBy) {
+		t.Errorf("Build should be triggered by %v; got: %+v", expected, records[0])
+	}
+	if records[0].Err != nil {
+		t.Errorf("Build err should be nil; got: %v", records[0].Err)
+	}
+	if !contains(logger.lines, "info: [server] Building, triggered by: main.go, cmd/app/app.go") {
+		t.Errorf("Build start should log the triggering files; got: %v", logger.lines)
+	}
+}
+
+func TestRunnerMaxConcurrent(t *testing.T) {
+	type testCase struct {
+		maxConcurrent int
+		policy        string
+		overlapPolicy string
+		expectedRuns  int
+		expectedPeak  int
+	}
+	for name, tc := range map[string]testCase{
+		"default": {
+			expectedRuns: 5,
+			expectedPeak: 1,
+		},
+		"queue": {
+			maxConcurrent: 2,
+			policy:        "queue",
+			expectedRuns:  5,
+			expectedPeak:  2,
+		},
+		"drop": {
+			maxConcurrent: 2,
+			policy:        "drop",
+			expectedRuns:  2,
+			expectedPeak:  2,
+		},
+		"skip": {
+			maxConcurrent: 1,
+			policy:        "skip",
+			expectedRuns:  1,
+			expectedPeak:  1,
+		},
+		"overlap policy": {
+			maxConcurrent: 1,
+			overlapPolicy: "skip",
+			expectedRuns:  1,
+			expectedPeak:  1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var mu sync.Mutex
+			running, peak, runs := 0, 0, 0
+			build := func() error {
+				mu.Lock()
+				running++
+				runs++
+				if running > peak {
+					peak = running
+				}
+				mu.Unlock()
+
+				time.Sleep(50 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return nil
+			}
+
+			a := action{
+				ID:         "build",
+				BuildFuncs: []BuildFunc{build},
+				Source: actionSource{
+					Action: Action{MaxConcurrent: tc.maxConcurrent, QueuePolicy: tc.policy, OverlapPolicy: tc.overlapPolicy},
+				},
+			}
+			r := newRunner(Config{})
+			r.logger = NewLogger("text", ioutil.Discard)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := r.runAction(a, 1); err != nil && !errors.Is(err, errBuildSkipped) {
+						t.Errorf("runAction() err should be nil or errBuildSkipped; got: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if runs != tc.expectedRuns {
+				t.Errorf("Build should run %d times; got: %d", tc.expectedRuns, runs)
+			}
+			if peak != tc.expectedPeak {
+				t.Errorf("At most %d builds should run at once; got: %d", tc.expectedPeak, peak)
+			}
+		})
+	}
+}
+
+func TestRunCycleDroppedBuild(t *testing.T) {
+	failing := action{
+		ID:         "failing",
+		BuildFuncs: []BuildFunc{func() error { return errors.New("build failed") }},
+	}
+	started, done := make(chan struct{}), make(chan struct{})
+	slow := action{
+		ID: "slow",
+		BuildFuncs: []BuildFunc{func() error {
+			close(started)
+			<-done
+			return nil
+		}},
+		Source: actionSource{
+			Action: Action{MaxConcurrent: 1, QueuePolicy: "drop"},
+		},
+	}
+	r := newRunner(Config{CircuitBreaker: CircuitBreaker{Threshold: 1, ResetAfter: 10 * time.Millisecond}})
+	r.logger = NewLogger("text", ioutil.Discard)
+
+	r.runCycle([]action{failing}, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	// The running build holds the only slot of the action.
+	go r.runAction(slow, 2)
+	<-started
+	result := r.runCycle([]action{slow}, 3)
+	close(done)
+
+	if len(result.Actions) != 0 || !result.Success() {
+		t.Errorf("Dropped build should not be in the result; got: %+v", result)
+	}
+	r.breaker.mu.Lock()
+	defer r.breaker.mu.Unlock()
+	if r.breaker.state != circuitHalfOpen || r.breaker.trial {
+		t.Errorf("Dropped build should not close the circuit breaker nor take its trial")
+	}
+}
+
+func TestActionHooks(t *testing.T) {
+	type testCase struct {
+		build    string
+		expected string
+		missing  string
+	}
+	for name, tc := range map[string]testCase{
+		"success": {
+			build:    "true",
+			expected: "success",
+			missing:  "failure",
+		},
+		"failure": {
+			build:    "false",
+			expected: "failure",
+			missing:  "success",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+
+			config := Config{
+				Shell: "sh -c",
+				Actions: []Action{
+					{
+						Name:          "build",
+						BuildCommands: []string{tc.build},
+						OnSuccess:     "echo $REVOLVER_ACTION > " + filepath.Join(dir, "success"),
+						OnFailure:     "echo $REVOLVER_ACTION $REVOLVER_ERROR > " + filepath.Join(dir, "failure"),
+					},
+				},
+			}
+			actions := mustParseActions(t, config)
+			r := newRunner(config)
+			r.logger = NewLogger("text", ioutil.Discard)
+
+			err := r.runAction(actions[0], 1)
+			if (err != nil) != (tc.expected == "failure") {
+				t.Fatalf("runAction() err should be nil only on success; got: %v", err)
+			}
+
+			file := filepath.Join(dir, tc.expected)
+			waitForPath(t, file)
+			time.Sleep(20 * time.Millisecond)
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Cannot read file: %v", err)
+			}
+			if !strings.HasPrefix(string(b), "build") {
+				t.Errorf("Hook should receive the action ID; got: %q", b)
+			}
+			if tc.expected == "failure" && !strings.Contains(string(b), "exit status 1") {
+				t.Errorf("Hook should receive the error; got: %q", b)
+			}
+			if _, err := os.Stat(filepath.Join(dir, tc.missing)); err == nil {
+				t.Errorf("The %s hook should not run", tc.missing)
+			}
+		})
+	}
+}
+
+func TestParseActionsWorkingDir(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	sub := filepath.Join(dir, "cmd", "server")
+	if err := os.MkdirAll(sub, 0700); err != nil {
+		t.Fatalf("Cannot create dir: %v", err)
+	}
+
+	config := Config{
+		Dir: dir,
+		Actions: []Action{
+			{
+				WorkingDir:    filepath.Join("cmd", "server"),
+				BuildCommands: []string{"touch built"},
+				RunCommand:    "touch running",
+			},
+		},
+	}
+	if err := config.validate(); err != nil {
+		t.Fatalf("validate() err should be nil; got: %v", err)
+	}
+
+	actions := mustParseActions(t, config)
+	stop, err := Run(actions[0].BuildFuncs, actions[0].RunFunc)
+	if err != nil {
+		t.Fatalf("Run() err should be nil; got: %v", err)
+	}
+	defer stop()
+
+	if _, err := os.Stat(filepath.Join(sub, "built")); err != nil {
+		t.Errorf("Build command should run in the working dir: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err = os.Stat(filepath.Join(sub, "running")); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Errorf("Run command should run in the working dir: %v", err)
+	}
+
+	config.Actions[0].WorkingDir = "not_exists"
+	if err := config.validate(); err == nil {
+		t.Errorf("validate() err should not be nil for a missing working dir")
+	}
+}
+
+func TestShellCommand(t *testing.T) {
+	type testCase struct {
+		shell, command string
+		cmd            string
+		args           []string
+		err            bool
+	}
+	for name, tc := range map[string]testCase{
+		"no shell": {
+			command: "go build ./...",
+			cmd:     "go",
+			args:    []string{"build", "./..."},
+		},
+		"no shell quotes": {
+			command: `go build -ldflags "-X main.version=1.0" ./...`,
+			cmd:     "go",
+			args:    []string{"build", "-ldflags", "-X main.version=1.0", "./..."},
+		},
+		"no shell unclosed quote": {
+			command: `go build -ldflags "-X main.version=1.0`,
+			err:     true,
+		},
+		"shell": {
+			shell:   "/bin/sh -c",
+			command: "go build ./... && cp bin/app /tmp/",
+			cmd:     "/bin/sh",
+			args:    []string{"-c", "go build ./... && cp bin/app /tmp/"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cmd, args, err := shellCommand(tc.shell, tc.command)
+			if (err != nil) != tc.err {
+				t.Fatalf("shellCommand() err should be %v; got: %v", tc.err, err)
+			}
+			if cmd != tc.cmd || strings.Join(args, "|") != strings.Join(tc.args, "|") {
+				t.Errorf("shellCommand() should return %q %q; got: %q %q", tc.cmd, tc.args, cmd, args)
+			}
+		})
+	}
+}
+
+func TestParseActionsShell(t *testing.T) {
+	type testCase struct {
+		config Config
+		output string
+	}
+	for name, tc := range map[string]testCase{
+		"global shell": {
+			config: Config{
+				Shell:   "/bin/sh -c",
+				Actions: []Action{{BuildCommands: []string{"echo 'a  b' | tr a-z A-Z"}}},
+			},
+			output: "A  B\n",
+		},
+		"action shell": {
+			config: Config{
+				Actions: []Action{{Shell: "/bin/sh -c", BuildCommands: []string{"echo a; echo b"}}},
+			},
+			output: "a\nb\n",
+		},
+		"action overrides global shell": {
+			config: Config{
+				Shell:   "/bin/false",
+				Actions: []Action{{Shell: "/bin/sh -c", BuildCommands: []string{"echo a"}}},
+			},
+			output: "a\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actions := mustParseActions(t, tc.config)
+
+			var output bytes.Buffer
+			actions[0].Output.Set(&output)
+			if _, err := Run(actions[0].BuildFuncs, actions[0].RunFunc); err != nil {
+				t.Fatalf("Run() err should be nil; got: %v", err)
+			}
+			if output.String() != tc.output {
+				t.Errorf("Output should be %q; got: %q", tc.output, output.String())
+			}
+		})
+	}
+}
+
+func TestTriggered(t *testing.T) {
+	actions := mustParseActions(t, Config{Actions: []Action{
+		{Name: "go", Patterns: []string{"**/*.go"}},
+		{Name: "css", Patterns: []string{"**/*.css"}},
+		{Name: "all", Patterns: []string{"**/*"}},
+	}})
+
+	type testCase struct {
+		changes  []string
+		expected []string
+	}
+	for name, tc := range map[string]testCase{
+		"no changes": {
+			changes:  []string{},
+			expected: []string{},
+		},
+		"go": {
+			changes:  []string{"main.go"},
+			expected: []string{"go", "all"},
+		},
+		"go and css": {
+			changes:  []string{"main.go", "style.css"},
+			expected: []string{"go", "css", "all"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ids := []string{}
+			for _, action := range triggered(actions, tc.changes) {
+				ids = append(ids, action.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("Triggered actions should be: %v; got: %v", tc.expected, ids)
 			}
 		})
 	}
@@ -0,0 +1,99 @@
+package revolver
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+)
+
+// OutputRoute sends the lines of the build output matching Pattern to File.
+type OutputRoute struct {
+	// Pattern is a regular expression matched against every line.
+	Pattern string `yaml:"pattern"`
+	// File is the path of the file the matching lines are appended to. A
+	// relative path is resolved against the working directory of the action.
+	File string `yaml:"file"`
+}
+
+// outputRoute is a parsed OutputRoute.
+type outputRoute struct {
+	pattern *regexp.Regexp
+	file    string
+}
+
+// outputRouter is an io.WriteCloser that appends the lines matching the
+// routes to their files and writes the other lines to w. In tee mode the
+// matching lines are written to w too.
+type outputRouter struct {
+	w      io.Writer
+	routes []outputRoute
+	tee    bool
+
+	buf   []byte
+	files map[string]*os.File
+}
+
+func newOutputRouter(w io.Writer, routes []outputRoute, tee bool) *outputRouter {
+	return &outputRouter{w: w, routes: routes, tee: tee, files: make(map[string]*os.File)}
+}
+
+func (r *outputRouter) Write(p []byte) (int, error) {
+	r.buf = append(r.buf, p...)
+	for {
+		i := bytes.IndexByte(r.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := r.route(r.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		r.buf = r.buf[i+1:]
+	}
+}
+
+// route writes the line to the files of the matching routes, and to w unless
+// it matched in exclusive mode.
+func (r *outputRouter) route(line []byte) error {
+	matched := false
+	for _, route := range r.routes {
+		if !route.pattern.Match(bytes.TrimRight(line, "\r\n")) {
+			continue
+		}
+		matched = true
+		f, ok := r.files[route.file]
+		if !ok {
+			var err error
+			f, err = os.OpenFile(route.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			r.files[route.file] = f
+		}
+		if _, err := f.Write(line); err != nil {
+			return err
+		}
+	}
+	if matched && !r.tee {
+		return nil
+	}
+	_, err := r.w.Write(line)
+	return err
+}
+
+// Close routes the last line if it isn't terminated by a newline and closes
+// the files.
+func (r *outputRouter) Close() error {
+	var err error
+	if len(r.buf) > 0 {
+		err = r.route(r.buf)
+		r.buf = nil
+	}
+	for _, f := range r.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	r.files = make(map[string]*os.File)
+	return err
+}
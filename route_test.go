@@ -0,0 +1,88 @@
+package revolver
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestOutputRouter(t *testing.T) {
+	type testCase struct {
+		tee      bool
+		stdout   string
+		warnings string
+		errors   string
+	}
+	for name, tc := range map[string]testCase{
+		"tee": {
+			tee:      true,
+			stdout:   "compiling\nwarning: unused\nerror: undefined\ndone",
+			warnings: "warning: unused\n",
+			errors:   "error: undefined\n",
+		},
+		"exclusive": {
+			stdout:   "compiling\ndone",
+			warnings: "warning: unused\n",
+			errors:   "error: undefined\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			warnings := filepath.Join(dir, "warnings.log")
+			errors := filepath.Join(dir, "errors.log")
+
+			var stdout bytes.Buffer
+			router := newOutputRouter(&stdout, []outputRoute{
+				{pattern: regexp.MustCompile("^warning:"), file: warnings},
+				{pattern: regexp.MustCompile("^error:"), file: errors},
+			}, tc.tee)
+			for _, chunk := range []string{"compiling\nwarn", "ing: unused\nerror: undefined\n", "done"} {
+				if _, err := router.Write([]byte(chunk)); err != nil {
+					t.Fatalf("Write() err should be nil; got: %v", err)
+				}
+			}
+			if err := router.Close(); err != nil {
+				t.Fatalf("Close() err should be nil; got: %v", err)
+			}
+
+			if stdout.String() != tc.stdout {
+				t.Errorf("Stdout should be %q; got: %q", tc.stdout, stdout.String())
+			}
+			if b, _ := ioutil.ReadFile(warnings); string(b) != tc.warnings {
+				t.Errorf("Warnings should be %q; got: %q", tc.warnings, b)
+			}
+			if b, _ := ioutil.ReadFile(errors); string(b) != tc.errors {
+				t.Errorf("Errors should be %q; got: %q", tc.errors, b)
+			}
+		})
+	}
+}
+
+func TestParseActionsOutputRouter(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	config := Config{
+		Actions: []Action{
+			{
+				WorkingDir:    dir,
+				BuildCommands: []string{`printf 'ok\nwarning: unused\n'`, `printf 'warning: shadowed\n'`},
+				Shell:         "sh -c",
+				OutputRouter:  []OutputRoute{{Pattern: "^warning:", File: "warnings.log"}},
+				RouteMode:     "exclusive",
+			},
+		},
+	}
+	actions := mustParseActions(t, config)
+	if _, err := Run(actions[0].BuildFuncs, nil); err != nil {
+		t.Fatalf("Run() err should be nil; got: %v", err)
+	}
+
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "warnings.log"))
+	if expected := "warning: unused\nwarning: shadowed\n"; string(b) != expected {
+		t.Errorf("Routed lines of every build command should be %q; got: %q", expected, b)
+	}
+}
@@ -0,0 +1,82 @@
+package revolver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// selector selects actions by their labels. It is a minimal implementation
+// of the Kubernetes label selector syntax supporting the equality based
+// requirements, e.g. "env=dev,tier!=test".
+type selector []requirement
+
+// requirement is a single condition of a selector.
+type requirement struct {
+	key   string
+	op    string
+	value string
+}
+
+// parseSelector parses the comma separated requirements of a selector. The
+// supported requirements are "key=value", "key==value", "key!=value", "key"
+// (the label exists) and "!key" (the label doesn't exist). An empty selector
+// selects every action.
+func parseSelector(s string) (selector, error) {
+	sel := selector{}
+	if strings.TrimSpace(s) == "" {
+		return sel, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+
+		var req requirement
+		switch {
+		case strings.Contains(part, "!="):
+			parts := strings.SplitN(part, "!=", 2)
+			req = requirement{key: parts[0], op: "!=", value: parts[1]}
+		case strings.Contains(part, "=="):
+			parts := strings.SplitN(part, "==", 2)
+			req = requirement{key: parts[0], op: "=", value: parts[1]}
+		case strings.Contains(part, "="):
+			parts := strings.SplitN(part, "=", 2)
+			req = requirement{key: parts[0], op: "=", value: parts[1]}
+		case strings.HasPrefix(part, "!"):
+			req = requirement{key: part[1:], op: "!"}
+		default:
+			req = requirement{key: part, op: "exists"}
+		}
+
+		req.key = strings.TrimSpace(req.key)
+		req.value = strings.TrimSpace(req.value)
+		if req.key == "" {
+			return nil, fmt.Errorf("invalid selector requirement: %q", part)
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// matches reports whether the labels satisfy all the requirements.
+func (sel selector) matches(labels map[string]string) bool {
+	for _, req := range sel {
+		if !req.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (req requirement) matches(labels map[string]string) bool {
+	value, ok := labels[req.key]
+	switch req.op {
+	case "=":
+		return ok && value == req.value
+	case "!=":
+		return !ok || value != req.value
+	case "!":
+		return !ok
+	default:
+		return ok
+	}
+}
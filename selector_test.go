@@ -0,0 +1,109 @@
+package revolver
+
+import "testing"
+
+func TestSelector(t *testing.T) {
+	type testCase struct {
+		selector string
+		labels   map[string]string
+		expected bool
+	}
+
+	testCases := map[string]testCase{
+		"empty": {
+			selector: "",
+			labels:   map[string]string{"env": "dev"},
+			expected: true,
+		},
+		"equality": {
+			selector: "env=dev",
+			labels:   map[string]string{"env": "dev"},
+			expected: true,
+		},
+		"double equality": {
+			selector: "env==dev",
+			labels:   map[string]string{"env": "dev"},
+			expected: true,
+		},
+		"equality mismatch": {
+			selector: "env=dev",
+			labels:   map[string]string{"env": "prod"},
+			expected: false,
+		},
+		"equality missing label": {
+			selector: "env=dev",
+			labels:   nil,
+			expected: false,
+		},
+		"inequality": {
+			selector: "tier!=test",
+			labels:   map[string]string{"tier": "backend"},
+			expected: true,
+		},
+		"inequality mismatch": {
+			selector: "tier!=test",
+			labels:   map[string]string{"tier": "test"},
+			expected: false,
+		},
+		"inequality missing label": {
+			selector: "tier!=test",
+			labels:   nil,
+			expected: true,
+		},
+		"exists": {
+			selector: "env",
+			labels:   map[string]string{"env": "dev"},
+			expected: true,
+		},
+		"not exists": {
+			selector: "!env",
+			labels:   map[string]string{"env": "dev"},
+			expected: false,
+		},
+		"multiple requirements": {
+			selector: "env=dev, tier!=test",
+			labels:   map[string]string{"env": "dev", "tier": "backend"},
+			expected: true,
+		},
+		"multiple requirements mismatch": {
+			selector: "env=dev,tier!=test",
+			labels:   map[string]string{"env": "dev", "tier": "test"},
+			expected: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sel, err := parseSelector(tc.selector)
+			if err != nil {
+				t.Fatalf("parseSelector() err should be nil; got: %v", err)
+			}
+			if got := sel.matches(tc.labels); got != tc.expected {
+				t.Errorf("selector %q should match %v: %v; got: %v", tc.selector, tc.labels, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseSelectorError(t *testing.T) {
+	for _, s := range []string{"=dev", "env=dev,", "!"} {
+		if _, err := parseSelector(s); err == nil {
+			t.Errorf("parseSelector(%q) err should not be nil", s)
+		}
+	}
+}
+
+func TestParseActionsSelector(t *testing.T) {
+	actions := mustParseActions(t, Config{
+		Selector: "env=dev,tier!=test",
+		Actions: []Action{
+			{Name: "server", BuildCommands: []string{"go build"}, Labels: map[string]string{"env": "dev", "tier": "backend"}},
+			{Name: "test", BuildCommands: []string{"go test"}, Labels: map[string]string{"env": "dev", "tier": "test"}},
+			{Name: "deploy", BuildCommands: []string{"make deploy"}, Labels: map[string]string{"env": "prod"}},
+		},
+	})
+
+	if len(actions) != 1 || actions[0].ID != "server" {
+		t.Errorf("only the server action should be selected; got: %v", actions)
+	}
+}
@@ -0,0 +1,74 @@
+package revolver
+
+import (
+	"errors"
+	"strings"
+)
+
+// shellSplit splits s into words like a POSIX shell does: words are separated
+// by unquoted whitespace, single quotes preserve everything between them,
+// double quotes preserve everything but backslash escaped ", \, $ and `, and
+// an unquoted backslash escapes the next character. Unclosed quotes and a
+// trailing backslash are errors.
+func shellSplit(s string) ([]string, error) {
+	words := []string{}
+
+	var word strings.Builder
+	inWord := false
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '\\':
+			i++
+			if i == len(runes) {
+				return nil, errors.New("unfinished escape at the end of command")
+			}
+			word.WriteRune(runes[i])
+			inWord = true
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, errors.New("unclosed single quote in command")
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+			inWord = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				}
+				word.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, errors.New("unclosed double quote in command")
+			}
+			inWord = true
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// indexRune returns the index of the first r in runes from start, or -1.
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
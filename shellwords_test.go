@@ -0,0 +1,45 @@
+package revolver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShellSplit(t *testing.T) {
+	type testCase struct {
+		input    string
+		expected []string
+		err      bool
+	}
+
+	testCases := map[string]testCase{
+		"empty":              {input: "", expected: []string{}},
+		"spaces":             {input: "  go   build  ", expected: []string{"go", "build"}},
+		"tabs":               {input: "go\tbuild", expected: []string{"go", "build"}},
+		"double quotes":      {input: `go build -ldflags "-X main.version=1.0"`, expected: []string{"go", "build", "-ldflags", "-X main.version=1.0"}},
+		"single quotes":      {input: `echo 'a "b" c'`, expected: []string{"echo", `a "b" c`}},
+		"adjacent quotes":    {input: `echo a"b c"'d'`, expected: []string{"echo", "ab cd"}},
+		"empty quotes":       {input: `echo "" ''`, expected: []string{"echo", "", ""}},
+		"escaped space":      {input: `cat my\ file`, expected: []string{"cat", "my file"}},
+		"escape in double":   {input: `echo "a \"b\" \\ \n"`, expected: []string{"echo", `a "b" \ \n`}},
+		"escape in single":   {input: `echo 'a\b'`, expected: []string{"echo", `a\b`}},
+		"unclosed double":    {input: `echo "a`, err: true},
+		"unclosed single":    {input: `echo 'a`, err: true},
+		"trailing backslash": {input: `echo a\`, err: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			words, err := shellSplit(tc.input)
+			if (err != nil) != tc.err {
+				t.Fatalf("shellSplit(%q) err should be %v; got: %v", tc.input, tc.err, err)
+			}
+			if tc.err {
+				return
+			}
+			if strings.Join(words, "|") != strings.Join(tc.expected, "|") || len(words) != len(tc.expected) {
+				t.Errorf("shellSplit(%q) should return %q; got: %q", tc.input, tc.expected, words)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package revolver
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+var signals = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// defaultStopSignal is the signal stopping the run commands of the actions
+// without a stop signal.
+var defaultStopSignal os.Signal = syscall.SIGTERM
+
+// parseSignal parses a signal name like "SIGTERM". An empty name returns a nil
+// signal.
+func parseSignal(name string) (os.Signal, error) {
+	if name == "" {
+		return nil, nil
+	}
+	sig, ok := signals[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown stop signal: %s", name)
+	}
+	return sig, nil
+}
+
+// setProcessGroup makes the command start in a new process group, so it can
+// be stopped together with its child processes.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcess sends sig to the process group of the process.
+func signalProcess(p *os.Process, sig os.Signal, logger Logger) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, s)
+}
+
+// killProcess kills the process group of the process.
+func killProcess(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,323 @@
+//go:build !windows
+// +build !windows
+
+package revolver
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// signalRecorder is a shell script that writes the name of the received signal
+// to the file in $1 and exits. It writes "ready" to the file when the traps are
+// set up.
+const signalRecorder = `for sig in HUP INT QUIT TERM USR1 USR2; do trap "echo $sig > $1; exit 0" $sig; done
+echo ready > $1
+while true; do sleep 0.01; done`
+
+func waitForFile(t *testing.T, name, content string) {
+	for i := 0; i < 200; i++ {
+		b, _ := ioutil.ReadFile(name)
+		if strings.TrimSpace(string(b)) == content {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("File %s should contain %q", name, content)
+}
+
+func TestRunCommandStopSignal(t *testing.T) {
+	for _, name := range []string{"SIGHUP", "SIGINT", "SIGQUIT", "SIGTERM", "SIGUSR1", "SIGUSR2"} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			file := filepath.Join(dir, "signal")
+
+			sig, err := parseSignal(name)
+			if err != nil {
+				t.Fatalf("parseSignal() err should be nil; got: %v", err)
+			}
+
+			stop, err := runCommand(commandOptions{StopSignal: sig}, "sh", "-c", signalRecorder, "sh", file)()
+			if err != nil {
+				t.Fatalf("Run func err should be nil; got: %v", err)
+			}
+			waitForFile(t, file, "ready")
+
+			stop()
+
+			waitForFile(t, file, strings.TrimPrefix(name, "SIG"))
+		})
+	}
+}
+
+func TestRunCommandKill(t *testing.T) {
+	stop, err := runCommand(commandOptions{}, "sleep", "5")()
+	if err != nil {
+		t.Fatalf("Run func err should be nil; got: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Errorf("Stop should kill the process")
+	}
+}
+
+func TestRunCommandGraceful(t *testing.T) {
+	type testCase struct {
+		script string
+		min    time.Duration
+		max    time.Duration
+	}
+
+	testCases := map[string]testCase{
+		"exits on SIGTERM": {
+			script: `trap "exit 0" TERM; echo ready > $1; while true; do sleep 0.01; done`,
+			max:    150 * time.Millisecond,
+		},
+		"ignores SIGTERM": {
+			script: `trap "" TERM; echo ready > $1; while true; do sleep 0.01; done`,
+			min:    200 * time.Millisecond,
+			max:    time.Second,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			file := filepath.Join(dir, "ready")
+
+			stop, err := RunCommandGraceful("sh", 200*time.Millisecond, "-c", tc.script, "sh", file)()
+			if err != nil {
+				t.Fatalf("Run func err should be nil; got: %v", err)
+			}
+			waitForFile(t, file, "ready")
+
+			start := time.Now()
+			stop()
+			elapsed := time.Since(start)
+
+			if elapsed < tc.min {
+				t.Errorf("Stop should take at least %v; took: %v", tc.min, elapsed)
+			}
+			if elapsed > tc.max {
+				t.Errorf("Stop should take at most %v; took: %v", tc.max, elapsed)
+			}
+		})
+	}
+}
+
+// processAlive reports whether the process with pid is running. Zombie
+// processes are not running.
+func processAlive(pid int) bool {
+	if err := syscall.Kill(pid, 0); err != nil {
+		return false
+	}
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		// Without procfs a signalable process is considered running.
+		return true
+	}
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) == 0 || fields[0] != "Z"
+}
+
+func TestRunCommandProcessGroup(t *testing.T) {
+	for name, opts := range map[string]commandOptions{
+		"kill":        {},
+		"stop signal": {StopSignal: syscall.SIGTERM},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			file := filepath.Join(dir, "pid")
+
+			stop, err := runCommand(opts, "sh", "-c", `sleep 30 & echo $! > $1; wait`, "sh", file)()
+			if err != nil {
+				t.Fatalf("Run func err should be nil; got: %v", err)
+			}
+
+			var pid int
+			for i := 0; i < 200 && pid == 0; i++ {
+				b, _ := ioutil.ReadFile(file)
+				pid, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+				time.Sleep(10 * time.Millisecond)
+			}
+			if pid == 0 {
+				t.Fatalf("Child process should write its pid")
+			}
+
+			stop()
+
+			for i := 0; i < 100 && processAlive(pid); i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if processAlive(pid) {
+				syscall.Kill(pid, syscall.SIGKILL)
+				t.Errorf("Child process %d should be stopped", pid)
+			}
+		})
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for name, tc := range map[string]struct {
+		sig os.Signal
+		err bool
+	}{
+		"":        {sig: nil},
+		"SIGTERM": {sig: signals["SIGTERM"]},
+		"sigint":  {sig: signals["SIGINT"]},
+		"SIGFOO":  {err: true},
+	} {
+		sig, err := parseSignal(name)
+		if (err != nil) != tc.err {
+			t.Errorf("parseSignal(%q) err should be %v; got: %v", name, tc.err, err)
+		}
+		if sig != tc.sig {
+			t.Errorf("parseSignal(%q) should return %v; got: %v", name, tc.sig, sig)
+		}
+	}
+}
+
+func TestWatchSignals(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	file := filepath.Join(dir, "pid")
+
+	config := Config{
+		Dir:        dir,
+		Interval:   10 * time.Millisecond,
+		RunOnStart: true,
+		Shell:      "sh -c",
+		Actions: []Action{
+			{RunCommand: "echo $$ > " + file + "; exec sleep 30"},
+		},
+	}
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchSignals(config, sigs)
+	}()
+
+	var pid int
+	for i := 0; i < 200 && pid == 0; i++ {
+		b, _ := ioutil.ReadFile(file)
+		pid, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pid == 0 {
+		t.Fatalf("Run command should write its pid")
+	}
+
+	sigs <- syscall.SIGTERM
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("WatchSignals() err should be nil; got: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("WatchSignals() should return after a signal")
+	}
+	if processAlive(pid) {
+		syscall.Kill(pid, syscall.SIGKILL)
+		t.Errorf("Run command %d should be stopped", pid)
+	}
+}
+
+func TestActionGracePeriod(t *testing.T) {
+	type testCase struct {
+		config Config
+		action Action
+		min    time.Duration
+		max    time.Duration
+	}
+
+	testCases := map[string]testCase{
+		"grace period": {
+			config: Config{StopTimeout: 50 * time.Millisecond},
+			action: Action{GracePeriod: 300 * time.Millisecond},
+			min:    300 * time.Millisecond,
+			max:    time.Second,
+		},
+		"config stop timeout": {
+			config: Config{StopTimeout: 50 * time.Millisecond},
+			action: Action{},
+			min:    50 * time.Millisecond,
+			max:    250 * time.Millisecond,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			file := filepath.Join(dir, "ready")
+
+			tc.config.Shell = "sh -c"
+			tc.action.RunCommand = `trap "" TERM; echo ready > ` + file + `; while true; do sleep 0.01; done`
+			a, err := newAction(tc.config, "server", tc.action, nil)
+			if err != nil {
+				t.Fatalf("newAction() err should be nil; got: %v", err)
+			}
+
+			stop, err := a.RunFunc()
+			if err != nil {
+				t.Fatalf("Run func err should be nil; got: %v", err)
+			}
+			waitForFile(t, file, "ready")
+
+			start := time.Now()
+			stop()
+			elapsed := time.Since(start)
+
+			if elapsed < tc.min {
+				t.Errorf("Stop should take at least %v; took: %v", tc.min, elapsed)
+			}
+			if elapsed > tc.max {
+				t.Errorf("Stop should take at most %v; took: %v", tc.max, elapsed)
+			}
+		})
+	}
+}
+
+func TestActionDefaultStopSignal(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+	file := filepath.Join(dir, "signal")
+
+	a, err := newAction(Config{Shell: "sh -c"}, "server", Action{
+		RunCommand: strings.Replace(signalRecorder, "$1", file, -1),
+	}, nil)
+	if err != nil {
+		t.Fatalf("newAction() err should be nil; got: %v", err)
+	}
+
+	stop, err := a.RunFunc()
+	if err != nil {
+		t.Fatalf("Run func err should be nil; got: %v", err)
+	}
+	waitForFile(t, file, "ready")
+
+	stop()
+
+	waitForFile(t, file, "TERM")
+}
@@ -0,0 +1,59 @@
+package revolver
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// namedSignal is a unix signal that can't be sent on Windows.
+type namedSignal string
+
+func (s namedSignal) Signal()        {}
+func (s namedSignal) String() string { return string(s) }
+
+var signals = map[string]os.Signal{
+	"SIGHUP":  namedSignal("SIGHUP"),
+	"SIGINT":  namedSignal("SIGINT"),
+	"SIGQUIT": namedSignal("SIGQUIT"),
+	"SIGKILL": os.Kill,
+	"SIGTERM": namedSignal("SIGTERM"),
+	"SIGUSR1": namedSignal("SIGUSR1"),
+	"SIGUSR2": namedSignal("SIGUSR2"),
+}
+
+// defaultStopSignal is the signal stopping the run commands of the actions
+// without a stop signal. Windows can only kill processes.
+var defaultStopSignal = os.Kill
+
+// parseSignal parses a signal name like "SIGTERM". An empty name returns a nil
+// signal.
+func parseSignal(name string) (os.Signal, error) {
+	if name == "" {
+		return nil, nil
+	}
+	sig, ok := signals[strings.ToUpper(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown stop signal: %s", name)
+	}
+	return sig, nil
+}
+
+// setProcessGroup does nothing on Windows, where processes are killed one by
+// one.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcess kills the process. Windows can't send other signals to a
+// process, so they are ignored with a warning.
+func signalProcess(p *os.Process, sig os.Signal, logger Logger) error {
+	if sig != os.Kill {
+		logger.Info("Stop signal %v is not supported on Windows, killing the process instead.", sig)
+	}
+	return killProcess(p)
+}
+
+// killProcess kills the process.
+func killProcess(p *os.Process) error {
+	return p.Kill()
+}
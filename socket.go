@@ -0,0 +1,216 @@
+package revolver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+)
+
+// The commands of the control socket.
+const (
+	ControlPause   = "pause"
+	ControlResume  = "resume"
+	ControlTrigger = "trigger"
+	ControlStatus  = "status"
+)
+
+// ControlCommand is a command sent to the control socket, one JSON object per
+// line, e.g. {"cmd":"trigger","action":"build"}.
+type ControlCommand struct {
+	Cmd string `json:"cmd"`
+	// Action is the ID of the action to build for the trigger command.
+	Action string `json:"action,omitempty"`
+}
+
+// ControlResponse is the response of the control socket to a command, one
+// JSON object per line.
+type ControlResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	// Status is the status of the actions for the status command.
+	Status *StatusResponse `json:"status,omitempty"`
+}
+
+// controlSocket listens for the commands controlling the watch loop on a Unix
+// domain socket. A nil controlSocket listens for nothing.
+type controlSocket struct {
+	listener net.Listener
+	logger   Logger
+	states   *actionStates
+	// control receives the pause and resume commands.
+	control chan WatchControl
+	// done is closed when the socket is closed.
+	done chan struct{}
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// startControlSocket listens on the Unix domain socket at path in the
+// background. A socket file left behind by a revolver that didn't shut down
+// cleanly is replaced.
+func startControlSocket(path string, states *actionStates, logger Logger) (*controlSocket, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil && isStaleSocket(path) {
+		if removeErr := os.Remove(path); removeErr == nil {
+			listener, err = net.Listen("unix", path)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error starting control socket: %w", err)
+	}
+
+	s := &controlSocket{
+		listener: listener,
+		logger:   logger,
+		states:   states,
+		control:  make(chan WatchControl, 1),
+		done:     make(chan struct{}),
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	logger.Info("Listening for commands on %s", path)
+	return s, nil
+}
+
+// isStaleSocket reports whether path is a socket file nobody listens on.
+func isStaleSocket(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return false
+	}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return true
+	}
+	conn.Close()
+	return false
+}
+
+// controlled returns the channel receiving the pause and resume commands. It
+// is nil for a nil controlSocket.
+func (s *controlSocket) controlled() <-chan WatchControl {
+	if s == nil {
+		return nil
+	}
+	return s.control
+}
+
+// close stops listening, closes the connections and removes the socket file.
+func (s *controlSocket) close() {
+	if s == nil {
+		return
+	}
+	close(s.done)
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *controlSocket) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+			default:
+				s.logger.Error(fmt.Errorf("Error accepting control connection: %w", err))
+			}
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// handleConn responds to the commands of the connection until it is closed.
+func (s *controlSocket) handleConn(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var command ControlCommand
+		var response ControlResponse
+		if err := json.Unmarshal(scanner.Bytes(), &command); err != nil {
+			response = ControlResponse{Error: fmt.Sprintf("invalid command: %v", err)}
+		} else {
+			response = s.handle(command)
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+// handle executes the command and returns its response.
+func (s *controlSocket) handle(command ControlCommand) ControlResponse {
+	switch command.Cmd {
+	case ControlPause:
+		return s.send(Pause)
+	case ControlResume:
+		return s.send(Resume)
+	case ControlTrigger:
+		if command.Action == "" {
+			return ControlResponse{Error: "missing action"}
+		}
+		if !s.states.triggerBuild(command.Action) {
+			return ControlResponse{Error: fmt.Sprintf("unknown action: %s", command.Action)}
+		}
+		return ControlResponse{OK: true}
+	case ControlStatus:
+		status := s.states.status()
+		return ControlResponse{OK: true, Status: &status}
+	default:
+		return ControlResponse{Error: fmt.Sprintf("unknown command: %s", command.Cmd)}
+	}
+}
+
+// send sends c to the watch loop. It blocks while the watch loop is busy
+// executing the actions.
+func (s *controlSocket) send(c WatchControl) ControlResponse {
+	select {
+	case s.control <- c:
+		return ControlResponse{OK: true}
+	case <-s.done:
+		return ControlResponse{Error: "revolver is stopping"}
+	}
+}
+
+// SendControl sends the command to the control socket at path and returns
+// its response.
+func SendControl(path string, command ControlCommand) (ControlResponse, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return ControlResponse{}, fmt.Errorf("Error connecting to control socket: %w", err)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(command); err != nil {
+		return ControlResponse{}, fmt.Errorf("Error sending command: %w", err)
+	}
+	var response ControlResponse
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		return ControlResponse{}, fmt.Errorf("Error reading response: %w", err)
+	}
+	return response, nil
+}
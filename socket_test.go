@@ -0,0 +1,174 @@
+package revolver
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestControlSocket(t *testing.T) {
+	type testCase struct {
+		command ControlCommand
+		ok      bool
+		control []WatchControl
+		pending []string
+	}
+	for name, tc := range map[string]testCase{
+		"pause": {
+			command: ControlCommand{Cmd: ControlPause},
+			ok:      true,
+			control: []WatchControl{Pause},
+		},
+		"resume": {
+			command: ControlCommand{Cmd: ControlResume},
+			ok:      true,
+			control: []WatchControl{Resume},
+		},
+		"trigger": {
+			command: ControlCommand{Cmd: ControlTrigger, Action: "build"},
+			ok:      true,
+			pending: []string{"build"},
+		},
+		"trigger: unknown action": {
+			command: ControlCommand{Cmd: ControlTrigger, Action: "deploy"},
+		},
+		"trigger: missing action": {
+			command: ControlCommand{Cmd: ControlTrigger},
+		},
+		"status": {
+			command: ControlCommand{Cmd: ControlStatus},
+			ok:      true,
+		},
+		"unknown command": {
+			command: ControlCommand{Cmd: "restart"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+
+			actions := []action{{ID: "build"}, {ID: "test"}}
+			states := newActionStates(actions)
+			path := filepath.Join(dir, "revolver.sock")
+			socket, err := startControlSocket(path, states, NewLogger("text", ioutil.Discard))
+			if err != nil {
+				t.Fatalf("startControlSocket() err should be nil; got: %v", err)
+			}
+			defer socket.close()
+
+			response, err := SendControl(path, tc.command)
+			if err != nil {
+				t.Fatalf("SendControl() err should be nil; got: %v", err)
+			}
+			if response.OK != tc.ok || (response.Error == "") != tc.ok {
+				t.Errorf("Response should be ok: %v; got: %+v", tc.ok, response)
+			}
+
+			control := []WatchControl{}
+			select {
+			case c := <-socket.controlled():
+				control = append(control, c)
+			default:
+			}
+			if len(control) != len(tc.control) || (len(control) > 0 && control[0] != tc.control[0]) {
+				t.Errorf("Control should be %v; got: %v", tc.control, control)
+			}
+
+			ids := []string{}
+			for _, a := range states.takePending(actions) {
+				ids = append(ids, a.ID)
+			}
+			if !equals(ids, tc.pending) {
+				t.Errorf("Pending actions should be %v; got: %v", tc.pending, ids)
+			}
+
+			if tc.command.Cmd == ControlStatus && (response.Status == nil || len(response.Status.Actions) != len(actions)) {
+				t.Errorf("Status should contain the actions; got: %+v", response.Status)
+			}
+		})
+	}
+}
+
+func TestWatcherControlSocket(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	path := filepath.Join(dir, "revolver.sock")
+	watcher := newTestWatcher(t, Config{
+		Dir:        dir,
+		Interval:   10 * time.Millisecond,
+		SocketPath: path,
+		Logger:     NewLogger("text", ioutil.Discard),
+		Actions: []Action{
+			{Name: "build", Patterns: []string{"**/*.go"}, BuildCommands: []string{"true"}},
+		},
+	})
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	send := func(command ControlCommand) ControlResponse {
+		t.Helper()
+		response, err := SendControl(path, command)
+		if err != nil {
+			t.Fatalf("SendControl() err should be nil; got: %v", err)
+		}
+		if !response.OK {
+			t.Fatalf("Response should be ok; got: %+v", response)
+		}
+		return response
+	}
+
+	// The socket is opened in the background by Start.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := SendControl(path, ControlCommand{Cmd: ControlStatus}); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Control socket should be opened")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	send(ControlCommand{Cmd: ControlTrigger, Action: "build"})
+	// The cycle of the initial files doesn't run any actions.
+	for built := false; !built; {
+		select {
+		case result := <-results:
+			built = len(result.Actions) > 0
+			if built && !equals([]string{"build"}, result.Actions) {
+				t.Errorf("Actions should be: %v; got: %v", []string{"build"}, result.Actions)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Triggered build should be run")
+		}
+	}
+
+	send(ControlCommand{Cmd: ControlPause})
+	deadline = time.Now().Add(5 * time.Second)
+	for !send(ControlCommand{Cmd: ControlStatus}).Status.Paused {
+		if time.Now().After(deadline) {
+			t.Fatalf("Status should report the watcher as paused")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	send(ControlCommand{Cmd: ControlResume})
+	deadline = time.Now().Add(5 * time.Second)
+	for send(ControlCommand{Cmd: ControlStatus}).Status.Paused {
+		if time.Now().After(deadline) {
+			t.Fatalf("Status should report the watcher as resumed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	watcher.Stop()
+	if _, err := SendControl(path, ControlCommand{Cmd: ControlStatus}); err == nil {
+		t.Errorf("Control socket should be closed when the watcher stops")
+	}
+}
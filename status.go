@@ -0,0 +1,284 @@
+package revolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The states of the actions reported by the status server.
+const (
+	StateIdle     = "idle"
+	StateBuilding = "building"
+	StateFailed   = "failed"
+	StateRunning  = "running"
+	StateStopped  = "stopped"
+)
+
+// ActionStatus is the status of an action reported by GET /status.
+type ActionStatus struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	// LastBuild is the end of the last build in RFC 3339 format. It is empty
+	// if the action hasn't been built yet.
+	LastBuild string `json:"lastBuild,omitempty"`
+}
+
+// StatusResponse is the response of GET /status.
+type StatusResponse struct {
+	Actions []ActionStatus `json:"actions"`
+	// Paused reports whether the watch loop is paused.
+	Paused bool `json:"paused,omitempty"`
+}
+
+// statusShutdownTimeout is the maximum duration to wait for the running
+// requests when the status server is shut down.
+const statusShutdownTimeout = time.Second
+
+// actionStates are the states of the actions, and the builds triggered
+// through the status server or the control socket.
+type actionStates struct {
+	// trigger receives a value when a build is triggered.
+	trigger chan struct{}
+
+	mu       sync.Mutex
+	ids      []string
+	statuses map[string]*ActionStatus
+	// pending are the IDs of the triggered actions not built yet.
+	pending []string
+	paused  bool
+}
+
+func newActionStates(actions []action) *actionStates {
+	s := &actionStates{
+		trigger:  make(chan struct{}, 1),
+		statuses: make(map[string]*ActionStatus),
+	}
+	s.setActions(actions)
+	return s
+}
+
+// setActions sets the actions tracked, keeping the status of the existing
+// ones, e.g. after the config is reloaded.
+func (s *actionStates) setActions(actions []action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make(map[string]*ActionStatus, len(actions))
+	s.ids = make([]string, 0, len(actions))
+	for _, a := range actions {
+		status, ok := s.statuses[a.ID]
+		if !ok {
+			status = &ActionStatus{ID: a.ID, State: StateIdle}
+		}
+		statuses[a.ID] = status
+		s.ids = append(s.ids, a.ID)
+	}
+	s.statuses = statuses
+}
+
+// update updates the status of the action of the event.
+func (s *actionStates) update(event Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status, ok := s.statuses[event.ActionID]
+	if !ok {
+		return
+	}
+	switch event.Kind {
+	case BuildStarted:
+		status.State = StateBuilding
+	case BuildSucceeded:
+		status.State = StateIdle
+		status.LastBuild = time.Now().Format(time.RFC3339)
+	case BuildFailed:
+		status.State = StateFailed
+		status.LastBuild = time.Now().Format(time.RFC3339)
+	case RunStarted, RunRestarted:
+		status.State = StateRunning
+	case RunStopped:
+		status.State = StateStopped
+	}
+}
+
+// setPaused sets whether the watch loop is paused.
+func (s *actionStates) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+// status returns the status of the actions.
+func (s *actionStates) status() StatusResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response := StatusResponse{Actions: make([]ActionStatus, 0, len(s.ids)), Paused: s.paused}
+	for _, id := range s.ids {
+		response.Actions = append(response.Actions, *s.statuses[id])
+	}
+	return response
+}
+
+// has reports whether the action with the given ID is tracked.
+func (s *actionStates) has(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.statuses[id]
+	return ok
+}
+
+// triggerBuild triggers the build of the action with the given ID and
+// reports whether the action is tracked.
+func (s *actionStates) triggerBuild(id string) bool {
+	s.mu.Lock()
+	_, ok := s.statuses[id]
+	if ok && !stringArr(s.pending).has(id) {
+		s.pending = append(s.pending, id)
+	}
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// triggered returns the channel receiving a value when a build is triggered.
+func (s *actionStates) triggered() <-chan struct{} {
+	return s.trigger
+}
+
+// takePending returns the actions triggered since the last call that are
+// still among the actions.
+func (s *actionStates) takePending(actions []action) []action {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+
+	result := []action{}
+	for _, a := range actions {
+		if stringArr(pending).has(a.ID) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// statusServer serves the status of the actions and triggers their builds
+// over HTTP. A nil statusServer serves nothing.
+type statusServer struct {
+	server *http.Server
+	logger Logger
+	states *actionStates
+	// history returns the build history of an action as JSON.
+	history func(actionID string) ([]byte, error)
+}
+
+// startStatusServer listens on addr and serves the states of the actions and
+// their build history returned by history in the background.
+func startStatusServer(addr string, states *actionStates, history func(actionID string) ([]byte, error), logger Logger) (*statusServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Error starting status server: %w", err)
+	}
+
+	s := &statusServer{
+		logger:  logger,
+		states:  states,
+		history: history,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/trigger", s.handleTrigger)
+	mux.HandleFunc("/history", s.handleHistory)
+	s.server = &http.Server{Handler: mux}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error(fmt.Errorf("Error serving status: %w", err))
+		}
+	}()
+	logger.Info("Serving status on %s", listener.Addr())
+	return s, nil
+}
+
+// close shuts down the server, waiting for the running requests.
+func (s *statusServer) close() {
+	if s == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), statusShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+	}
+}
+
+func (s *statusServer) handleStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.states.status()); err != nil {
+		s.logger.Error(fmt.Errorf("Error writing status: %w", err))
+	}
+}
+
+func (s *statusServer) handleTrigger(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := req.URL.Query().Get("action")
+	if id == "" {
+		http.Error(w, "missing action", http.StatusBadRequest)
+		return
+	}
+
+	if !s.states.triggerBuild(id) {
+		http.Error(w, fmt.Sprintf("unknown action: %s", id), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *statusServer) handleHistory(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := req.URL.Query().Get("action")
+	if id == "" {
+		http.Error(w, "missing action", http.StatusBadRequest)
+		return
+	}
+
+	if !s.states.has(id) {
+		http.Error(w, fmt.Sprintf("unknown action: %s", id), http.StatusNotFound)
+		return
+	}
+
+	history, err := s.history(id)
+	if err != nil {
+		s.logger.Error(fmt.Errorf("Error encoding history: %w", err))
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(history); err != nil {
+		s.logger.Error(fmt.Errorf("Error writing history: %w", err))
+	}
+}
@@ -0,0 +1,217 @@
+package revolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusServerStatus(t *testing.T) {
+	s := &statusServer{
+		logger: NewLogger("text", ioutil.Discard),
+		states: newActionStates([]action{{ID: "server"}, {ID: "lint"}, {ID: "test"}}),
+	}
+	s.states.update(Event{ActionID: "server", Kind: BuildStarted})
+	s.states.update(Event{ActionID: "server", Kind: BuildSucceeded})
+	s.states.update(Event{ActionID: "server", Kind: RunStarted})
+	s.states.update(Event{ActionID: "lint", Kind: BuildFailed, Err: errors.New("lint failed")})
+
+	rec := httptest.NewRecorder()
+	s.handleStatus(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status code should be %d; got: %d", http.StatusOK, rec.Code)
+	}
+	var response StatusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Cannot decode status: %v", err)
+	}
+
+	expected := []ActionStatus{
+		{ID: "server", State: StateRunning},
+		{ID: "lint", State: StateFailed},
+		{ID: "test", State: StateIdle},
+	}
+	if len(response.Actions) != len(expected) {
+		t.Fatalf("Status should contain %d actions; got: %v", len(expected), response.Actions)
+	}
+	for i, status := range response.Actions {
+		if status.ID != expected[i].ID || status.State != expected[i].State {
+			t.Errorf("Status %d should be %v; got: %v", i, expected[i], status)
+		}
+		if built := status.ID != "test"; built != (status.LastBuild != "") {
+			t.Errorf("LastBuild of %s should be set only after a build; got: %q", status.ID, status.LastBuild)
+		}
+	}
+}
+
+func TestStatusServerTrigger(t *testing.T) {
+	type testCase struct {
+		method   string
+		target   string
+		code     int
+		expected []string
+	}
+	for name, tc := range map[string]testCase{
+		"trigger": {
+			method:   http.MethodPost,
+			target:   "/trigger?action=build",
+			code:     http.StatusAccepted,
+			expected: []string{"build"},
+		},
+		"unknown action": {
+			method: http.MethodPost,
+			target: "/trigger?action=deploy",
+			code:   http.StatusNotFound,
+		},
+		"missing action": {
+			method: http.MethodPost,
+			target: "/trigger",
+			code:   http.StatusBadRequest,
+		},
+		"get": {
+			method: http.MethodGet,
+			target: "/trigger?action=build",
+			code:   http.StatusMethodNotAllowed,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actions := []action{{ID: "build"}, {ID: "test"}}
+			s := &statusServer{
+				logger: NewLogger("text", ioutil.Discard),
+				states: newActionStates(actions),
+			}
+
+			rec := httptest.NewRecorder()
+			s.handleTrigger(rec, httptest.NewRequest(tc.method, tc.target, nil))
+			if rec.Code != tc.code {
+				t.Errorf("Status code should be %d; got: %d", tc.code, rec.Code)
+			}
+
+			ids := []string{}
+			for _, a := range s.states.takePending(actions) {
+				ids = append(ids, a.ID)
+			}
+			if !equals(ids, tc.expected) {
+				t.Errorf("Pending actions should be %v; got: %v", tc.expected, ids)
+			}
+			if pending := s.states.takePending(actions); len(pending) != 0 {
+				t.Errorf("Pending actions should be taken once; got: %v", pending)
+			}
+		})
+	}
+}
+
+func TestStatusServerHistory(t *testing.T) {
+	type testCase struct {
+		method   string
+		target   string
+		code     int
+		expected string
+	}
+	for name, tc := range map[string]testCase{
+		"history": {
+			method:   http.MethodGet,
+			target:   "/history?action=build",
+			code:     http.StatusOK,
+			expected: `[{"action":"build"}]`,
+		},
+		"unknown action": {
+			method: http.MethodGet,
+			target: "/history?action=deploy",
+			code:   http.StatusNotFound,
+		},
+		"missing action": {
+			method: http.MethodGet,
+			target: "/history",
+			code:   http.StatusBadRequest,
+		},
+		"post": {
+			method: http.MethodPost,
+			target: "/history?action=build",
+			code:   http.StatusMethodNotAllowed,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := &statusServer{
+				logger: NewLogger("text", ioutil.Discard),
+				history: func(actionID string) ([]byte, error) {
+					return []byte(`[{"action":"` + actionID + `"}]`), nil
+				},
+				states: newActionStates([]action{{ID: "build"}}),
+			}
+
+			rec := httptest.NewRecorder()
+			s.handleHistory(rec, httptest.NewRequest(tc.method, tc.target, nil))
+			if rec.Code != tc.code {
+				t.Errorf("Status code should be %d; got: %d", tc.code, rec.Code)
+			}
+			if tc.expected != "" && rec.Body.String() != tc.expected {
+				t.Errorf("Body should be %s; got: %s", tc.expected, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestWatcherStatusAddr(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	config := Config{
+		Dir:        dir,
+		Interval:   time.Minute,
+		StatusAddr: addr,
+		Logger:     NewLogger("text", ioutil.Discard),
+		Actions: []Action{
+			{Name: "build", BuildCommands: []string{"true"}},
+		},
+	}
+	watcher := newTestWatcher(t, config)
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+
+	var resp *http.Response
+	for i := 0; i < 100; i++ {
+		resp, err = http.Post("http://"+addr+"/trigger?action=build", "", nil)
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		watcher.Stop()
+		t.Fatalf("Cannot trigger build: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("Status code should be %d; got: %d", http.StatusAccepted, resp.StatusCode)
+	}
+
+	select {
+	case result := <-results:
+		if !equals(result.Actions, []string{"build"}) {
+			t.Errorf("Triggered cycle should run build; got: %v", result.Actions)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Trigger should run a cycle without a file change")
+	}
+
+	watcher.Stop()
+	if _, err := http.Get("http://" + addr + "/status"); err == nil {
+		t.Errorf("Status server should be shut down when the watcher stops")
+	}
+}
@@ -0,0 +1,105 @@
+package revolver
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// sink is an io.Writer that forwards writes to a replaceable destination.
+// Writes are discarded while no destination is set or after the destination
+// returned an error, so a failing destination never breaks a build.
+type sink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Set replaces the destination of the sink.
+func (s *sink) Set(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w = w
+}
+
+func (s *sink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return len(p), nil
+	}
+	if _, err := s.w.Write(p); err != nil {
+		s.w = nil
+	}
+	return len(p), nil
+}
+
+// stream is an io.WriteCloser that sends every line written to it as a
+// separate chunk of a chunked HTTP POST request.
+type stream struct {
+	pw   *io.PipeWriter
+	buf  []byte
+	done chan error
+}
+
+// streamOutput opens a chunked HTTP POST request to url for the given build.
+// The request is finished when the returned writer is closed.
+func streamOutput(url, actionID string, cycle int) io.WriteCloser {
+	pr, pw := io.Pipe()
+	s := &stream{pw: pw, done: make(chan error, 1)}
+
+	req, err := http.NewRequest(http.MethodPost, url, pr)
+	if err != nil {
+		pr.CloseWithError(err)
+		s.done <- fmt.Errorf("Error streaming output: %w", err)
+		return s
+	}
+	req.TransferEncoding = []string{"chunked"}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("X-Revolver-Action", actionID)
+	req.Header.Set("X-Revolver-Cycle", strconv.Itoa(cycle))
+
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			pr.CloseWithError(err)
+			s.done <- fmt.Errorf("Error streaming output: %w", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			s.done <- fmt.Errorf("Error streaming output: %s", resp.Status)
+			return
+		}
+		s.done <- nil
+	}()
+
+	return s
+}
+
+// Write buffers p and sends every completed line as a chunk.
+func (s *stream) Write(p []byte) (int, error) {
+	s.buf = append(s.buf, p...)
+	for {
+		i := bytes.IndexByte(s.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if _, err := s.pw.Write(s.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		s.buf = s.buf[i+1:]
+	}
+}
+
+// Close sends the remaining partial line and finishes the request.
+func (s *stream) Close() error {
+	if len(s.buf) > 0 {
+		s.pw.Write(s.buf)
+		s.buf = nil
+	}
+	s.pw.Close()
+	return <-s.done
+}
@@ -0,0 +1,123 @@
+package revolver
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type chunkServer struct {
+	*httptest.Server
+	requests chan chunkRequest
+}
+
+type chunkRequest struct {
+	action, cycle string
+	chunked       bool
+	lines         []string
+}
+
+func newChunkServer(t *testing.T) *chunkServer {
+	s := &chunkServer{requests: make(chan chunkRequest, 10)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := chunkRequest{
+			action:  r.Header.Get("X-Revolver-Action"),
+			cycle:   r.Header.Get("X-Revolver-Cycle"),
+			chunked: len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked",
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			req.lines = append(req.lines, scanner.Text())
+		}
+		s.requests <- req
+	}))
+	return s
+}
+
+func TestStreamOutput(t *testing.T) {
+	server := newChunkServer(t)
+	defer server.Close()
+
+	type testCase struct {
+		writes []string
+		lines  []string
+	}
+	for name, tc := range map[string]testCase{
+		"empty": {
+			writes: []string{},
+			lines:  nil,
+		},
+		"single line": {
+			writes: []string{"line 1\n"},
+			lines:  []string{"line 1"},
+		},
+		"multiple lines in one write": {
+			writes: []string{"line 1\nline 2\n"},
+			lines:  []string{"line 1", "line 2"},
+		},
+		"partial lines": {
+			writes: []string{"li", "ne 1\nli", "ne 2"},
+			lines:  []string{"line 1", "line 2"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			stream := streamOutput(server.URL, "action", 3)
+			for _, w := range tc.writes {
+				if _, err := stream.Write([]byte(w)); err != nil {
+					t.Fatalf("Write() err should be nil; got: %v", err)
+				}
+			}
+			if err := stream.Close(); err != nil {
+				t.Fatalf("Close() err should be nil; got: %v", err)
+			}
+
+			req := <-server.requests
+			if !req.chunked {
+				t.Errorf("Request should use chunked transfer encoding")
+			}
+			if req.action != "action" || req.cycle != "3" {
+				t.Errorf("Headers should be action: %q, cycle: %q; got: %q, %q", "action", "3", req.action, req.cycle)
+			}
+			if strings.Join(req.lines, "\n") != strings.Join(tc.lines, "\n") {
+				t.Errorf("Streamed output should be: %v; got: %v", tc.lines, req.lines)
+			}
+		})
+	}
+}
+
+func TestStreamOutputBuild(t *testing.T) {
+	server := newChunkServer(t)
+	defer server.Close()
+
+	output := &sink{}
+	build := buildCommand(commandOptions{Output: output}, "echo", "build output")
+
+	stream := streamOutput(server.URL, "build", 1)
+	output.Set(stream)
+	if err := build(); err != nil {
+		t.Fatalf("Build err should be nil; got: %v", err)
+	}
+	output.Set(nil)
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close() err should be nil; got: %v", err)
+	}
+
+	req := <-server.requests
+	if len(req.lines) != 1 || req.lines[0] != "build output" {
+		t.Errorf("Streamed output should be: %v; got: %v", []string{"build output"}, req.lines)
+	}
+}
+
+func TestStreamOutputServerDown(t *testing.T) {
+	server := newChunkServer(t)
+	url := server.URL
+	server.Close()
+
+	stream := streamOutput(url, "action", 1)
+	stream.Write([]byte("line\n"))
+	if err := stream.Close(); err == nil {
+		t.Errorf("Close() err should not be nil")
+	}
+}
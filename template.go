@@ -0,0 +1,122 @@
+package revolver
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// templateData is the data the command strings of an action are executed
+// with as text/template templates.
+type templateData struct {
+	Dir        string
+	ActionName string
+	ActionID   string
+	Vars       map[string]string
+}
+
+// ID is the ID of the action, an alias of ActionID.
+func (d templateData) ID() string {
+	return d.ActionID
+}
+
+// executeTemplate executes text as a template with the data. Missing keys are
+// errors.
+func executeTemplate(text string, data templateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// expandVars returns the vars of the config with the templates in their values
+// executed, so vars can reference other vars. Recursive references are errors.
+func expandVars(config Config) (map[string]string, error) {
+	vars := make(map[string]string, len(config.Vars))
+	for key, value := range config.Vars {
+		vars[key] = value
+	}
+
+	// Every pass resolves at least one more level of references, so after
+	// len(vars) passes only recursive references can be left.
+	for i := 0; i <= len(vars); i++ {
+		unresolved := false
+		next := make(map[string]string, len(vars))
+		for key, value := range vars {
+			expanded, err := executeTemplate(value, templateData{Dir: config.Dir, Vars: vars})
+			if err != nil {
+				return nil, fmt.Errorf("Error expanding var %s: %w", key, err)
+			}
+			unresolved = unresolved || strings.Contains(expanded, "{{")
+			next[key] = expanded
+		}
+		vars = next
+		if !unresolved {
+			return vars, nil
+		}
+	}
+	return nil, fmt.Errorf("Error expanding vars: recursive reference")
+}
+
+// interpolate executes the command strings and the log prefix of the action as
+// templates.
+func interpolate(a Action, data templateData) (Action, error) {
+	execute := func(text string) (string, error) {
+		result, err := executeTemplate(text, data)
+		if err != nil {
+			return "", fmt.Errorf("Error interpolating command %q: %w", text, err)
+		}
+		return result, nil
+	}
+
+	var err error
+	executeAll := func(texts stringArr) (stringArr, error) {
+		if texts == nil {
+			return nil, nil
+		}
+		results := make(stringArr, len(texts))
+		for i, text := range texts {
+			if results[i], err = execute(text); err != nil {
+				return nil, err
+			}
+		}
+		return results, nil
+	}
+	if a.BuildCommands, err = executeAll(a.BuildCommands); err != nil {
+		return a, err
+	}
+	if a.PreBuild, err = executeAll(a.PreBuild); err != nil {
+		return a, err
+	}
+	if a.PostBuild, err = executeAll(a.PostBuild); err != nil {
+		return a, err
+	}
+	if a.RunCommand, err = execute(a.RunCommand); err != nil {
+		return a, err
+	}
+	if a.PreStop, err = execute(a.PreStop); err != nil {
+		return a, err
+	}
+	if a.PostStop, err = execute(a.PostStop); err != nil {
+		return a, err
+	}
+	if a.OnSuccess, err = execute(a.OnSuccess); err != nil {
+		return a, err
+	}
+	if a.OnFailure, err = execute(a.OnFailure); err != nil {
+		return a, err
+	}
+	if a.LogPrefix, err = execute(a.logPrefix()); err != nil {
+		return a, err
+	}
+	return a, nil
+}
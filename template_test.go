@@ -0,0 +1,101 @@
+package revolver
+
+import (
+	"testing"
+)
+
+func TestParseActionsTemplates(t *testing.T) {
+	type testCase struct {
+		config   Config
+		expected []string
+		err      bool
+	}
+	for name, tc := range map[string]testCase{
+		"no template": {
+			config: Config{Actions: []Action{
+				{BuildCommands: []string{"go build ./..."}},
+			}},
+			expected: []string{"go build ./..."},
+		},
+		"config fields": {
+			config: Config{Dir: "src", Actions: []Action{
+				{Name: "app", BuildCommands: []string{"go build -o {{.Dir}}/bin/{{.ActionName}} ./cmd/{{.ActionID}}"}},
+			}},
+			expected: []string{"go build -o src/bin/app ./cmd/app"},
+		},
+		"action id": {
+			config: Config{Actions: []Action{
+				{BuildCommands: []string{"echo {{.ActionID}}"}},
+			}},
+			expected: []string{"echo 1"},
+		},
+		"vars": {
+			config: Config{
+				Vars: map[string]string{"bin": "{{.Dir}}/bin", "out": "{{.Vars.bin}}/app"},
+				Dir:  "src",
+				Actions: []Action{
+					{BuildCommands: []string{"go build -o {{.Vars.out}}"}, RunCommand: "{{.Vars.out}}"},
+				},
+			},
+			expected: []string{"go build -o src/bin/app", "src/bin/app"},
+		},
+		"missing var": {
+			config: Config{Actions: []Action{
+				{BuildCommands: []string{"echo {{.Vars.missing}}"}},
+			}},
+			err: true,
+		},
+		"missing field": {
+			config: Config{Actions: []Action{
+				{BuildCommands: []string{"echo {{.Missing}}"}},
+			}},
+			err: true,
+		},
+		"malformed": {
+			config: Config{Actions: []Action{
+				{RunCommand: "echo {{.Dir"},
+			}},
+			err: true,
+		},
+		"recursive vars": {
+			config: Config{
+				Vars: map[string]string{"a": "{{.Vars.b}}", "b": "{{.Vars.a}}"},
+				Actions: []Action{
+					{BuildCommands: []string{"echo {{.Vars.a}}"}},
+				},
+			},
+			err: true,
+		},
+		"self reference": {
+			config: Config{
+				Vars: map[string]string{"a": "x{{.Vars.a}}"},
+				Actions: []Action{
+					{BuildCommands: []string{"echo"}},
+				},
+			},
+			err: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			actions, err := parseActions(tc.config)
+			if err != nil {
+				if !tc.err {
+					t.Errorf("parseActions() err should be nil; got: %v", err)
+				}
+				return
+			}
+			if tc.err {
+				t.Fatalf("parseActions() err should not be nil")
+			}
+
+			a := actions[0].Source.Action
+			commands := append([]string{}, a.BuildCommands...)
+			if a.RunCommand != "" {
+				commands = append(commands, a.RunCommand)
+			}
+			if !equals(commands, tc.expected) {
+				t.Errorf("Commands should be %v; got: %v", tc.expected, commands)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package revolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// modulePath is the path of the revolver module.
+const modulePath = "github.com/kszab0/revolver"
+
+// latestReleaseURL is the GitHub API endpoint of the latest release.
+var latestReleaseURL = "https://api.github.com/repos/kszab0/revolver/releases/latest"
+
+// currentVersion returns the version of the revolver module in the running
+// binary. It is empty if the version is unknown, e.g. for a local build.
+func currentVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		if info.Main.Version == "(devel)" {
+			return ""
+		}
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// checkForUpdates fetches the latest release from url and logs a notice if it
+// is newer than the current version.
+func checkForUpdates(url, current string, logger Logger) error {
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("Error checking for updates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error checking for updates: %s", resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return fmt.Errorf("Error checking for updates: %w", err)
+	}
+	if newerVersion(release.TagName, current) {
+		logger.Info("revolver %s available (you have %s), run 'go get -u %s/cmd/revolver' to update", release.TagName, current, modulePath)
+	}
+	return nil
+}
+
+// newerVersion reports whether the semantic version latest is newer than
+// current. Versions that can't be parsed are never newer.
+func newerVersion(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion parses the major, minor and patch numbers of a semantic
+// version like v1.2.3, ignoring its pre-release and build metadata.
+func parseVersion(version string) ([3]int, bool) {
+	var parsed [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return parsed, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, false
+		}
+		parsed[i] = n
+	}
+	return parsed, true
+}
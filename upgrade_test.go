@@ -0,0 +1,86 @@
+package revolver
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCheckForUpdates(t *testing.T) {
+	type testCase struct {
+		status  int
+		body    string
+		current string
+		lines   []string
+		err     bool
+	}
+	for name, tc := range map[string]testCase{
+		"newer version": {
+			status:  http.StatusOK,
+			body:    `{"tag_name": "v1.2.3"}`,
+			current: "v1.1.0",
+			lines:   []string{"info: revolver v1.2.3 available (you have v1.1.0), run 'go get -u github.com/kszab0/revolver/cmd/revolver' to update"},
+		},
+		"same version": {
+			status:  http.StatusOK,
+			body:    `{"tag_name": "v1.1.0"}`,
+			current: "v1.1.0",
+		},
+		"older version": {
+			status:  http.StatusOK,
+			body:    `{"tag_name": "v1.0.9"}`,
+			current: "v1.1.0",
+		},
+		"server error": {
+			status:  http.StatusInternalServerError,
+			current: "v1.1.0",
+			err:     true,
+		},
+		"invalid response": {
+			status:  http.StatusOK,
+			body:    `not json`,
+			current: "v1.1.0",
+			err:     true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer server.Close()
+
+			logger := &recordLogger{}
+			err := checkForUpdates(server.URL, tc.current, logger)
+			if (err != nil) != tc.err {
+				t.Fatalf("checkForUpdates() err should be %v; got: %v", tc.err, err)
+			}
+			if !reflect.DeepEqual(tc.lines, logger.lines) {
+				t.Errorf("checkForUpdates() should log: %v; got: %v", tc.lines, logger.lines)
+			}
+		})
+	}
+}
+
+func TestNewerVersion(t *testing.T) {
+	type testCase struct {
+		latest, current string
+		newer           bool
+	}
+	for name, tc := range map[string]testCase{
+		"major":          {latest: "v2.0.0", current: "v1.9.9", newer: true},
+		"minor":          {latest: "v1.10.0", current: "v1.9.0", newer: true},
+		"patch":          {latest: "v1.0.1", current: "v1.0.0", newer: true},
+		"same":           {latest: "v1.0.0", current: "v1.0.0", newer: false},
+		"older":          {latest: "v1.0.0", current: "v1.0.1", newer: false},
+		"pseudo-version": {latest: "v1.0.0", current: "v0.0.0-20200501120000-abcdef123456", newer: true},
+		"invalid latest": {latest: "latest", current: "v1.0.0", newer: false},
+		"invalid":        {latest: "v1.0.0", current: "(devel)", newer: false},
+	} {
+		if newer := newerVersion(tc.latest, tc.current); newer != tc.newer {
+			t.Errorf("%s: newerVersion(%q, %q) should return %v; got: %v", name, tc.latest, tc.current, tc.newer, newer)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package revolver
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultChangeVelocityWindow is the default window of the change velocity.
+const defaultChangeVelocityWindow = time.Minute
+
+// velocity counts the changed files over a sliding window.
+type velocity struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	changes []velocityChange
+}
+
+// velocityChange is the number of files changed at a time.
+type velocityChange struct {
+	at    time.Time
+	count int
+}
+
+func newVelocity(window time.Duration) *velocity {
+	if window <= 0 {
+		window = defaultChangeVelocityWindow
+	}
+	return &velocity{window: window, now: time.Now}
+}
+
+// add records count changed files.
+func (v *velocity) add(count int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	v.changes = append(v.changes, velocityChange{at: now, count: count})
+	v.prune(now)
+}
+
+// rate returns the number of files changed per second over the window.
+func (v *velocity) rate() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.prune(v.now())
+	total := 0
+	for _, change := range v.changes {
+		total += change.count
+	}
+	return float64(total) / v.window.Seconds()
+}
+
+// prune drops the changes that are out of the window.
+func (v *velocity) prune(now time.Time) {
+	i := 0
+	for i < len(v.changes) && now.Sub(v.changes[i].at) >= v.window {
+		i++
+	}
+	v.changes = v.changes[i:]
+}
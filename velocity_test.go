@@ -0,0 +1,66 @@
+package revolver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVelocity(t *testing.T) {
+	start := time.Now()
+	now := start
+	v := newVelocity(10 * time.Second)
+	v.now = func() time.Time { return now }
+
+	if rate := v.rate(); rate != 0 {
+		t.Errorf("rate() without changes should be 0; got: %v", rate)
+	}
+
+	v.add(10)
+	now = start.Add(5 * time.Second)
+	v.add(20)
+	if rate := v.rate(); rate != 3 {
+		t.Errorf("rate() should be 3; got: %v", rate)
+	}
+
+	now = start.Add(12 * time.Second)
+	if rate := v.rate(); rate != 2 {
+		t.Errorf("rate() after the first change left the window should be 2; got: %v", rate)
+	}
+
+	now = start.Add(20 * time.Second)
+	if rate := v.rate(); rate != 0 {
+		t.Errorf("rate() after every change left the window should be 0; got: %v", rate)
+	}
+}
+
+func TestNewVelocityDefaultWindow(t *testing.T) {
+	if v := newVelocity(0); v.window != defaultChangeVelocityWindow {
+		t.Errorf("window should be: %v; got: %v", defaultChangeVelocityWindow, v.window)
+	}
+}
+
+func TestWatcherRecordChanges(t *testing.T) {
+	w := newTestWatcher(t, Config{PrintOnly: true, ChangeVelocityWindow: time.Second, ChangeVelocityThreshold: 2})
+	logger := &recordLogger{}
+
+	exceeded := w.recordChanges([]string{"a.go", "b.go"}, logger, false)
+	if exceeded {
+		t.Errorf("velocity of 2 files/s should not exceed the threshold")
+	}
+
+	exceeded = w.recordChanges([]string{"c.go"}, logger, exceeded)
+	if !exceeded {
+		t.Errorf("velocity of 3 files/s should exceed the threshold")
+	}
+	exceeded = w.recordChanges([]string{"d.go"}, logger, exceeded)
+	if !exceeded {
+		t.Errorf("velocity of 4 files/s should exceed the threshold")
+	}
+
+	if len(logger.lines) != 1 {
+		t.Errorf("should warn once while the threshold is exceeded; got: %v", logger.lines)
+	}
+	if rate := w.ChangeVelocity(); rate != 4 {
+		t.Errorf("ChangeVelocity() should be 4; got: %v", rate)
+	}
+}
@@ -0,0 +1,588 @@
+package revolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// WatchConfig is the configuration of a Watcher. It is the same struct as the
+// parsed config files, so a program embedding revolver can fill it in Go
+// without any YAML.
+type WatchConfig = Config
+
+// WatchEvent is an event of a Watcher: either an event of an action or the
+// result of a cycle.
+type WatchEvent struct {
+	// Event is the event of an action, e.g. the start or the end of a build.
+	// It is nil for the results of the cycles.
+	Event *Event
+	// Cycle is the result of a cycle. It is nil for the events of the
+	// actions.
+	Cycle *CycleResult
+}
+
+// Watcher runs commands based on file changes in the background.
+type Watcher struct {
+	Config Config
+
+	// onCycle receives the result of every cycle if it is not nil.
+	onCycle func(CycleResult)
+	// onEvent receives the events of the actions if it is not nil.
+	onEvent func(Event)
+	// control pauses and resumes the watch loop if it is not nil.
+	control <-chan WatchControl
+	// after waits for the poll interval. It is time.After if nil.
+	after func(time.Duration) <-chan time.Time
+
+	velocity *velocity
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+	// subscribers receive the events of the actions and the cycle results.
+	subscribers []chan WatchEvent
+	// triggers are the files that triggered the last build of the actions by
+	// their ID.
+	triggers map[string][]string
+	// history are the last buildHistorySize builds of the actions by their
+	// ID, with their output.
+	history map[string]*buildHistory
+	// runner is the runner of the running watch loop.
+	runner *runner
+}
+
+// statsSize is the number of builds per action returned by Stats.
+const statsSize = 10
+
+// subscriberBuffer is the number of events a subscriber channel can hold
+// before further events are dropped.
+const subscriberBuffer = 64
+
+// NewWatcher returns a Watcher for the given config, or the first violation
+// of the config. The watcher doesn't do anything until it is started.
+func NewWatcher(config WatchConfig) (*Watcher, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		Config:   config,
+		velocity: newVelocity(config.ChangeVelocityWindow),
+	}, nil
+}
+
+// ChangeVelocity returns the number of changed files per second over the
+// last ChangeVelocityWindow.
+func (w *Watcher) ChangeVelocity() float64 {
+	return w.velocity.rate()
+}
+
+// Subscribe returns a channel that receives the events of the actions and the
+// result of every cycle. The channel is closed when the watcher stops. The
+// watcher never waits for a subscriber: events are dropped while the channel
+// is full.
+func (w *Watcher) Subscribe() <-chan WatchEvent {
+	ch := make(chan WatchEvent, subscriberBuffer)
+	w.mu.Lock()
+	w.subscribers = append(w.subscribers, ch)
+	w.mu.Unlock()
+	return ch
+}
+
+// LastTrigger returns the changed files that triggered the last build of the
+// action with the given ID. It is empty if the action hasn't been built yet or
+// its last build ran on start.
+func (w *Watcher) LastTrigger(actionID string) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string{}, w.triggers[actionID]...)
+}
+
+// recordBuild records the files that triggered the build and adds it to the
+// build history.
+func (w *Watcher) recordBuild(record BuildRecord) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.triggers == nil {
+		w.triggers = make(map[string][]string)
+	}
+	w.triggers[record.Action] = record.TriggeredBy
+
+	if w.history == nil {
+		w.history = make(map[string]*buildHistory)
+	}
+	history, ok := w.history[record.Action]
+	if !ok {
+		history = &buildHistory{}
+		w.history[record.Action] = history
+	}
+	history.add(record)
+}
+
+// BuildHistory returns the last n builds of the action with the given ID with
+// their output, oldest first. The last 50 builds of every action are kept,
+// n <= 0 returns all of them.
+func (w *Watcher) BuildHistory(actionID string, n int) []BuildRecord {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	history, ok := w.history[actionID]
+	if !ok {
+		return []BuildRecord{}
+	}
+	return history.last(n)
+}
+
+// BuildHistoryJSON returns the builds of the action with the given ID kept by
+// the build history as a JSON array, oldest first.
+func (w *Watcher) BuildHistoryJSON(actionID string) ([]byte, error) {
+	return json.Marshal(w.BuildHistory(actionID, 0))
+}
+
+// Stats returns the last builds of the actions by their ID, at most 10 per
+// action, oldest first. The durations show which action slows down the
+// cycles.
+func (w *Watcher) Stats() map[string][]BuildRecord {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := make(map[string][]BuildRecord, len(w.history))
+	for id, history := range w.history {
+		stats[id] = history.last(statsSize)
+	}
+	return stats
+}
+
+// ResetBreakers closes the circuit breaker and the breakers of the actions
+// opened by BreakAfter, so the skipped actions are built again on the next
+// change.
+func (w *Watcher) ResetBreakers() {
+	w.mu.Lock()
+	r := w.runner
+	w.mu.Unlock()
+
+	if r != nil {
+		r.resetBreakers()
+	}
+}
+
+// publish passes the result of a cycle to onCycle and the subscribers.
+func (w *Watcher) publish(result CycleResult) {
+	if w.onCycle != nil {
+		w.onCycle(result)
+	}
+
+	w.send(WatchEvent{Cycle: &result})
+}
+
+// publishEvent passes the event of an action to onEvent and the subscribers.
+func (w *Watcher) publishEvent(event Event) {
+	if w.onEvent != nil {
+		w.onEvent(event)
+	}
+	w.send(WatchEvent{Event: &event})
+}
+
+// send sends the event to the subscribers that aren't full.
+func (w *Watcher) send(event WatchEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, ch := range w.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// recordChanges adds the changes to the change velocity and warns if the
+// velocity exceeds the configured threshold. It returns whether the velocity
+// exceeds the threshold.
+func (w *Watcher) recordChanges(changes []string, logger Logger, exceeded bool) bool {
+	w.velocity.add(len(changes))
+
+	threshold := w.Config.ChangeVelocityThreshold
+	if threshold <= 0 {
+		return false
+	}
+	rate := w.velocity.rate()
+	if rate <= threshold {
+		return false
+	}
+	if !exceeded {
+		logger.Info("%.2f files changed per second, more than %.2f. Consider excluding frequently changing files.", rate, threshold)
+	}
+	return true
+}
+
+// Start starts watching in the background and returns immediately. The
+// watcher stops like with Stop when ctx is done. A stopped watcher can be
+// started again.
+func (w *Watcher) Start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.done != nil {
+		select {
+		case <-w.done:
+		default:
+			return errors.New("watcher is already running")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	w.cancel = cancel
+	w.done = done
+	w.err = nil
+
+	go func() {
+		err := w.run(ctx.Done())
+		cancel()
+
+		w.mu.Lock()
+		w.err = err
+		for _, ch := range w.subscribers {
+			close(ch)
+		}
+		w.subscribers = nil
+		w.mu.Unlock()
+		close(done)
+	}()
+	return nil
+}
+
+// Stop stops watching and the running commands. It blocks until the watcher
+// is shut down and returns the error that stopped it, like Wait does, e.g. if
+// a build failed the watcher before it was stopped.
+func (w *Watcher) Stop() error {
+	w.mu.Lock()
+	cancel, done := w.cancel, w.done
+	w.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+	<-done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Wait blocks until the watcher is stopped or fails and returns the error
+// that stopped it.
+func (w *Watcher) Wait() error {
+	w.mu.Lock()
+	done := w.done
+	w.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	<-done
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// run executes the watch loop until stop is closed.
+func (w *Watcher) run(stop <-chan struct{}) error {
+	config := w.Config
+	if config.DryRun {
+		return dryRun(config, config.logger())
+	}
+	if config.PrintOnly {
+		return printChanges(config, os.Stdout, stop)
+	}
+
+	r := newRunner(config)
+	if err := runStartupProbes(config, r.logger); err != nil {
+		return err
+	}
+
+	if config.AuditLog != "" {
+		audit, err := openAuditLog(config.AuditLog)
+		if err != nil {
+			return err
+		}
+		defer audit.close()
+		r.audit = audit
+	}
+
+	actions, err := parseActions(config)
+	if err != nil {
+		return err
+	}
+
+	detect := newDetect(config)
+
+	states := newActionStates(actions)
+	if config.StatusAddr != "" {
+		status, err := startStatusServer(config.StatusAddr, states, w.BuildHistoryJSON, r.logger)
+		if err != nil {
+			return err
+		}
+		defer status.close()
+	}
+	var socket *controlSocket
+	if config.SocketPath != "" {
+		socket, err = startControlSocket(config.SocketPath, states, r.logger)
+		if err != nil {
+			return err
+		}
+		defer socket.close()
+	}
+	var metrics *metricsServer
+	if config.MetricsAddr != "" {
+		metrics, err = startMetricsServer(config.MetricsAddr, w.ChangeVelocity, r.logger)
+		if err != nil {
+			return err
+		}
+		defer metrics.close()
+	}
+	var events *eventServer
+	if config.WSAddr != "" {
+		events, err = startEventServer(config.WSAddr, r.logger)
+		if err != nil {
+			return err
+		}
+		defer events.close()
+	}
+
+	r.onCycle = w.publish
+	r.onBuild = w.recordBuild
+	r.onEvent = func(event Event) {
+		states.update(event)
+		metrics.update(event)
+		events.update(event)
+		w.publishEvent(event)
+	}
+	defer r.stopAll()
+	w.mu.Lock()
+	w.runner = r
+	w.mu.Unlock()
+	cycle := 0
+	fast := false
+
+	var reloader *configReloader
+	if config.WatchConfig && config.File != "" {
+		reloader = newConfigReloader(config.File)
+	}
+
+	// wait sleeps for the adaptive poll interval varied by the jitter, or
+	// while the watcher is paused, and reports whether the watcher should
+	// continue.
+	poll := newAdaptiveInterval(config)
+	control := w.control
+	if socket != nil {
+		control = mergeControl(stop, control, socket.controlled())
+	}
+	after := w.after
+	if after == nil {
+		after = time.After
+	}
+	rnd := newJitterRand()
+	wait := func() bool {
+		select {
+		case <-stop:
+			return false
+		case c, ok := <-control:
+			if !ok {
+				control = nil
+				return true
+			}
+			switch c {
+			case Pause:
+				states.setPaused(true)
+				ok := paused(stop, control, r)
+				states.setPaused(false)
+				if !ok {
+					return false
+				}
+			case ResetBreakers:
+				r.resetBreakers()
+			}
+			return true
+		case <-states.triggered():
+			return true
+		case <-after(jitterInterval(rnd, poll.interval(), config.IntervalJitter)):
+			return true
+		}
+	}
+
+	// The first detection reports every file as added, so the actions
+	// matching the existing files run on the first poll. If all the actions
+	// should run on start, it only takes the initial snapshot instead.
+	if config.RunOnStart {
+		detect()
+	}
+	if config.CheckForUpdates {
+		if current := currentVersion(); current != "" {
+			go func() {
+				if err := checkForUpdates(latestReleaseURL, current, r.logger); err != nil {
+					r.logger.Error(err)
+				}
+			}()
+		}
+	}
+	if config.RunOnStart {
+		cycle++
+		r.report(r.runCycle(actions, cycle), nil)
+		if r.done {
+			return r.failed
+		}
+		if !wait() {
+			return nil
+		}
+	}
+
+	for {
+		if reloader != nil {
+			if next, ok := reloader.reload(r.logger); ok {
+				next.Logger = config.Logger
+				next.Detect = config.Detect
+				nextActions, err := parseActions(*next)
+				if err != nil {
+					r.logger.Error(fmt.Errorf("Error reloading config: %w", err))
+				} else {
+					if !watchesSameFiles(config, *next) {
+						detect = newDetect(*next)
+						detect()
+					}
+					config = *next
+					r.config = config
+					poll = newAdaptiveInterval(config)
+					prev := actions
+					actions = nextActions
+					cycle = r.reloadActions(prev, actions, cycle)
+					states.setActions(actions)
+				}
+			}
+		}
+
+		cycle = r.runDebounced(actions, cycle)
+		if r.done {
+			return r.failed
+		}
+
+		if due := states.takePending(actions); len(due) > 0 {
+			cycle++
+			r.report(r.runCycle(due, cycle), nil)
+			if r.done {
+				return r.failed
+			}
+		}
+
+		changes := detect()
+		if len(changes) == 0 {
+			poll.unchanged()
+			if !wait() {
+				return nil
+			}
+			continue
+		}
+		if config.Debounce > 0 {
+			changes = debounce(detect, changes, config.Debounce, config.Interval)
+		}
+		poll.changed()
+		fast = w.recordChanges(changes, r.logger, fast)
+		if config.FileTypeIndicators {
+			color := config.LogFormat != "json" && isTerminal(os.Stdout)
+			r.logger.Info("Changed: %s", indicateFileTypes(config.FileTypeIndicatorMap, changes, color))
+		}
+		cycle = r.runChanges(detect, actions, changes, cycle)
+		if r.done {
+			return r.failed
+		}
+
+		if !wait() {
+			return nil
+		}
+	}
+}
+
+// WatchUntil watches like Watch does until the until predicate returns true
+// for the result of a cycle or ctx is done.
+func WatchUntil(ctx context.Context, config Config, until func(CycleResult) bool) error {
+	met := make(chan struct{})
+	var once sync.Once
+
+	watcher, err := NewWatcher(config)
+	if err != nil {
+		return err
+	}
+	watcher.onCycle = func(result CycleResult) {
+		if until(result) {
+			once.Do(func() { close(met) })
+		}
+	}
+	if err := watcher.Start(ctx); err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- watcher.Wait()
+	}()
+
+	select {
+	case <-met:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-stopped:
+		return err
+	}
+}
+
+// WatchFunc calls cb with the change events of the files in dir, polled every
+// interval, skipping the excludeDirs. Errors returned by cb are logged and
+// watching continues. It blocks until revolver receives SIGINT or SIGTERM.
+func WatchFunc(dir string, excludeDirs []string, interval time.Duration, cb func(events []FileEvent) error) error {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	stop := make(chan struct{})
+	go func() {
+		<-sigs
+		close(stop)
+	}()
+	return watchFunc(dir, excludeDirs, interval, cb, NewLogger("text", os.Stdout), stop)
+}
+
+// watchFunc calls cb like WatchFunc does until stop is closed.
+func watchFunc(dir string, excludeDirs []string, interval time.Duration, cb func(events []FileEvent) error, logger Logger, stop <-chan struct{}) error {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("Error watching dir: %s should be a directory", dir)
+	}
+	if interval <= 0 {
+		interval = 500 * time.Millisecond
+	}
+
+	detect := DetectEvents(dir, excludeDirs)
+	// The first detection reports every file as added.
+	detect()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-time.After(interval):
+		}
+
+		if events := detect(); len(events) > 0 {
+			if err := cb(events); err != nil {
+				logger.Error(fmt.Errorf("Error handling changes: %w", err))
+			}
+		}
+	}
+}
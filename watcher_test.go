@@ -0,0 +1,763 @@
+package revolver
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestWatcher returns a watcher for the config, failing the test if the
+// config is invalid.
+func newTestWatcher(t *testing.T, config Config) *Watcher {
+	watcher, err := NewWatcher(config)
+	if err != nil {
+		t.Fatalf("NewWatcher() err should be nil; got: %v", err)
+	}
+	return watcher
+}
+
+// subscribeCycles returns a channel receiving the cycle results of the
+// watcher, closed when the watcher stops. Like with Subscribe, results are
+// dropped while the channel is full.
+func subscribeCycles(watcher *Watcher) <-chan CycleResult {
+	events := watcher.Subscribe()
+	results := make(chan CycleResult, subscriberBuffer)
+	go func() {
+		defer close(results)
+		for event := range events {
+			if event.Cycle == nil {
+				continue
+			}
+			select {
+			case results <- *event.Cycle:
+			default:
+			}
+		}
+	}()
+	return results
+}
+
+func TestNewWatcher(t *testing.T) {
+	type testCase struct {
+		config Config
+		err    bool
+	}
+	for name, tc := range map[string]testCase{
+		"valid": {
+			config: Config{Actions: []Action{{BuildCommands: []string{"true"}}}},
+		},
+		"missing command": {
+			config: Config{Actions: []Action{{Name: "empty"}}},
+			err:    true,
+		},
+		"invalid retryIf": {
+			config: Config{Actions: []Action{{BuildCommands: []string{"true"}, RetryIf: "("}}},
+			err:    true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			watcher, err := NewWatcher(tc.config)
+			if (err != nil) != tc.err {
+				t.Errorf("NewWatcher() err should be %v; got: %v", tc.err, err)
+			}
+			if (watcher == nil) != tc.err {
+				t.Errorf("NewWatcher() should return a watcher only without an error")
+			}
+		})
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	t.Run("Start Stop", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		out := filepath.Join(dir, "out")
+		watcher := newTestWatcher(t, Config{
+			Dir:        dir,
+			Interval:   10 * time.Millisecond,
+			RunOnStart: true,
+			Actions: []Action{
+				{RunCommand: "sleep 5", BuildCommands: []string{"touch " + out}},
+			},
+		})
+
+		goroutines := runtime.NumGoroutine()
+		for i := 0; i < 3; i++ {
+			if err := watcher.Start(context.Background()); err != nil {
+				t.Fatalf("Start() err should be nil; got: %v", err)
+			}
+			time.Sleep(50 * time.Millisecond)
+			watcher.Stop()
+			if err := watcher.Wait(); err != nil {
+				t.Errorf("Wait() err should be nil; got: %v", err)
+			}
+		}
+
+		if _, err := ioutil.ReadFile(out); err != nil {
+			t.Errorf("action should have run: %v", err)
+		}
+
+		// Give the exited goroutines time to be cleaned up.
+		time.Sleep(50 * time.Millisecond)
+		if got := runtime.NumGoroutine(); got > goroutines {
+			t.Errorf("goroutines should not leak: before %d; after %d", goroutines, got)
+		}
+	})
+
+	t.Run("Start twice", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		watcher := newTestWatcher(t, Config{
+			Dir:       dir,
+			Interval:  10 * time.Millisecond,
+			PrintOnly: true,
+		})
+		if err := watcher.Start(context.Background()); err != nil {
+			t.Fatalf("Start() err should be nil; got: %v", err)
+		}
+		defer watcher.Stop()
+
+		if err := watcher.Start(context.Background()); err == nil {
+			t.Errorf("second Start() err should not be nil")
+		}
+	})
+
+	t.Run("Stop not started", func(t *testing.T) {
+		watcher := newTestWatcher(t, Config{PrintOnly: true})
+		watcher.Stop()
+		if err := watcher.Wait(); err != nil {
+			t.Errorf("Wait() err should be nil; got: %v", err)
+		}
+	})
+}
+
+func TestWatcherSubscribeCycles(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:        dir,
+		Interval:   10 * time.Millisecond,
+		RunOnStart: true,
+		Actions: []Action{
+			{Name: "ok", BuildCommands: []string{"true"}},
+			{Name: "fail", BuildCommands: []string{"false"}},
+		},
+	})
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+
+	select {
+	case result := <-results:
+		if result.Cycle != 1 {
+			t.Errorf("Cycle should be 1; got: %d", result.Cycle)
+		}
+		if !equals([]string{"ok", "fail"}, result.Actions) {
+			t.Errorf("Actions should be: %v; got: %v", []string{"ok", "fail"}, result.Actions)
+		}
+		if _, ok := result.Errors["fail"]; !ok || len(result.Errors) != 1 {
+			t.Errorf("Errors should only contain fail; got: %v", result.Errors)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Subscriber should receive the result of the cycle")
+	}
+
+	watcher.Stop()
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Errorf("Subscriber should not receive more results")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Subscriber channel should be closed when the watcher stops")
+	}
+}
+
+func TestWatcherSubscribe(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:        dir,
+		Interval:   10 * time.Millisecond,
+		RunOnStart: true,
+		Actions: []Action{
+			{Name: "fail", BuildCommands: []string{"false"}},
+		},
+	})
+	events := watcher.Subscribe()
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+
+	var kinds []EventKind
+	var result *CycleResult
+	timeout := time.After(5 * time.Second)
+	for result == nil {
+		select {
+		case event := <-events:
+			if event.Cycle != nil {
+				result = event.Cycle
+				continue
+			}
+			if event.Event.ActionID != "fail" {
+				t.Errorf("ActionID should be fail; got: %s", event.Event.ActionID)
+			}
+			kinds = append(kinds, event.Event.Kind)
+		case <-timeout:
+			t.Fatalf("Subscriber should receive the events and the result of the cycle; got: %v", kinds)
+		}
+	}
+	if !reflect.DeepEqual(kinds, []EventKind{BuildStarted, BuildFailed}) {
+		t.Errorf("Events should be: %v; got: %v", []EventKind{BuildStarted, BuildFailed}, kinds)
+	}
+	if _, ok := result.Errors["fail"]; result.Cycle != 1 || !ok {
+		t.Errorf("Result should be the failed cycle 1; got: %+v", result)
+	}
+
+	watcher.Stop()
+	for {
+		select {
+		case _, ok := <-events:
+			if ok {
+				continue
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Subscriber channel should be closed when the watcher stops")
+		}
+		return
+	}
+}
+
+func TestWatcherStartCanceled(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:       dir,
+		Interval:  10 * time.Millisecond,
+		PrintOnly: true,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := watcher.Start(ctx); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+
+	cancel()
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- watcher.Wait()
+	}()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Errorf("Wait() err should be nil; got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watcher should stop when the context is done")
+	}
+
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Watcher should start again; got: %v", err)
+	}
+	if err := watcher.Stop(); err != nil {
+		t.Errorf("Stop() err should be nil; got: %v", err)
+	}
+}
+
+func TestWatcherStopError(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:        dir,
+		Interval:   10 * time.Millisecond,
+		RunOnStart: true,
+		OnError:    "stop",
+		Actions: []Action{
+			{Name: "fail", BuildCommands: []string{"false"}},
+		},
+	})
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	watcher.Wait()
+
+	if err := watcher.Stop(); err == nil {
+		t.Errorf("Stop() err should be the error that stopped the watcher")
+	}
+}
+
+func TestWatcherInitialFiles(t *testing.T) {
+	type testCase struct {
+		runOnStart bool
+		expected   []string
+	}
+	for name, tc := range map[string]testCase{
+		"existing files trigger the matching actions": {
+			expected: []string{"go"},
+		},
+		"run on start runs every action": {
+			runOnStart: true,
+			expected:   []string{"go", "js"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0600); err != nil {
+				t.Fatalf("Cannot write file: %v", err)
+			}
+
+			watcher := newTestWatcher(t, Config{
+				Dir:        dir,
+				Interval:   10 * time.Millisecond,
+				RunOnStart: tc.runOnStart,
+				Logger:     NewLogger("text", ioutil.Discard),
+				Actions: []Action{
+					{Name: "go", Patterns: []string{"**/*.go"}, BuildCommands: []string{"true"}},
+					{Name: "js", Patterns: []string{"**/*.js"}, BuildCommands: []string{"true"}},
+				},
+			})
+			results := subscribeCycles(watcher)
+			if err := watcher.Start(context.Background()); err != nil {
+				t.Fatalf("Start() err should be nil; got: %v", err)
+			}
+			defer watcher.Stop()
+
+			select {
+			case result := <-results:
+				if !equals(tc.expected, result.Actions) {
+					t.Errorf("Actions should be: %v; got: %v", tc.expected, result.Actions)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Actions should be run on start")
+			}
+		})
+	}
+}
+
+func TestWatcherCustomDetect(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	changes := make(chan []string, 2)
+	changes <- []string{"README.md"}
+	changes <- []string{"main.go"}
+	watcher := newTestWatcher(t, Config{
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+		Detect: func() []string {
+			select {
+			case changed := <-changes:
+				return changed
+			default:
+				return nil
+			}
+		},
+		Actions: []Action{
+			{Name: "build", Patterns: []string{"**/*.go"}, BuildCommands: []string{"true"}},
+		},
+	})
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	// The initial files don't match the patterns of the action.
+	for {
+		select {
+		case result := <-results:
+			if len(result.Actions) == 0 {
+				continue
+			}
+			if !equals([]string{"main.go"}, result.Changes) {
+				t.Errorf("Changes should be: %v; got: %v", []string{"main.go"}, result.Changes)
+			}
+			if !equals([]string{"build"}, result.Actions) {
+				t.Errorf("Actions should be: %v; got: %v", []string{"build"}, result.Actions)
+			}
+			return
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Changes of the custom detect should run the action")
+		}
+	}
+}
+
+func TestWatcherLastTrigger(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+		Actions: []Action{
+			{Name: "build", Patterns: []string{"*.go"}, BuildCommands: []string{"true"}},
+		},
+	})
+	results := subscribeCycles(watcher)
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	if trigger := watcher.LastTrigger("build"); len(trigger) != 0 {
+		t.Errorf("LastTrigger() should be empty before a build; got: %v", trigger)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	for _, name := range []string{"main.go", "README.md"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+	}
+
+	select {
+	case <-results:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Watcher should run a cycle")
+	}
+	if trigger := watcher.LastTrigger("build"); !equals([]string{"main.go"}, trigger) {
+		t.Errorf("LastTrigger() should be: %v; got: %v", []string{"main.go"}, trigger)
+	}
+}
+
+func TestWatcherOnError(t *testing.T) {
+	type testCase struct {
+		onError  string
+		stopped  bool
+		notified bool
+	}
+	for name, tc := range map[string]testCase{
+		"continue": {
+			onError: "continue",
+		},
+		"stop": {
+			onError: "stop",
+			stopped: true,
+		},
+		"notify": {
+			onError:  "notify",
+			notified: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+			notified := filepath.Join(dir, "notified")
+
+			watcher := newTestWatcher(t, Config{
+				Dir:           dir,
+				Interval:      10 * time.Millisecond,
+				RunOnStart:    true,
+				Shell:         "sh -c",
+				OnError:       tc.onError,
+				NotifyCommand: "echo $REVOLVER_ACTION > " + notified,
+				Actions: []Action{
+					{Name: "build", BuildCommands: []string{"false"}},
+				},
+			})
+			if err := watcher.Start(context.Background()); err != nil {
+				t.Fatalf("Start() err should be nil; got: %v", err)
+			}
+			defer watcher.Stop()
+
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- watcher.Wait()
+			}()
+			select {
+			case err := <-stopped:
+				if !tc.stopped {
+					t.Fatalf("Watcher should keep watching after a failed build; got: %v", err)
+				}
+				if err == nil || !strings.Contains(err.Error(), "build") {
+					t.Errorf("Wait() err should be the failed build; got: %v", err)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tc.stopped {
+					t.Fatalf("Watcher should stop on the failed build")
+				}
+			}
+
+			_, err := os.Stat(notified)
+			if notified := err == nil; notified != tc.notified {
+				t.Errorf("Notify command should run: %v; ran: %v", tc.notified, notified)
+			}
+		})
+	}
+}
+
+func TestWatcherRunOnce(t *testing.T) {
+	type testCase struct {
+		build string
+		err   bool
+	}
+	for name, tc := range map[string]testCase{
+		"success": {
+			build: "true",
+		},
+		"failure": {
+			build: "false",
+			err:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, teardown := createTempDir(t)
+			defer teardown()
+
+			watcher := newTestWatcher(t, Config{
+				Dir:      dir,
+				Interval: 10 * time.Millisecond,
+				Shell:    "sh -c",
+				RunOnce:  true,
+				Logger:   NewLogger("text", ioutil.Discard),
+				Actions: []Action{
+					{Name: "build", Patterns: []string{"*.go"}, BuildCommands: []string{tc.build}},
+				},
+			})
+			if err := watcher.Start(context.Background()); err != nil {
+				t.Fatalf("Start() err should be nil; got: %v", err)
+			}
+			defer watcher.Stop()
+
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- watcher.Wait()
+			}()
+
+			// A change not triggering any action doesn't complete the cycle.
+			time.Sleep(50 * time.Millisecond)
+			if err := ioutil.WriteFile(filepath.Join(dir, "README.md"), nil, 0600); err != nil {
+				t.Fatalf("Cannot write file: %v", err)
+			}
+			select {
+			case err := <-stopped:
+				t.Fatalf("Watcher should wait for a change triggering an action; got: %v", err)
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0600); err != nil {
+				t.Fatalf("Cannot write file: %v", err)
+			}
+			select {
+			case err := <-stopped:
+				if (err != nil) != tc.err {
+					t.Errorf("Wait() err should be returned: %v; got: %v", tc.err, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Watcher should stop after the first cycle")
+			}
+		})
+	}
+}
+
+func TestWatchUntilError(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- WatchUntilError(Config{
+			Dir:        dir,
+			Interval:   10 * time.Millisecond,
+			Shell:      "sh -c",
+			RunOnStart: true,
+			Logger:     NewLogger("text", ioutil.Discard),
+			Actions: []Action{
+				{Name: "build", Patterns: []string{"**/*"}, WorkingDir: dir, BuildCommands: []string{"test ! -e broken"}},
+			},
+		})
+	}()
+
+	// Successful builds don't stop watching.
+	select {
+	case err := <-stopped:
+		t.Fatalf("WatchUntilError() should wait for a failed build; got: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "broken"), nil, 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+	select {
+	case err := <-stopped:
+		if err == nil || !strings.Contains(err.Error(), "build") {
+			t.Errorf("WatchUntilError() should return the error of the build; got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("WatchUntilError() should return after a failed build")
+	}
+}
+
+func TestWatcherStats(t *testing.T) {
+	watcher := newTestWatcher(t, Config{PrintOnly: true})
+	if stats := watcher.Stats(); len(stats) != 0 {
+		t.Errorf("Stats() should be empty before a build; got: %v", stats)
+	}
+
+	for i := 1; i <= statsSize+2; i++ {
+		watcher.recordBuild(BuildRecord{Action: "slow", Cycle: i, Duration: time.Duration(i) * time.Second})
+	}
+	watcher.recordBuild(BuildRecord{Action: "fast", Cycle: 1, Duration: time.Millisecond})
+
+	stats := watcher.Stats()
+	if len(stats["slow"]) != statsSize {
+		t.Fatalf("Stats() should keep the last %d builds; got: %d", statsSize, len(stats["slow"]))
+	}
+	if first, last := stats["slow"][0], stats["slow"][statsSize-1]; first.Cycle != 3 || last.Cycle != statsSize+2 {
+		t.Errorf("Stats() should keep the last builds, oldest first; got cycles %d to %d", first.Cycle, last.Cycle)
+	}
+	if len(stats["fast"]) != 1 || stats["fast"][0].Duration != time.Millisecond {
+		t.Errorf("Stats() should contain the build of fast; got: %v", stats["fast"])
+	}
+
+	stats["fast"][0].Duration = 0
+	if watcher.Stats()["fast"][0].Duration != time.Millisecond {
+		t.Errorf("Stats() should return a copy")
+	}
+
+	// The stats are the last builds of the build history, even once it wraps
+	// around.
+	for i := statsSize + 3; i <= buildHistorySize+5; i++ {
+		watcher.recordBuild(BuildRecord{Action: "slow", Cycle: i})
+	}
+	stats = watcher.Stats()
+	if first, last := stats["slow"][0], stats["slow"][statsSize-1]; first.Cycle != buildHistorySize+6-statsSize || last.Cycle != buildHistorySize+5 {
+		t.Errorf("Stats() should keep the last builds of the history; got cycles %d to %d", first.Cycle, last.Cycle)
+	}
+}
+
+func TestWatchUntil(t *testing.T) {
+	t.Run("successful build", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		config := Config{
+			Dir:      dir,
+			Interval: 10 * time.Millisecond,
+			Actions: []Action{
+				{Name: "build", Patterns: []string{"*.go"}, BuildCommands: []string{"true"}},
+			},
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0600); err != nil {
+				t.Errorf("Cannot write file: %v", err)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		var result CycleResult
+		err := WatchUntil(ctx, config, func(r CycleResult) bool {
+			result = r
+			return r.Success() && len(r.Actions) > 0
+		})
+		if err != nil {
+			t.Fatalf("WatchUntil() err should be nil; got: %v", err)
+		}
+		if !equals([]string{"main.go"}, result.Changes) {
+			t.Errorf("Changes should be: %v; got: %v", []string{"main.go"}, result.Changes)
+		}
+		if !equals([]string{"build"}, result.Actions) {
+			t.Errorf("Actions should be: %v; got: %v", []string{"build"}, result.Actions)
+		}
+	})
+
+	t.Run("context done", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+
+		config := Config{
+			Dir:      dir,
+			Interval: 10 * time.Millisecond,
+			Actions:  []Action{{BuildCommands: []string{"true"}}},
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := WatchUntil(ctx, config, func(CycleResult) bool { return true })
+		if err != context.DeadlineExceeded {
+			t.Errorf("WatchUntil() err should be: %v; got: %v", context.DeadlineExceeded, err)
+		}
+	})
+}
+
+func TestWatchFunc(t *testing.T) {
+	t.Run("events", func(t *testing.T) {
+		dir, teardown := createTempDir(t)
+		defer teardown()
+		existing := filepath.Join(dir, "existing.go")
+		if err := ioutil.WriteFile(existing, nil, 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+
+		batches := make(chan []FileEvent, 10)
+		cb := func(events []FileEvent) error {
+			batches <- events
+			return errors.New("handler failed")
+		}
+		logger := &recordLogger{}
+		stop := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- watchFunc(dir, nil, 10*time.Millisecond, cb, logger, stop)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0600); err != nil {
+			t.Fatalf("Cannot write file: %v", err)
+		}
+		if err := os.Remove(existing); err != nil {
+			t.Fatalf("Cannot remove file: %v", err)
+		}
+
+		events := []FileEvent{}
+		for len(events) < 2 {
+			select {
+			case batch := <-batches:
+				events = append(events, batch...)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Callback should receive the events; got: %v", events)
+			}
+		}
+
+		close(stop)
+		if err := <-done; err != nil {
+			t.Errorf("watchFunc() err should be nil; got: %v", err)
+		}
+
+		expected := []FileEvent{{Path: "existing.go", Kind: Deleted}, {Path: "main.go", Kind: Added}}
+		sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+		if len(events) != 2 || events[0] != expected[0] || events[1] != expected[1] {
+			t.Errorf("Events should be: %v; got: %v", expected, events)
+		}
+		if len(logger.lines) == 0 || logger.lines[0] != "error: Error handling changes: handler failed" {
+			t.Errorf("Callback errors should be logged; got: %v", logger.lines)
+		}
+	})
+
+	t.Run("not a dir", func(t *testing.T) {
+		cb := func([]FileEvent) error { return nil }
+		if err := watchFunc("not_exists", nil, 0, cb, &recordLogger{}, nil); err == nil {
+			t.Errorf("watchFunc() err should not be nil")
+		}
+	})
+}
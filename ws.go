@@ -0,0 +1,219 @@
+package revolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsEvent is the JSON form of an event pushed to the WebSocket clients.
+type wsEvent struct {
+	Event      string    `json:"event"`
+	Action     string    `json:"action"`
+	Timestamp  time.Time `json:"ts"`
+	DurationMs int64     `json:"durationMs,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// wsClientBuffer is the number of events a WebSocket client can lag behind
+// before further events are dropped for it.
+const wsClientBuffer = 64
+
+// wsWriteTimeout is the maximum duration of sending an event to a client.
+const wsWriteTimeout = 5 * time.Second
+
+// eventServer pushes the events of the actions to WebSocket clients at
+// /events, and serves a page rendering them at /. A nil eventServer pushes
+// nothing.
+type eventServer struct {
+	server   *http.Server
+	logger   Logger
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[*wsClient]struct{}
+}
+
+// wsClient is a WebSocket connection receiving the events.
+type wsClient struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+func newEventServer(logger Logger) *eventServer {
+	return &eventServer{
+		logger:  logger,
+		clients: make(map[*wsClient]struct{}),
+	}
+}
+
+// startEventServer listens on addr and serves the events in the background.
+func startEventServer(addr string, logger Logger) (*eventServer, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Error starting event server: %w", err)
+	}
+
+	s := newEventServer(logger)
+	s.server = &http.Server{Handler: s.handler()}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Error(fmt.Errorf("Error serving events: %w", err))
+		}
+	}()
+	logger.Info("Serving events on %s", listener.Addr())
+	return s, nil
+}
+
+func (s *eventServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handlePage)
+	mux.HandleFunc("/events", s.handleEvents)
+	return mux
+}
+
+// close shuts down the server and closes the connections of the clients.
+func (s *eventServer) close() {
+	if s == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), statusShutdownTimeout)
+	defer cancel()
+	if err := s.server.Shutdown(ctx); err != nil {
+		s.server.Close()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		client.conn.Close()
+	}
+}
+
+// update pushes the event to every client. The event is dropped for the
+// clients that don't keep up, so a slow client never blocks the watcher.
+func (s *eventServer) update(event Event) {
+	if s == nil {
+		return
+	}
+	message, err := json.Marshal(wsEvent{
+		Event:      event.Kind.String(),
+		Action:     event.ActionID,
+		Timestamp:  time.Now(),
+		DurationMs: event.Duration.Milliseconds(),
+		Error:      errorMessage(event.Err),
+	})
+	if err != nil {
+		s.logger.Error(fmt.Errorf("Error encoding event: %w", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for client := range s.clients {
+		select {
+		case client.send <- message:
+		default:
+		}
+	}
+}
+
+func (s *eventServer) handleEvents(w http.ResponseWriter, req *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error.
+		return
+	}
+	client := &wsClient{conn: conn, send: make(chan []byte, wsClientBuffer)}
+	s.mu.Lock()
+	s.clients[client] = struct{}{}
+	s.mu.Unlock()
+
+	// The clients don't send anything, reading only processes the control
+	// messages and detects when the connection is closed.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	for {
+		select {
+		case <-closed:
+			return
+		case message := <-client.send:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *eventServer) handlePage(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write([]byte(eventsPage)); err != nil {
+		s.logger.Error(fmt.Errorf("Error writing events page: %w", err))
+	}
+}
+
+// eventsPage renders the events received from /events.
+const eventsPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>revolver</title>
+<style>
+body { font-family: monospace; margin: 1em; }
+.build_succeeded { color: green; }
+.build_failed { color: red; }
+.build_started, .run_restarted { color: darkorange; }
+</style>
+</head>
+<body>
+<h1>revolver</h1>
+<p id="state">Connecting...</p>
+<ul id="events"></ul>
+<script>
+var state = document.getElementById("state");
+var list = document.getElementById("events");
+var ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/events");
+ws.onopen = function() { state.textContent = "Connected."; };
+ws.onclose = function() { state.textContent = "Disconnected."; };
+ws.onmessage = function(message) {
+	var e = JSON.parse(message.data);
+	var item = document.createElement("li");
+	item.className = e.event;
+	item.textContent = e.ts + " [" + e.action + "] " + e.event +
+		(e.durationMs ? " in " + e.durationMs + "ms" : "") +
+		(e.error ? ": " + e.error : "");
+	list.insertBefore(item, list.firstChild);
+};
+</script>
+</body>
+</html>
+`
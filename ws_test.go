@@ -0,0 +1,188 @@
+package revolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dialEvents connects to the events endpoint of the server at addr, retrying
+// until the server is listening.
+func dialEvents(t *testing.T, addr string) *websocket.Conn {
+	t.Helper()
+	var conn *websocket.Conn
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var err error
+		if conn, _, err = websocket.DefaultDialer.Dial("ws://"+addr+"/events", nil); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Cannot connect to events: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return conn
+}
+
+// readEvent reads the next event from conn.
+func readEvent(t *testing.T, conn *websocket.Conn) wsEvent {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event wsEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("Cannot read event: %v", err)
+	}
+	return event
+}
+
+func TestEventServerEvents(t *testing.T) {
+	s := newEventServer(NewLogger("text", ioutil.Discard))
+	server := httptest.NewServer(s.handler())
+	defer server.Close()
+
+	clients := func() int {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.clients)
+	}
+	conn := dialEvents(t, strings.TrimPrefix(server.URL, "http://"))
+	defer conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for clients() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Client should be registered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.update(Event{ActionID: "build", Kind: BuildStarted})
+	s.update(Event{ActionID: "build", Kind: BuildFailed, Duration: 1500 * time.Millisecond, Err: errors.New("exit status 1")})
+	s.update(Event{ActionID: "server", Kind: RunRestarted})
+
+	for _, expected := range []wsEvent{
+		{Event: "build_started", Action: "build"},
+		{Event: "build_failed", Action: "build", DurationMs: 1500, Error: "exit status 1"},
+		{Event: "run_restarted", Action: "server"},
+	} {
+		event := readEvent(t, conn)
+		if event.Timestamp.IsZero() {
+			t.Errorf("Timestamp of %s should be set", event.Event)
+		}
+		event.Timestamp = time.Time{}
+		if event != expected {
+			t.Errorf("Event should be %+v; got: %+v", expected, event)
+		}
+	}
+
+	conn.Close()
+	deadline = time.Now().Add(5 * time.Second)
+	for clients() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Closed client should be removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestEventServerPage(t *testing.T) {
+	type testCase struct {
+		method string
+		target string
+		code   int
+	}
+	for name, tc := range map[string]testCase{
+		"page": {
+			method: http.MethodGet,
+			target: "/",
+			code:   http.StatusOK,
+		},
+		"wrong method": {
+			method: http.MethodPost,
+			target: "/",
+			code:   http.StatusMethodNotAllowed,
+		},
+		"unknown path": {
+			method: http.MethodGet,
+			target: "/unknown",
+			code:   http.StatusNotFound,
+		},
+		"events without upgrade": {
+			method: http.MethodGet,
+			target: "/events",
+			code:   http.StatusBadRequest,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newEventServer(NewLogger("text", ioutil.Discard))
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, httptest.NewRequest(tc.method, tc.target, nil))
+			if rec.Code != tc.code {
+				t.Errorf("Status code should be %d; got: %d", tc.code, rec.Code)
+			}
+			if tc.code == http.StatusOK && !strings.Contains(rec.Body.String(), `"/events"`) {
+				t.Errorf("Page should connect to /events; got: %s", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestWatcherWSAddr(t *testing.T) {
+	dir, teardown := createTempDir(t)
+	defer teardown()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	watcher := newTestWatcher(t, Config{
+		Dir:      dir,
+		Interval: 10 * time.Millisecond,
+		WSAddr:   addr,
+		Logger:   NewLogger("text", ioutil.Discard),
+		Actions: []Action{
+			{Name: "build", Patterns: []string{"**/*"}, BuildCommands: []string{"true"}},
+		},
+	})
+	if err := watcher.Start(context.Background()); err != nil {
+		t.Fatalf("Start() err should be nil; got: %v", err)
+	}
+	defer watcher.Stop()
+
+	conn := dialEvents(t, addr)
+	defer conn.Close()
+	// The client is registered right after the upgrade.
+	time.Sleep(50 * time.Millisecond)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.go"), nil, 0600); err != nil {
+		t.Fatalf("Cannot write file: %v", err)
+	}
+	for _, expected := range []string{"build_started", "build_succeeded"} {
+		if event := readEvent(t, conn); event.Event != expected || event.Action != "build" {
+			t.Errorf("Event should be %s of build; got: %+v", expected, event)
+		}
+	}
+}
+
+func TestWSEventJSON(t *testing.T) {
+	b, err := json.Marshal(wsEvent{Event: "build_started", Action: "build", Timestamp: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("Marshal() err should be nil; got: %v", err)
+	}
+	if expected := `{"event":"build_started","action":"build","ts":"2020-01-02T03:04:05Z"}`; string(b) != expected {
+		t.Errorf("JSON should be %s; got: %s", expected, b)
+	}
+}